@@ -12,22 +12,55 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/desertthunder/weather/internal/gazetteer"
 	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/places"
 )
 
 func selectCity() nws.City {
 	var options []huh.Option[nws.City]
 	var selected nws.City
 
-	for _, city := range nws.Cities() {
-		options = append(options, huh.NewOption(city.Name, city))
+	cities := nws.Cities()
+	seen := map[string]bool{}
+
+	// Saved places are listed first.
+	if s, err := openPlaces(); err == nil {
+		for _, p := range s.Places {
+			label := places.Prefix + p.Name
+
+			if p.Label != "" {
+				label = fmt.Sprintf("%s (%s)", label, p.Label)
+			}
+
+			options = append(options, huh.NewOption(label, p.City()))
+		}
+	}
+
+	for _, name := range nws.CityNames() {
+		seen[name] = true
+		options = append(options, huh.NewOption(name, cities[name]))
+	}
+
+	// The offline gazetteer fills out the list so that the filter has more
+	// than the built in cities to match against.
+	if g, err := gazetteer.Default(); err == nil {
+		for _, p := range g.Places() {
+			if seen[p.Name] {
+				continue
+			}
+
+			options = append(options, huh.NewOption(p.Label(), p.City()))
+		}
 	}
 
 	f := huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[nws.City]().
-				Title("Choose a city to fetch the weather for").
+				Title("Choose a city to fetch the weather for (type to filter)").
 				Options(options...).
+				Filtering(true).
+				Height(12).
 				Value(&selected),
 		),
 	)
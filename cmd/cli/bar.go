@@ -0,0 +1,207 @@
+// Submodule bar prints the current conditions for status bars, which run
+// geocast every minute or so (or, for i3bar, keep it running). The weather is
+// cached for FORECAST_TTL and the location for a day, to keep those runs from
+// hammering weather.gov and Nominatim.
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// Default lifetime of the weather cached for status bars.
+const defaultForecastTTL time.Duration = 10 * time.Minute
+
+// How often the i3bar status line is updated by default. Updates within
+// FORECAST_TTL use the cached weather.
+const defaultBarInterval time.Duration = time.Minute
+
+func barFormatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Usage:   "Status bar format: " + strings.Join(view.BarFormats, ", ") + ".",
+		Value:   view.BarText,
+	}
+}
+
+func barIntervalFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "How often to update the i3bar status line.",
+		Value: defaultBarInterval,
+	}
+}
+
+// func fetchBar fetches the current conditions and active alerts. Alerts are
+// optional, so failing to fetch them only logs a warning.
+func fetchBar(w *nws.WeatherClient, city nws.City) (view.Bar, error) {
+	obs, err := w.GetConditions(city)
+
+	if err != nil {
+		return view.Bar{}, err
+	}
+
+	b := view.Bar{Conditions: view.NewConditions(city, w.Units(), *obs), Alerts: []view.Alert{}}
+
+	alerts, err := w.GetAlerts(city)
+
+	if err != nil {
+		w.Log.Warn(fmt.Sprintf("Could not fetch alerts: %s", err.Error()))
+	} else {
+		b.Alerts = view.NewAlerts(city, alerts.Alerts()).Alerts
+	}
+
+	return b, nil
+}
+
+// func weatherFetcher caches weather for FORECAST_TTL in CACHE_DIR. The base
+// URL is the tag so that switching NWS_URL (e.g. to a proxy) does not reuse
+// weather from another server.
+func weatherFetcher(w *nws.WeatherClient, refresh bool, config *conf) cache.Fetcher {
+	f := cache.Fetcher{
+		TTL:     cacheTTL(config, "FORECAST_TTL", defaultForecastTTL),
+		Tag:     w.BaseURL(),
+		Refresh: refresh,
+		Log:     config.log,
+	}
+
+	if config.metrics != nil {
+		f.Observe = config.metrics.ObserveCache
+	}
+
+	if f.TTL <= 0 {
+		return f
+	}
+
+	store, err := cacheStore(config)
+
+	if err != nil {
+		config.log.Debug(fmt.Sprintf("Weather cache disabled: %s", err.Error()))
+
+		return f
+	}
+
+	f.Store = store
+
+	return f
+}
+
+// func cachedBar returns the weather for a city, cached for FORECAST_TTL.
+func cachedBar(w *nws.WeatherClient, city nws.City, ctx *cli.Context, config *conf) (view.Bar, error) {
+	key := fmt.Sprintf("bar-%.4f,%.4f-%s", city.Lat, city.Long, w.Units())
+
+	return cache.Fetch(weatherFetcher(w, ctx.Bool("refresh"), config), key, func() (view.Bar, error) {
+		return fetchBar(w, city)
+	})
+}
+
+// BarCommand defines a pointer to the bar command.
+//
+// Usage: geocast bar --format waybar [location flags]
+//
+// Errors are logged to stderr and shown as a placeholder in the bar, so the
+// command only fails for an unknown format. With --format i3bar, geocast is
+// the bar's status_command and keeps running, printing a status line every
+// --interval until SIGINT or SIGTERM.
+func BarCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:      "bar",
+		Usage:     "Print the current conditions for a status bar: Waybar, i3bar, i3blocks, Polybar or tmux.",
+		UsageText: "geocast bar [--f]ormat waybar|i3bar|i3blocks|polybar|tmux [--interval 1m] [--c]ity [--ip] [--z]ip [--p]t [--place]",
+		Args:      true,
+		Flags:     append(flags(), barFormatFlag(), barIntervalFlag()),
+		Before:    before(config),
+		Action: func(ctx *cli.Context) error {
+			format := ctx.String("format")
+
+			if !slices.Contains(view.BarFormats, format) {
+				return fmt.Errorf("unknown bar format %q (expected %s)", format, strings.Join(view.BarFormats, ", "))
+			}
+
+			// The bar reads stdout, so log messages go to stderr.
+			config.log.SetOutput(logger.Writer(os.Stderr))
+
+			if format == view.BarI3bar {
+				return streamI3bar(ctx, config)
+			}
+
+			return writeBar(ctx, config, format)
+		},
+	}
+}
+
+// func streamI3bar writes the i3bar protocol header, then a status line every
+// --interval until SIGINT or SIGTERM, or until i3bar stops reading.
+func streamI3bar(ctx *cli.Context, config *conf) error {
+	stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := view.WriteI3barHeader(ctx.App.Writer); err != nil {
+		return err
+	}
+
+	for {
+		if err := writeBar(ctx, config, view.BarI3bar); err != nil {
+			return err
+		}
+
+		select {
+		case <-stop.Done():
+			return nil
+		case <-time.After(ctx.Duration("interval")):
+		}
+	}
+}
+
+// func writeBar writes the weather in a status bar format, or a placeholder
+// when it can't be fetched.
+func writeBar(ctx *cli.Context, config *conf, format string) error {
+	b, err := bar(ctx, config)
+
+	if err != nil {
+		config.log.Error(err.Error())
+
+		return view.WriteBarError(ctx.App.Writer, format, err)
+	}
+
+	return view.WriteBar(ctx.App.Writer, format, b)
+}
+
+// func bar locates the city and returns its (cached) weather. Cities and
+// points are geocoded through the cache, so that bars don't look them up on
+// Nominatim on every run.
+func bar(ctx *cli.Context, config *conf) (view.Bar, error) {
+	w, err := newWeatherClient(ctx, config)
+
+	if err != nil {
+		return view.Bar{}, err
+	}
+
+	loc, err := resolveLocation(ctx)
+
+	if err != nil {
+		return view.Bar{}, err
+	}
+
+	city, err := cachedGeocode(geocodeFetcher(ctx, config), loc, func() (*nws.City, error) {
+		return locate(ctx, config)
+	})
+
+	if err != nil {
+		return view.Bar{}, err
+	}
+
+	return cachedBar(w, *city, ctx, config)
+}
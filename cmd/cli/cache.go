@@ -0,0 +1,77 @@
+// Submodule cache opens the cache directory and reads the lifetimes of cached
+// values from the settings.
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/urfave/cli/v2"
+)
+
+// Lifetime of the cities and points geocoded by the server and status bars,
+// which would otherwise be looked up on Nominatim for every request or run.
+const geocodeTTL time.Duration = 24 * time.Hour
+
+// func cacheStore returns the store in CACHE_DIR, or in the user cache
+// directory by default.
+func cacheStore(config *conf) (*cache.Store, error) {
+	if dir := config.Get("CACHE_DIR"); dir != "" {
+		return cache.New(dir), nil
+	}
+
+	return cache.Default()
+}
+
+// func cacheTTL reads a lifetime setting such as LOCATION_TTL, using def when
+// it is unset or invalid. Zero disables the cache.
+func cacheTTL(config *conf, key string, def time.Duration) time.Duration {
+	s := config.Get(key)
+
+	if s == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(s)
+
+	if err != nil {
+		config.log.Warn(fmt.Sprintf("Invalid %s %q, using %s.", key, s, def))
+
+		return def
+	}
+
+	return d
+}
+
+// func geocodeFetcher caches geocoded cities and points in CACHE_DIR for
+// geocodeTTL. The geocoder is the tag so that switching --geocoder does not
+// reuse its results.
+func geocodeFetcher(ctx *cli.Context, config *conf) cache.Fetcher {
+	f := cache.Fetcher{TTL: geocodeTTL, Tag: ctx.String("geocoder"), Refresh: ctx.Bool("refresh"), Log: config.log}
+
+	if config.metrics != nil {
+		f.Observe = config.metrics.ObserveCache
+	}
+
+	if store, err := cacheStore(config); err == nil {
+		f.Store = store
+	}
+
+	return f
+}
+
+// func cachedGeocode geocodes a city or point through the fetcher. Other
+// locations are geocoded from local data (or cached by the geolocator), so
+// they are resolved directly.
+func cachedGeocode(f cache.Fetcher, loc location, fn func() (*nws.City, error)) (*nws.City, error) {
+	if loc.kind != locationCity && loc.kind != locationPoint {
+		return fn()
+	}
+
+	key := fmt.Sprintf("geocode-%s-%s", loc.kind, strings.ToLower(loc.value))
+
+	return cache.Fetch(f, key, fn)
+}
@@ -3,37 +3,44 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/desertthunder/weather/internal/ipinfo"
-	"github.com/desertthunder/weather/internal/nominatim"
 	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/transport"
 	"github.com/desertthunder/weather/internal/view"
 	"github.com/urfave/cli/v2"
 )
 
-// DefaultAction is the function called when no arguments are provided or when
-// the "me" argument is provided.
+// DefaultAction is the function called by the root and forecast commands.
 //
-// The default action is to first geocode the current device's IP address and
-// then fetch the weather forecast for the city.
-func DefaultAction(i *ipinfo.IPInfoClient, n *nominatim.Nominatim, nwsc *nws.WeatherClient, ctx *cli.Context) {
-	city := geocode(i, n, ctx)
-
-	if city == nil {
-		err := errors.New("no results found for the provided city name")
+// The default action is to geocode the location given as an argument or with
+// a flag (or the current device's IP address, when there is none or the "me"
+// argument is provided) and then fetch the weather forecast for the city.
+func DefaultAction(i ipinfo.Geolocator, n Geocoder, nwsc *nws.WeatherClient, ctx *cli.Context) error {
+	city, err := geocode(i, n, ctx, nwsc.Log)
+
+	if err != nil {
+		return err
+	}
 
-		i.Log.Error(err.Error())
+	return forecast(city, nwsc, ctx)
+}
 
-		return
-	}
+// func before is the Before hook for commands with flags. It applies the
+// config file and environment to the flags and selects the output format and
+// color theme.
+func before(config *conf) cli.BeforeFunc {
+	return func(ctx *cli.Context) error {
+		if err := config.apply(ctx); err != nil {
+			return err
+		}
 
-	view.CityLine(city)
+		setOutput(ctx, config.log)
 
-	forecast(city, nwsc, ctx)
+		return view.SetTheme(ctx.String("theme"))
+	}
 }
 
 // func Application acts as a constant and is the entry point for the application.
@@ -42,12 +49,29 @@ func Application() *cli.App {
 
 	logger := config.log
 
+	transport.Install(logger)
+
 	return &cli.App{
 		Name:     "geocast",
 		HelpName: "geocast (Geo[coding] + [Fore]cast)",
 		Usage:    "Location aware weather forecasts for the command line.",
-		UsageText: `geocast f[orecast] [--c]ity [--ip] [--p]t [--i]nteractive
-geocast g[eocode] [--c]ity [--ip] [--p]t
+		UsageText: `geocast [flags] [me|city|zip|lat,lon|ip|@place]
+geocast [--profile name] f[orecast] [--c]ity [--ip] [--z]ip [--p]t [--place] [--gps] [--i]nteractive [--geocoder nominatim|offline]
+geocast alerts|now [--c]ity [--ip] [--z]ip [--p]t [--place]
+geocast [--o]utput text|json|ndjson|yaml|csv|tsv|markdown <command>
+geocast g[eocode] [--c]ity [--ip] [--z]ip [--p]t [--gps] [--geocoder nominatim|offline]
+geocast g[eocode] zip <zip>
+geocast bar [--f]ormat waybar|i3bar|i3blocks|polybar|tmux [--interval 1m]
+geocast export ics [--file forecast.ics]
+geocast serve [--addr :8080] [--cors-origin origin] [--metrics]
+geocast exporter [--addr :9876] [--interval 1m]
+geocast publish mqtt [--broker tcp://localhost:1883] [--topic geocast/home]
+geocast notify --webhook https://hooks.slack.com/services/... [--threshold temperature>=100]
+geocast proxy [--addr :8081] [--upstream url]
+geocast ip lookup [--json] <ip...>
+geocast places add|list|remove|rename|default
+geocast @<place>
+geocast config list|get|set|unset|path|validate
 geocast i[nteractive]`,
 		Description: `Geocast is a command line utility that provides location aware weather forecasts.
 It can be used to fetch the weather forecast for a specific city, latitude and
@@ -56,10 +80,23 @@ longitude, or the current device's IP address.`,
 		// Global flags for the application , i.e. the flags that apply to all commands.
 		//
 		// City, IP, and Point flags
-		Flags: flags(),
+		Flags:  append(flags(), profileFlag()),
+		Before: before(config),
 		Commands: []*cli.Command{
 			ForecastCommand(config),
+			AlertsCommand(config),
+			ConditionsCommand(config),
 			GeocodeCommand(config),
+			BarCommand(config),
+			ExportCommand(config),
+			ServeCommand(config),
+			ExporterCommand(config),
+			PublishCommand(config),
+			NotifyCommand(config),
+			ProxyCommand(config),
+			IPCommand(config),
+			PlacesCommand(config),
+			ConfigCommand(config),
 			InteractiveCommand(config),
 		},
 		Action: func(ctx *cli.Context) error {
@@ -69,61 +106,43 @@ longitude, or the current device's IP address.`,
 			logger.Debug(fmt.Sprintf("Flags: %s", flags))
 			logger.Debug(fmt.Sprintf("Arg: %s", arg))
 
-			ipc := ipinfo.NewIPInfoClient(config.Get("IPINFO_TOKEN"))
-			n := nominatim.Client()
-			nwsc := nws.NewWeatherClient()
-
-			nwsc.SetLogger(logger)
-			ipc.SetLogger(logger)
-
-			app := ctx.Bool("interactive")
+			nwsc, err := newWeatherClient(ctx, config)
 
-			if strings.ToLower(arg) == "me" || ctx.Args().Len() == 0 {
-				logger.Debug("Default command invoked.")
-
-				if app {
-					city := geocode(ipc, n, ctx)
-
-					if city == nil {
-						err := errors.New("no results found for the provided city name")
-
-						logger.Error(err.Error())
-
-						return err
-					} else {
-						view.CityLine(city)
-
-						interactive(*city)
-
-						return nil
-					}
-				}
-
-				DefaultAction(ipc, n, nwsc, ctx)
-
-				return nil
+			if err != nil {
+				return err
 			}
 
-			// Geocode the city.
-			city, err := n.GeocodeByCity(arg)
+			ipc, err := newGeolocator(ctx, config)
 
 			if err != nil {
 				logger.Error(err.Error())
 
 				return err
-			} else if city != nil {
-				err = errors.New("no results found for the provided city name")
+			}
+
+			n, err := newGeocoder(ctx, logger)
 
+			if err != nil {
 				logger.Error(err.Error())
 
 				return err
 			}
 
-			view.CityLine(city)
+			if ctx.Bool("interactive") {
+				city, err := geocode(ipc, n, ctx, logger)
+
+				if err != nil {
+					return err
+				}
+
+				view.CityLine(city)
 
-			forecast(city, nwsc, ctx)
+				interactive(*city)
+
+				return nil
+			}
 
-			return nil
+			return DefaultAction(ipc, n, nwsc, ctx)
 		},
 	}
 }
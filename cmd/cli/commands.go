@@ -3,73 +3,113 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
-	"time"
+	"strings"
 
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/gpsd"
 	"github.com/desertthunder/weather/internal/ipinfo"
-	"github.com/desertthunder/weather/internal/nominatim"
 	"github.com/desertthunder/weather/internal/nws"
 	"github.com/desertthunder/weather/internal/view"
 	"github.com/urfave/cli/v2"
 )
 
-func geocode(i *ipinfo.IPInfoClient, n *nominatim.Nominatim, ctx *cli.Context) *nws.City {
-	pt := ctx.StringSlice("pt")
-	c := ctx.String("city")
-	ip := ctx.String("ip")
+// func geocode returns the city for the location given as an argument or
+// with a flag (see resolveLocation), or locates the device.
+func geocode(i ipinfo.Geolocator, n Geocoder, ctx *cli.Context, logger *log.Logger) (*nws.City, error) {
+	loc, err := resolveLocation(ctx)
 
-	var city *nws.City
-	var err error
-	var ipc ipinfo.IPInfoResponse
+	if err != nil {
+		return nil, err
+	}
 
-	if len(pt) > 0 {
-		lat, _ := strconv.ParseFloat(pt[0], 64)
-		lng, _ := strconv.ParseFloat(pt[1], 64)
+	logger.Debug(fmt.Sprintf("Location: %s %s", loc.kind, loc.value))
 
-		city, err = n.GeocodeByPoint(lat, lng)
-	} else if c != "" {
-		city, err = n.GeocodeByCity(c)
+	if loc.kind != locationDevice && loc.kind != locationIP {
+		return geocodeResolved(n, ctx, loc, logger)
 	}
 
-	if err != nil {
-		i.Log.Error(err.Error())
+	// The default saved place stands in for the device's location, unless
+	// the device was asked for explicitly ("me", --ip or --gps).
+	if loc.kind == locationDevice && loc.value == "" && !ctx.Bool("gps") {
+		if p, ok := defaultPlace(logger); ok {
+			logger.Debug(fmt.Sprintf("Using the default place %s.", p.Name))
+
+			city := p.City()
 
-		return nil
-	} else if city != nil {
-		return city
+			return &city, nil
+		}
+	}
+
+	return geolocate(i, n, ctx, loc.ip(), logger)
+}
+
+// func geolocate locates the device with gpsd (when --gps is given) or an
+// IP address, falling back to the device's IP address when ip is empty.
+func geolocate(i ipinfo.Geolocator, n Geocoder, ctx *cli.Context, ip string, logger *log.Logger) (*nws.City, error) {
+	var ipc ipinfo.IPInfoResponse
+	var err error
+
+	if ctx.Bool("gps") && ip == "" {
+		city, err := geocodeGPS(n, ctx, logger)
+
+		if err == nil {
+			return city, nil
+		}
+
+		logger.Warn(fmt.Sprintf("%s, falling back to IP geolocation.", err.Error()))
 	}
 
 	if ip == "" {
-		i.Log.Debug("No IP address provided, will attempt to use device IP.")
+		logger.Debug("No IP address provided, will attempt to use device IP.")
 
 		ipc, err = i.Geolocate(nil)
 
 	} else {
-		i.Log.Debug(fmt.Sprintf("Set params to ip: %s", ip))
+		logger.Debug(fmt.Sprintf("Set params to ip: %s", ip))
 
 		ipc, err = i.Geolocate(&ip)
 	}
 
 	if err != nil {
-		i.Log.Error(err.Error())
+		return nil, err
+	}
+
+	city, err := ipc.BuildCity()
 
-		return nil
+	if err != nil {
+		return nil, err
 	}
 
-	cityV := ipc.BuildCity()
+	return &city, nil
+}
+
+// func geocodeGPS waits for a fix from gpsd and reverse geocodes it.
+func geocodeGPS(n Geocoder, ctx *cli.Context, logger *log.Logger) (*nws.City, error) {
+	g := gpsd.NewClient()
+	g.SetLogger(logger)
+	g.Address = ctx.String("gpsd")
+	g.Timeout = ctx.Duration("gps-timeout")
+	g.Accuracy = ctx.Float64("gps-accuracy")
 
-	return &cityV
+	tpv, err := g.Fix()
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(fmt.Sprintf("GPS fix %f,%f from %s (±%.0f m).", tpv.Lat, tpv.Lon, tpv.Device, tpv.Accuracy()))
+
+	return n.GeocodeByPoint(tpv.Lat, tpv.Lon)
 }
 
 // func forecast defines the shared functionality for the forecast command.
-func forecast(city *nws.City, w *nws.WeatherClient, ctx *cli.Context) {
+func forecast(city *nws.City, w *nws.WeatherClient, ctx *cli.Context) error {
 	forecast, err := w.GetWeather(*city)
 
 	if err != nil {
-		w.Log.Error(err.Error())
-
-		return
+		return err
 	}
 
 	v := ctx.Int("verbosity")
@@ -80,22 +120,54 @@ func forecast(city *nws.City, w *nws.WeatherClient, ctx *cli.Context) {
 
 	w.Log.Debug(fmt.Sprintf("Extended: %t", extended))
 
-	for _, period := range forecast.Properties.Periods {
-		view.ForecastLine(period, v)
+	periods := forecast.Properties.Periods
 
-		if extended {
-			time.Sleep(time.Millisecond * 500)
-		} else {
-			break
-		}
+	if !extended && len(periods) > 1 {
+		periods = periods[:1]
+	}
+
+	return render(ctx, view.NewForecast(*city, w.Units(), periods))
+}
+
+// func newWeatherClient builds the weather.gov client in the selected units.
+func newWeatherClient(ctx *cli.Context, config *conf) (*nws.WeatherClient, error) {
+	w := nws.NewWeatherClient()
+	w.SetLogger(config.log)
+
+	if uri := config.Get("nws_url"); uri != "" {
+		w.SetURL(strings.TrimSuffix(uri, "/"))
+	}
+
+	if err := w.SetUnits(ctx.String("units")); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// func locate geocodes the location given on the command line, or locates
+// the device.
+func locate(ctx *cli.Context, config *conf) (*nws.City, error) {
+	i, err := newGeolocator(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := newGeocoder(ctx, config.log)
+
+	if err != nil {
+		return nil, err
 	}
+
+	return geocode(i, n, ctx, config.log)
 }
 
-// func ForecastCommand defines a pointer to the forecast command.
+// GeocodeCommand defines a pointer to the geocode command.
 //
-// Usage: geocast g[eocode] [-city]
+// Usage: geocast g[eocode] [--c]ity [--ip] [--z]ip [--p]t [--place] [city|zip|lat,lon|ip|@place]
 //
-// If no city is provided, the current IP address is used.
+// If no location is provided, the current IP address is used.
 func GeocodeCommand(config *conf) *cli.Command {
 	return &cli.Command{
 		Name: "geocode",
@@ -103,22 +175,42 @@ func GeocodeCommand(config *conf) *cli.Command {
 			"g",
 			"gc",
 		},
-		Category: "Core",
-		Usage:    "Geocode a city or IP address, or reverse geocode a latitude and longitude.",
+		Category:  "Core",
+		Usage:     "Geocode a city or IP address, or reverse geocode a latitude and longitude.",
+		UsageText: "geocast g[eocode] [--c]ity [--ip] [--z]ip [--p]t [--place] [me|city|zip|lat,lon|ip|@place]",
+		Args:      true,
+		Flags:     flags(),
+		Before:    before(config),
+		Subcommands: []*cli.Command{
+			{
+				Name:      "zip",
+				Usage:     "Geocode a US ZIP code.",
+				UsageText: "geocast geocode zip 78701",
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() == 0 {
+						return errors.New("a ZIP code is required")
+					}
+
+					city, err := geocodeZIP(ctx.Args().First(), ctx, config.log)
+
+					if err != nil {
+						config.log.Error(err.Error())
+
+						return err
+					}
+
+					return render(ctx, view.NewLocation(*city))
+				},
+			},
+		},
 		Action: func(ctx *cli.Context) error {
-			i := ipinfo.NewIPInfoClient(config.Get("IPINFO_TOKEN"))
-			n := nominatim.Client()
-			i.SetLogger(config.log)
+			city, err := locate(ctx, config)
 
-			city := geocode(i, n, ctx)
-
-			if city == nil {
-				return nil
+			if err != nil {
+				return err
 			}
 
-			view.CityLine(city)
-
-			return nil
+			return render(ctx, view.NewLocation(*city))
 		},
 	}
 }
@@ -131,19 +223,99 @@ func ForecastCommand(config *conf) *cli.Command {
 			"f",
 		},
 		Usage:     "Fetch the weather forecast.",
-		UsageText: "geocast f[orecast] [--c]ity [--i]p [--p]t",
+		UsageText: "geocast f[orecast] [--c]ity [--i]p [--z]ip [--p]t [me|city|zip|lat,lon|ip|@place]",
 		Args:      true,
 		Flags:     flags(),
+		Before:    before(config),
 		Action: func(ctx *cli.Context) error {
-			i := ipinfo.NewIPInfoClient(config.Get("IPINFO_TOKEN"))
-			n := nominatim.Client()
-			w := nws.NewWeatherClient()
-			i.SetLogger(config.log)
-			w.SetLogger(config.log)
+			w, err := newWeatherClient(ctx, config)
 
-			DefaultAction(i, n, w, ctx)
+			if err != nil {
+				return err
+			}
 
-			return nil
+			i, err := newGeolocator(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			n, err := newGeocoder(ctx, config.log)
+
+			if err != nil {
+				return err
+			}
+
+			return DefaultAction(i, n, w, ctx)
+		},
+	}
+}
+
+// AlertsCommand defines a pointer to the alerts command.
+func AlertsCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:      "alerts",
+		Usage:     "Fetch the active weather alerts.",
+		UsageText: "geocast alerts [--c]ity [--ip] [--z]ip [--p]t [--place]",
+		Args:      true,
+		Flags:     flags(),
+		Before:    before(config),
+		Action: func(ctx *cli.Context) error {
+			w, err := newWeatherClient(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			city, err := locate(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			alerts, err := w.GetAlerts(*city)
+
+			if err != nil {
+				return err
+			}
+
+			return render(ctx, view.NewAlerts(*city, alerts.Alerts()))
+		},
+	}
+}
+
+// ConditionsCommand defines a pointer to the conditions command.
+func ConditionsCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name: "conditions",
+		Aliases: []string{
+			"now",
+		},
+		Usage:     "Fetch the current conditions from the nearest observation station.",
+		UsageText: "geocast now [--c]ity [--ip] [--z]ip [--p]t [--place]",
+		Args:      true,
+		Flags:     flags(),
+		Before:    before(config),
+		Action: func(ctx *cli.Context) error {
+			w, err := newWeatherClient(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			city, err := locate(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			obs, err := w.GetConditions(*city)
+
+			if err != nil {
+				return err
+			}
+
+			return render(ctx, view.NewConditions(*city, w.Units(), *obs))
 		},
 	}
 }
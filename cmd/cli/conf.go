@@ -1,41 +1,457 @@
 // Submodule conf defines a wrapper around viper to manage settings.
+//
+// Settings are read, in order of precedence, from command line flags,
+// environment variables, the selected profile, the top level of the config
+// file and finally the built-in defaults. A .env file in the working directory
+// is still read, on top of the config file, for backwards compatibility.
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/fuzzy"
 	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/metrics"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/viper"
+	"github.com/urfave/cli/v2"
 )
 
+// Name of the config file in $XDG_CONFIG_HOME/geocast.
+const configFile string = "config.toml"
+
 // struct conf is a wrapper around the viper package, used for configuration
 // management.
 type conf struct {
 	v   *viper.Viper
 	log *log.Logger
+	// Path of the config file, which may not exist.
+	path string
+	// Contents of the config file, including its profiles.
+	file map[string]any
+	// Contents of ./.env, if present.
+	dotenv map[string]any
+	// Name of the selected profile.
+	profile string
+	// Flags that were set from the configuration rather than the command line.
+	applied map[string]bool
+	// Client metrics, set by the commands that serve /metrics.
+	metrics *metrics.Client
+}
+
+// func ConfigPath returns the path of the config file: $GEOCAST_CONFIG, or
+// config.toml in $XDG_CONFIG_HOME/geocast (~/.config/geocast by default).
+func ConfigPath() string {
+	if path := os.Getenv("GEOCAST_CONFIG"); path != "" {
+		return path
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return filepath.Join(".config", "geocast", configFile)
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "geocast", configFile)
 }
 
 // func Config is the conf constructor.
 //
-// It reads the configuration from the .env file and returns a pointer to an
-// instance of conf.
+// It reads the config file (if there is one) and returns a pointer to an
+// instance of conf. Missing files are not an error; invalid ones are reported
+// and ignored.
 func Config() *conf {
-	v := viper.New()
-	v.SetConfigFile(".env")
+	c := &conf{path: ConfigPath(), file: map[string]any{}, dotenv: map[string]any{}, applied: map[string]bool{}}
+	c.log = logger.Init()
 
-	if err := v.ReadInConfig(); err != nil {
-		fmt.Printf("Error reading config file, %s\n", err)
+	if err := c.load(); err != nil {
+		c.log.Warn(err.Error())
 	}
 
-	c := &conf{v: v}
+	c.profile = os.Getenv("GEOCAST_PROFILE")
 
-	c.log = logger.Init()
+	if c.profile == "" {
+		c.profile, _ = c.file["profile"].(string)
+	}
+
+	if err := c.build(); err != nil {
+		c.log.Warn(err.Error())
+
+		c.profile = ""
+		c.build()
+	}
 
 	return c
 }
 
+// func load reads the config file and ./.env.
+func (c *conf) load() error {
+	errs := []error{}
+
+	if _, err := os.Stat(c.path); err == nil {
+		f := viper.New()
+		f.SetConfigFile(c.path)
+		f.SetConfigType("toml")
+
+		if err := f.ReadInConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("ignoring invalid config file %s: %w", c.path, err))
+		} else {
+			c.file = f.AllSettings()
+		}
+	}
+
+	if _, err := os.Stat(".env"); err == nil {
+		d := viper.New()
+		d.SetConfigFile(".env")
+		d.SetConfigType("env")
+
+		if err := d.ReadInConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("ignoring invalid .env file: %w", err))
+		} else {
+			c.dotenv = d.AllSettings()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// func profiles returns the profiles defined in the config file.
+func (c *conf) profiles() map[string]map[string]any {
+	out := map[string]map[string]any{}
+
+	profiles, _ := c.file["profiles"].(map[string]any)
+
+	for name, p := range profiles {
+		if values, ok := p.(map[string]any); ok {
+			out[name] = values
+		}
+	}
+
+	return out
+}
+
+// func layer returns the file values for the selected profile: the top level
+// of the config file, overridden by the profile, overridden by ./.env.
+func (c *conf) layer() (map[string]any, error) {
+	values := map[string]any{}
+
+	for k, v := range c.file {
+		if k != "profiles" && k != "profile" {
+			values[k] = v
+		}
+	}
+
+	if c.profile != "" {
+		p, ok := c.profiles()[c.profile]
+
+		if !ok {
+			return values, fmt.Errorf("profile %q is not defined in %s", c.profile, c.path)
+		}
+
+		for k, v := range p {
+			values[k] = v
+		}
+	}
+
+	for k, v := range c.dotenv {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+// func build creates the viper instance for the selected profile.
+func (c *conf) build() error {
+	values, err := c.layer()
+
+	v := viper.New()
+
+	for _, s := range settings {
+		v.BindEnv(s.key, s.env)
+
+		if s.def != "" {
+			v.SetDefault(s.key, s.def)
+		}
+	}
+
+	v.AutomaticEnv()
+	v.MergeConfigMap(values)
+
+	c.v = v
+	c.registerSecrets()
+
+	return err
+}
+
+// func UseProfile selects a profile defined in the config file.
+func (c *conf) UseProfile(name string) error {
+	if name == c.profile {
+		return nil
+	}
+
+	previous := c.profile
+	c.profile = name
+
+	if err := c.build(); err != nil {
+		c.profile = previous
+		c.build()
+
+		return err
+	}
+
+	c.log.Debug(fmt.Sprintf("Using profile %s.", name))
+
+	return nil
+}
+
+// Suffixes of configuration keys and environment variables holding secrets.
+// Webhook URLs embed their credentials.
+var secretSuffixes = []string{"TOKEN", "KEY", "SECRET", "PASSWORD", "WEBHOOKS"}
+
+func isSecret(key string) bool {
+	key = strings.ToUpper(key)
+
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// func registerSecrets redacts the values of secret-looking configuration
+// keys and environment variables (e.g. IPINFO_TOKEN) from all log output.
+func (c *conf) registerSecrets() {
+	layers := []map[string]any{c.file, c.dotenv}
+
+	for _, p := range c.profiles() {
+		layers = append(layers, p)
+	}
+
+	for _, values := range layers {
+		for key, value := range values {
+			if isSecret(key) {
+				logger.AddSecret(fmt.Sprint(value))
+			}
+		}
+	}
+
+	for _, env := range os.Environ() {
+		if key, value, ok := strings.Cut(env, "="); ok && isSecret(key) {
+			logger.AddSecret(value)
+		}
+	}
+}
+
 // Get is the accessor method for any configuration values/environment variables.
 func (c *conf) Get(key string) string {
 	return c.v.GetString(key)
 }
+
+// func Source returns the effective value of a setting and where it came
+// from: a flag given on the command line, the environment, the config file
+// (or .env) or the built-in default.
+func (c *conf) Source(ctx *cli.Context, s setting) (string, string) {
+	if s.flag != "" && !c.applied[s.flag] {
+		for _, p := range ctx.Lineage() {
+			if p.Command != nil && definesFlag(p, s.flag) && setLocally(p, s.flag) {
+				return fmt.Sprint(p.Value(s.flag)), sourceFlag
+			}
+		}
+	}
+
+	return c.lookup(s)
+}
+
+// func section returns the top level of the config file, or the table of a
+// profile, creating it if needed.
+func (c *conf) section(profile string) map[string]any {
+	if profile == "" {
+		return c.file
+	}
+
+	profiles, ok := c.file["profiles"].(map[string]any)
+
+	if !ok {
+		profiles = map[string]any{}
+		c.file["profiles"] = profiles
+	}
+
+	p, ok := profiles[profile].(map[string]any)
+
+	if !ok {
+		p = map[string]any{}
+		profiles[profile] = p
+	}
+
+	return p
+}
+
+// func Set validates a value and saves it to the config file, in a profile
+// if one is given.
+func (c *conf) Set(key, value, profile string) error {
+	s, ok := lookupSetting(key)
+
+	if !ok {
+		return unknownKey(key)
+	}
+
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return fmt.Errorf("invalid %s: %w", s.key, err)
+		}
+	}
+
+	var v any = value
+
+	if n, err := strconv.Atoi(value); err == nil && s.key == "verbosity" {
+		v = n
+	}
+
+	c.section(profile)[s.key] = v
+
+	return c.save()
+}
+
+// func Unset removes a value from the config file (or a profile), returning
+// whether it was set.
+func (c *conf) Unset(key, profile string) (bool, error) {
+	s, ok := lookupSetting(key)
+
+	if !ok {
+		return false, unknownKey(key)
+	}
+
+	section := c.section(profile)
+
+	if _, ok := section[s.key]; !ok {
+		return false, nil
+	}
+
+	delete(section, s.key)
+
+	return true, c.save()
+}
+
+// func save writes the config file. Comments in the file are not preserved.
+func (c *conf) save() error {
+	data, err := toml.Marshal(c.file)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	// The file may hold API tokens.
+	if err := os.WriteFile(c.path, data, 0o600); err != nil {
+		return err
+	}
+
+	return c.build()
+}
+
+// func unknownKey returns an error for a key that is not a setting,
+// suggesting the closest one.
+func unknownKey(key string) error {
+	keys := []string{}
+
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+
+	if suggestions := fuzzy.Suggest(strings.ToLower(key), keys, 1); len(suggestions) > 0 {
+		return fmt.Errorf("unknown setting %q (did you mean %s?)", key, suggestions[0])
+	}
+
+	return fmt.Errorf("unknown setting %q (see geocast config list)", key)
+}
+
+// func Validate checks every effective setting and the keys in the config
+// file, returning one error per problem.
+func (c *conf) Validate(ctx *cli.Context) []error {
+	errs := []error{}
+
+	for _, s := range settings {
+		value, source := c.Source(ctx, s)
+
+		// Values from the file are checked below, including those in
+		// profiles that aren't active.
+		if s.validate == nil || source == sourceDefault || source == sourceFile || value == "" {
+			continue
+		}
+
+		if err := s.validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", s.key, source, err))
+		}
+	}
+
+	sections := map[string]map[string]any{"": c.file}
+	names := []string{""}
+
+	for name, p := range c.profiles() {
+		sections[name] = p
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		section := sections[name]
+		keys := []string{}
+
+		for key := range section {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			if name == "" && (key == "profiles" || key == "profile") {
+				continue
+			}
+
+			var err error
+
+			if s, ok := lookupSetting(key); !ok {
+				err = unknownKey(key)
+			} else if s.validate == nil {
+				continue
+			} else if err = s.validate(fmt.Sprint(section[key])); err != nil {
+				err = fmt.Errorf("%s: %w", key, err)
+			} else {
+				continue
+			}
+
+			if name != "" {
+				err = fmt.Errorf("profile %s: %w", name, err)
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", c.path, err))
+		}
+	}
+
+	if name, ok := c.file["profile"].(string); ok {
+		if _, defined := c.profiles()[name]; !defined {
+			errs = append(errs, fmt.Errorf("%s: default profile %q is not defined", c.path, name))
+		}
+	}
+
+	return errs
+}
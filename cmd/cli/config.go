@@ -0,0 +1,181 @@
+// Submodule config provides the "geocast config" commands for inspecting and
+// editing settings.
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// struct settingValue is a row of geocast config list in the json and yaml
+// output formats.
+type settingValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// func displayValue hides secrets unless reveal is set.
+func displayValue(s setting, value string, reveal bool) string {
+	if value != "" && isSecret(s.key) && !reveal {
+		return logger.Redacted
+	}
+
+	return value
+}
+
+// func profileTarget returns the profile that set and unset write to.
+func profileTarget(ctx *cli.Context) string {
+	return ctx.String("profile")
+}
+
+// func ConfigCommand defines a pointer to the config command.
+//
+// Usage: geocast config list|get|set|unset|path|validate
+func ConfigCommand(config *conf) *cli.Command {
+	targetFlag := &cli.StringFlag{
+		Name:  "profile",
+		Usage: "Write to a profile instead of the top level of the config file.",
+	}
+
+	revealFlag := &cli.BoolFlag{
+		Name:  "reveal",
+		Usage: "Show tokens and other secrets.",
+	}
+
+	return &cli.Command{
+		Name:     "config",
+		Category: "Settings",
+		Usage:    "Inspect and edit settings.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Aliases:   []string{"ls"},
+				Usage:     "List the effective settings and where each value comes from (default, file, env or flag).",
+				UsageText: "geocast [--profile name] config list [--reveal]",
+				Flags:     []cli.Flag{revealFlag},
+				Action: func(ctx *cli.Context) error {
+					l := view.List{Headers: []string{"Key", "Value", "Source"}}
+
+					for _, s := range settings {
+						value, source := config.Source(ctx, s)
+
+						if source == sourceFile && config.profile != "" {
+							source = fmt.Sprintf("file (%s)", config.profile)
+						}
+
+						value = displayValue(s, value, ctx.Bool("reveal"))
+
+						l.Data = append(l.Data, []string{s.key, value, source})
+						l.Values = append(l.Values, settingValue{s.key, value, source})
+					}
+
+					return render(ctx, l)
+				},
+			},
+			{
+				Name:      "get",
+				Usage:     "Print the effective value of a setting.",
+				UsageText: "geocast config get [--reveal] units",
+				Flags:     []cli.Flag{revealFlag},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() != 1 {
+						return errors.New("expected a setting name, e.g. geocast config get units")
+					}
+
+					s, ok := lookupSetting(ctx.Args().First())
+
+					if !ok {
+						return unknownKey(ctx.Args().First())
+					}
+
+					value, source := config.Source(ctx, s)
+
+					config.log.Debug(fmt.Sprintf("%s is set by %s.", s.key, source))
+
+					fmt.Fprintln(ctx.App.Writer, displayValue(s, value, ctx.Bool("reveal")))
+
+					return nil
+				},
+			},
+			{
+				Name:      "set",
+				Usage:     "Validate a value and save it to the config file.",
+				UsageText: "geocast config set [--profile name] units si",
+				Flags:     []cli.Flag{targetFlag},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() != 2 {
+						return errors.New("expected a setting name and value, e.g. geocast config set units si")
+					}
+
+					if err := config.Set(ctx.Args().Get(0), ctx.Args().Get(1), profileTarget(ctx)); err != nil {
+						return err
+					}
+
+					s, _ := lookupSetting(ctx.Args().Get(0))
+
+					if value, source := config.lookup(s); source == sourceEnv {
+						config.log.Warn(fmt.Sprintf("%s overrides the file with %s.", s.env, displayValue(s, value, false)))
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "unset",
+				Usage:     "Remove a setting from the config file.",
+				UsageText: "geocast config unset [--profile name] units",
+				Flags:     []cli.Flag{targetFlag},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() != 1 {
+						return errors.New("expected a setting name, e.g. geocast config unset units")
+					}
+
+					removed, err := config.Unset(ctx.Args().First(), profileTarget(ctx))
+
+					if err != nil {
+						return err
+					}
+
+					if !removed {
+						config.log.Warn(fmt.Sprintf("%s is not set in %s.", ctx.Args().First(), config.path))
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "path",
+				Usage: "Print the path of the config file.",
+				Action: func(ctx *cli.Context) error {
+					fmt.Fprintln(ctx.App.Writer, config.path)
+
+					return nil
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "Check the effective settings and the config file.",
+				Action: func(ctx *cli.Context) error {
+					errs := config.Validate(ctx)
+
+					for _, err := range errs {
+						config.log.Error(err.Error())
+					}
+
+					if len(errs) > 0 {
+						return fmt.Errorf("found %d invalid settings", len(errs))
+					}
+
+					fmt.Fprintln(ctx.App.Writer, "Configuration is valid.")
+
+					return nil
+				},
+			},
+		},
+	}
+}
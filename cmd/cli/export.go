@@ -0,0 +1,164 @@
+// Submodule export writes forecasts in file formats for other programs, such
+// as iCalendar for calendar apps.
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Time zones are embedded so that calendars get their TZID on systems
+	// without a zoneinfo database.
+	_ "time/tzdata"
+
+	"github.com/desertthunder/weather/internal/ical"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+func exportFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.PathFlag{
+			Name:      "file",
+			Usage:     "Write to a file instead of stdout.",
+			TakesFile: true,
+		},
+		&cli.BoolFlag{
+			Name:  "alerts",
+			Usage: "Include the active alerts (--alerts=false to leave them out).",
+			Value: true,
+		},
+		&cli.StringFlag{
+			Name:  "tz",
+			Usage: "Time zone of event times, e.g. America/Chicago or UTC (default: the forecast office's time zone).",
+		},
+	}
+}
+
+// func timeZone loads a time zone by name, UTC when it is empty.
+func timeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q, expected e.g. America/Chicago or UTC", name)
+	}
+
+	return loc, nil
+}
+
+// func forecastCalendar fetches the whole forecast for a city (and its active
+// alerts) as a calendar in the forecast office's time zone, unless tz is set.
+func forecastCalendar(w *nws.WeatherClient, city nws.City, alerts bool, tz string) (ical.Calendar, error) {
+	office, err := w.Office(city)
+
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+
+	fc, err := w.GetForecast(office, city)
+
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+
+	active := []view.Alert{}
+
+	if alerts {
+		a, err := w.GetAlerts(city)
+
+		if err != nil {
+			w.Log.Warn(fmt.Sprintf("Could not fetch alerts: %s", err.Error()))
+		} else {
+			active = view.NewAlerts(city, a.Alerts()).Alerts
+		}
+	}
+
+	if tz == "" {
+		tz = office.Properties.Timezone
+	}
+
+	loc, err := timeZone(tz)
+
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+
+	f := view.NewForecast(city, w.Units(), fc.Properties.Periods)
+
+	return view.NewCalendar(f, active, loc), nil
+}
+
+// ExportCommand defines a pointer to the export command.
+//
+// Usage: geocast export ics [--file forecast.ics] [location flags]
+func ExportCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "export",
+		Category: "Core",
+		Usage:    "Export the forecast to a file format for other programs.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "ics",
+				Usage:     "Export the forecast periods and active alerts as iCalendar events.",
+				UsageText: "geocast export ics [--file forecast.ics] [--alerts=false] [--tz zone] [--c]ity [--ip] [--z]ip [--p]t [--place] [location]",
+				Args:      true,
+				Flags:     append(flags(), exportFlags()...),
+				Before:    before(config),
+				Action: func(ctx *cli.Context) error {
+					if _, err := timeZone(ctx.String("tz")); err != nil {
+						return err
+					}
+
+					w, err := newWeatherClient(ctx, config)
+
+					if err != nil {
+						return err
+					}
+
+					city, err := locate(ctx, config)
+
+					if err != nil {
+						return err
+					}
+
+					cal, err := forecastCalendar(w, *city, ctx.Bool("alerts"), ctx.String("tz"))
+
+					if err != nil {
+						return err
+					}
+
+					path := ctx.Path("file")
+
+					if path == "" || path == "-" {
+						return cal.Encode(ctx.App.Writer)
+					}
+
+					buf := bytes.Buffer{}
+
+					if err := cal.Encode(&buf); err != nil {
+						return err
+					}
+
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						return fmt.Errorf("failed to create directory: %w", err)
+					}
+
+					if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+						return err
+					}
+
+					fmt.Fprintf(ctx.App.Writer, "Saved %d events to %s\n", len(cal.Events), path)
+
+					return nil
+				},
+			},
+		},
+	}
+}
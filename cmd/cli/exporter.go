@@ -0,0 +1,196 @@
+// Submodule exporter serves Prometheus metrics (see internal/metrics) for the
+// weather of the saved places, from geocast exporter and geocast serve.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/metrics"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/server"
+	"github.com/desertthunder/weather/internal/transport"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// Address geocast exporter listens on by default.
+const defaultExporterAddr string = ":9876"
+
+// How often the weather gauges are updated by default. Updates within
+// FORECAST_TTL use the cached weather.
+const defaultMetricsInterval time.Duration = time.Minute
+
+func metricsIntervalFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "How often to update the weather metrics of the saved places.",
+		Value: defaultMetricsInterval,
+	}
+}
+
+// struct placeWeather is the weather of a saved place, cached for the
+// metrics.
+type placeWeather struct {
+	Conditions view.Conditions `json:"conditions"`
+	Next       *view.Period    `json:"next"`
+}
+
+func fetchPlaceWeather(w *nws.WeatherClient, city nws.City) (placeWeather, error) {
+	obs, err := w.GetConditions(city)
+
+	if err != nil {
+		return placeWeather{}, err
+	}
+
+	pw := placeWeather{Conditions: view.NewConditions(city, w.Units(), *obs)}
+
+	fc, err := w.GetWeather(city)
+
+	if err != nil {
+		return placeWeather{}, err
+	}
+
+	if f := view.NewForecast(city, w.Units(), fc.Properties.Periods); len(f.Periods) > 0 {
+		pw.Next = &f.Periods[0]
+	}
+
+	return pw, nil
+}
+
+// func updateWeather sets the weather gauges of the saved places, and removes
+// those of places that are no longer saved. It returns the names of the
+// places it updated.
+func updateWeather(w *nws.WeatherClient, gauges *metrics.Weather, previous map[string]bool, config *conf) map[string]bool {
+	updated := map[string]bool{}
+
+	s, err := openPlaces()
+
+	if err != nil {
+		config.log.Warn(err.Error())
+
+		return previous
+	}
+
+	f := weatherFetcher(w, false, config)
+
+	for _, p := range s.Places {
+		city := p.City()
+		updated[p.Name] = true
+
+		key := fmt.Sprintf("metrics-%.4f,%.4f", city.Lat, city.Long)
+		pw, err := cache.Fetch(f, key, func() (placeWeather, error) {
+			return fetchPlaceWeather(w, city)
+		})
+
+		gauges.SetUp(p.Name, err == nil)
+
+		if err != nil {
+			config.log.Warn(fmt.Sprintf("Could not update the weather of @%s: %s", p.Name, err.Error()))
+
+			continue
+		}
+
+		gauges.SetConditions(p.Name, pw.Conditions)
+
+		if pw.Next != nil {
+			gauges.SetForecast(p.Name, *pw.Next)
+		}
+	}
+
+	for name := range previous {
+		if !updated[name] {
+			gauges.Delete(name)
+		}
+	}
+
+	return updated
+}
+
+// func startMetrics registers the client metrics and the weather gauges,
+// which are updated every --interval until ctx is done.
+func startMetrics(stop context.Context, ctx *cli.Context, config *conf) (*metrics.Registry, error) {
+	w, err := newWeatherClient(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Gauges are in base units, whatever the configured units.
+	if err := w.SetUnits(nws.UnitsSI); err != nil {
+		return nil, err
+	}
+
+	r := metrics.NewRegistry()
+	gauges := metrics.NewWeather(r)
+
+	config.metrics = metrics.NewClient(r)
+	transport.Observe(config.metrics.ObserveRequest)
+
+	interval := ctx.Duration("interval")
+
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+
+	go func() {
+		places := map[string]bool{}
+
+		for {
+			places = updateWeather(w, gauges, places, config)
+
+			select {
+			case <-stop.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+// ExporterCommand defines a pointer to the exporter command.
+//
+// Usage: geocast exporter [--addr :9876] [--interval 1m]
+func ExporterCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "exporter",
+		Category: "Core",
+		Usage:    "Serve Prometheus metrics for the weather of the saved places at /metrics.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "addr",
+				Usage: "Address to listen on.",
+				Value: defaultExporterAddr,
+			},
+			metricsIntervalFlag(),
+		},
+		Before: before(config),
+		Action: func(ctx *cli.Context) error {
+			stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			if s, err := openPlaces(); err == nil && len(s.Places) == 0 {
+				config.log.Warn("No saved places to export the weather of, add some with geocast places add.")
+			}
+
+			r, err := startMetrics(stop, ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", r)
+
+			return server.Listen(stop, ctx.String("addr"), mux, config.log)
+		},
+	}
+}
@@ -2,7 +2,12 @@
 // application.
 package cli
 
-import "github.com/urfave/cli/v2"
+import (
+	"github.com/desertthunder/weather/internal/gpsd"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
 
 func cityFlag() cli.Flag {
 	return &cli.StringFlag{
@@ -15,7 +20,15 @@ func cityFlag() cli.Flag {
 func ipFlag() cli.Flag {
 	return &cli.StringFlag{
 		Name:  "ip",
-		Usage: "The IP address to fetch the forecast for.",
+		Usage: "The IPv4 or IPv6 address to fetch the forecast for.",
+	}
+}
+
+func zipFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "zip",
+		Aliases: []string{"z"},
+		Usage:   "The US ZIP code to fetch the forecast for.",
 	}
 }
 
@@ -23,7 +36,14 @@ func pointFlag() cli.Flag {
 	return &cli.StringSliceFlag{
 		Name:    "pt",
 		Aliases: []string{"p"},
-		Usage:   "The point to fetch the forecast for (lat,lon).",
+		Usage:   "The point to fetch the forecast for: lat,lon, DMS, plus code, geohash or MGRS/UTM.",
+	}
+}
+
+func placeFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "place",
+		Usage: "A saved place to fetch the forecast for (see geocast places), e.g. --place home or @home.",
 	}
 }
 
@@ -59,13 +79,113 @@ func interactiveFlag() cli.Flag {
 	}
 }
 
-func flags() []cli.Flag {
+func geocoderFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "geocoder",
+		Usage: "Geocoding backend: nominatim (falls back to offline) or offline.",
+		Value: "nominatim",
+	}
+}
+
+func ipProviderFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "ip-provider",
+		Usage: "Comma separated IP geolocation providers, tried in order: ipinfo, mmdb, ipapi, ipwhois (default: ipinfo if IPINFO_TOKEN is set, mmdb if MMDB_PATH is set, then ipapi,ipwhois).",
+	}
+}
+
+func refreshFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "refresh",
+		Usage: "Geolocate the device again instead of using the cached location (and fetch the weather again in geocast bar).",
+	}
+}
+
+func unitsFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "units",
+		Usage: "Forecast units: us or si.",
+		Value: nws.UnitsUS,
+	}
+}
+
+func themeFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "theme",
+		Usage: "Color theme: default or mono.",
+		Value: "default",
+	}
+}
+
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Output format: text, json, ndjson, yaml, csv, tsv or markdown.",
+		Value:   view.FormatText,
+	}
+}
+
+func templateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "Format results with a Go template, e.g. '{{.City.Name}}: {{.Summary}}', or a built-in template: motd, oneline, slack or tmux.",
+		},
+		&cli.PathFlag{
+			Name:      "template-file",
+			Usage:     "Format results with a Go template read from a file.",
+			TakesFile: true,
+		},
+	}
+}
+
+func profileFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "profile",
+		Usage: "Use a profile from the config file, e.g. --profile work.",
+	}
+}
+
+func gpsFlags() []cli.Flag {
 	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "gps",
+			Usage: "Locate the device with a local gpsd daemon instead of its IP address.",
+		},
+		&cli.StringFlag{
+			Name:  "gpsd",
+			Usage: "Address of the gpsd daemon.",
+			Value: gpsd.DefaultAddress,
+		},
+		&cli.DurationFlag{
+			Name:  "gps-timeout",
+			Usage: "How long to wait for a GPS fix.",
+			Value: gpsd.DefaultTimeout,
+		},
+		&cli.Float64Flag{
+			Name:  "gps-accuracy",
+			Usage: "Largest accepted GPS error in meters (0 accepts any fix).",
+			Value: gpsd.DefaultAccuracy,
+		},
+	}
+}
+
+func flags() []cli.Flag {
+	return append([]cli.Flag{
 		cityFlag(),
 		ipFlag(),
+		zipFlag(),
 		pointFlag(),
+		placeFlag(),
 		verbosityFlag(),
 		extendedFlag(),
 		interactiveFlag(),
-	}
+		geocoderFlag(),
+		ipProviderFlag(),
+		refreshFlag(),
+		unitsFlag(),
+		themeFlag(),
+		outputFlag(),
+	}, append(gpsFlags(), templateFlags()...)...)
 }
@@ -0,0 +1,178 @@
+// Submodule geocoder selects the forward/reverse geocoding backend used by
+// the "geocast" application.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/fuzzy"
+	"github.com/desertthunder/weather/internal/gazetteer"
+	"github.com/desertthunder/weather/internal/nominatim"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/zcta"
+	"github.com/urfave/cli/v2"
+)
+
+// Geocoder is implemented by the backends that can turn a city name or a
+// point into a City (Nominatim and the offline gazetteer).
+type Geocoder interface {
+	GeocodeByCity(c string) (*nws.City, error)
+	GeocodeByPoint(lat, lon float64) (*nws.City, error)
+}
+
+// fallbackGeocoder tries the primary geocoder first and retries with the
+// fallback geocoder when the primary one is unavailable (e.g. no network or
+// a 5xx), but not when it found nothing.
+type fallbackGeocoder struct {
+	primary  Geocoder
+	fallback Geocoder
+	log      *log.Logger
+}
+
+func (f fallbackGeocoder) GeocodeByCity(c string) (*nws.City, error) {
+	city, err := f.primary.GeocodeByCity(c)
+
+	if err == nil || errors.Is(err, nominatim.ErrNoResults) {
+		return city, err
+	}
+
+	f.log.Debug(fmt.Sprintf("Geocoding %q failed (%s), using offline gazetteer.", c, err.Error()))
+
+	return f.fallback.GeocodeByCity(c)
+}
+
+func (f fallbackGeocoder) GeocodeByPoint(lat, lon float64) (*nws.City, error) {
+	city, err := f.primary.GeocodeByPoint(lat, lon)
+
+	if err == nil || errors.Is(err, nominatim.ErrNoResults) {
+		return city, err
+	}
+
+	f.log.Debug(fmt.Sprintf("Reverse geocoding failed (%s), using offline gazetteer.", err.Error()))
+
+	return f.fallback.GeocodeByPoint(lat, lon)
+}
+
+// suggestingGeocoder adds "did you mean" suggestions to city lookups that
+// fail, e.g. for typos like "Clevland".
+type suggestingGeocoder struct {
+	Geocoder
+}
+
+func (s suggestingGeocoder) GeocodeByCity(c string) (*nws.City, error) {
+	city, err := s.Geocoder.GeocodeByCity(c)
+
+	if err == nil {
+		return city, nil
+	}
+
+	if names := suggestCities(c, 3); len(names) > 0 {
+		return nil, fmt.Errorf("%w (did you mean %s?)", err, strings.Join(names, " or "))
+	}
+
+	return nil, err
+}
+
+// func suggestCities returns up to limit known city names that are close to
+// the query, drawing from the built in cities and the offline gazetteer.
+func suggestCities(q string, limit int) []string {
+	suggestions := fuzzy.Suggest(q, nws.CityNames(), limit)
+
+	if g, err := gazetteer.Default(); err == nil {
+		for _, p := range g.Suggest(q, limit) {
+			suggestions = append(suggestions, p.Label())
+		}
+	}
+
+	// Prefer the fully qualified gazetteer label over the bare city name.
+	names := []string{}
+
+	for _, s := range suggestions {
+		if len(names) == limit {
+			break
+		}
+
+		duplicate := false
+
+		for i, n := range names {
+			if strings.HasPrefix(s, n+",") {
+				names[i] = s
+				duplicate = true
+			}
+
+			if strings.HasPrefix(n, s+",") || n == s {
+				duplicate = true
+			}
+		}
+
+		if !duplicate {
+			names = append(names, s)
+		}
+	}
+
+	return names
+}
+
+// func newGeocoder builds the geocoder selected with the --geocoder flag.
+//
+// "offline" uses only the embedded gazetteer. "nominatim" (the default) uses
+// Nominatim and falls back to the gazetteer when Nominatim is unavailable.
+func newGeocoder(ctx *cli.Context, logger *log.Logger) (Geocoder, error) {
+	n := nominatim.Client()
+	n.SetLogger(logger)
+	name := ctx.String("geocoder")
+
+	switch name {
+	case "", "nominatim":
+		g, err := gazetteer.Default()
+
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Offline gazetteer unavailable: %s", err.Error()))
+
+			return suggestingGeocoder{n}, nil
+		}
+
+		return suggestingGeocoder{fallbackGeocoder{primary: n, fallback: g, log: logger}}, nil
+	case "offline":
+		g, err := gazetteer.Default()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return suggestingGeocoder{g}, nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q (expected nominatim or offline)", name)
+	}
+}
+
+// func geocodeZIP resolves a US ZIP code to its ZCTA centroid, using the
+// embedded table first and Nominatim's postal code search as a fallback
+// (unless the offline geocoder was requested).
+func geocodeZIP(zip string, ctx *cli.Context, logger *log.Logger) (*nws.City, error) {
+	code, err := zcta.Normalize(zip)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t, err := zcta.Default(); err == nil {
+		if c, ok := t.Lookup(code); ok {
+			city := c.City()
+
+			return &city, nil
+		}
+	}
+
+	if ctx.String("geocoder") == "offline" {
+		return nil, fmt.Errorf("ZIP code %s is not in the offline ZCTA table", code)
+	}
+
+	n := nominatim.Client()
+	n.SetLogger(logger)
+
+	return n.GeocodeByPostalCode(code, "us")
+}
@@ -0,0 +1,141 @@
+// Submodule geolocator selects the IP geolocation providers used to locate
+// the current device (or an --ip address).
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/desertthunder/weather/internal/ipinfo"
+	"github.com/urfave/cli/v2"
+)
+
+// Names accepted by --ip-provider.
+var ipProviders = []string{"ipinfo", "mmdb", "ipapi", "ipwhois"}
+
+// Token-free providers tried after the configured ones.
+var defaultIPProviders = []string{"ipapi", "ipwhois"}
+
+// func newGeolocator builds the IP geolocation provider chain selected with
+// the --ip-provider flag, a comma separated list tried in order.
+//
+// Without the flag, the IPInfo API is tried first when IPINFO_TOKEN is set,
+// then a local mmdb file when MMDB_PATH is set, then the token-free ip-api
+// and ipwho.is providers.
+func newGeolocator(ctx *cli.Context, config *conf) (ipinfo.Geolocator, error) {
+	names := []string{}
+
+	for _, name := range strings.Split(ctx.String("ip-provider"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		if config.Get("IPINFO_TOKEN") != "" {
+			names = append(names, "ipinfo")
+		}
+
+		if config.Get("MMDB_PATH") != "" {
+			names = append(names, "mmdb")
+		}
+
+		names = append(names, defaultIPProviders...)
+	}
+
+	providers := []ipinfo.Provider{}
+
+	for _, name := range names {
+		g, err := ipProvider(name, config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, ipinfo.Provider{Name: name, Geolocator: g})
+	}
+
+	var g ipinfo.Geolocator = providers[0].Geolocator
+
+	if len(providers) > 1 {
+		config.log.Debug(fmt.Sprintf("Using IP providers %s.", strings.Join(names, ", ")))
+
+		chain := ipinfo.NewChain(providers...)
+		chain.SetLogger(config.log)
+
+		g = chain
+	}
+
+	return cachedGeolocator(g, names, ctx, config), nil
+}
+
+// func cachedGeolocator caches the device's location for LOCATION_TTL
+// (default 6h, "0" disables the cache) in CACHE_DIR (default: the user cache
+// directory). The --refresh flag ignores the cached location.
+func cachedGeolocator(g ipinfo.Geolocator, names []string, ctx *cli.Context, config *conf) ipinfo.Geolocator {
+	ttl := cacheTTL(config, "LOCATION_TTL", ipinfo.DefaultCacheTTL)
+
+	if ttl <= 0 {
+		return g
+	}
+
+	store, err := cacheStore(config)
+
+	if err != nil {
+		config.log.Debug(fmt.Sprintf("Location cache disabled: %s", err.Error()))
+
+		return g
+	}
+
+	c := ipinfo.NewCachedGeolocator(g, store, "location-"+strings.Join(names, "-"), ttl)
+	c.SetLogger(config.log)
+	c.Refresh = ctx.Bool("refresh")
+
+	return c
+}
+
+// func ipProvider builds a single named IP geolocation provider. Base URLs
+// can be overridden with IPINFO_URL, IPAPI_URL and IPWHOIS_URL.
+func ipProvider(name string, config *conf) (ipinfo.Geolocator, error) {
+	switch name {
+	case "ipinfo":
+		c := ipinfo.NewIPInfoClient(config.Get("IPINFO_TOKEN"))
+		c.SetLogger(config.log)
+
+		if url := config.Get("IPINFO_URL"); url != "" {
+			c.SetURL(url)
+		}
+
+		return c, nil
+	case "mmdb":
+		c, err := ipinfo.NewMMDBClient(config.Get("MMDB_PATH"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.SetLogger(config.log)
+
+		return c, nil
+	case "ipapi":
+		c := ipinfo.NewIPAPIClient()
+		c.SetLogger(config.log)
+
+		if url := config.Get("IPAPI_URL"); url != "" {
+			c.SetURL(url)
+		}
+
+		return c, nil
+	case "ipwhois":
+		c := ipinfo.NewIPWhoisClient()
+		c.SetLogger(config.log)
+
+		if url := config.Get("IPWHOIS_URL"); url != "" {
+			c.SetURL(url)
+		}
+
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown IP provider %q (expected one of %s)", name, strings.Join(ipProviders, ", "))
+	}
+}
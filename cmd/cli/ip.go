@@ -0,0 +1,110 @@
+// Submodule ip provides the "geocast ip" commands for looking up arbitrary IP
+// addresses.
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/desertthunder/weather/internal/ipinfo"
+	"github.com/desertthunder/weather/internal/utils"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// func lookupIPs geolocates the given IP addresses and returns the results in
+// the order they were given. Addresses that can't be geolocated are left out
+// and their errors are joined in the returned error.
+func lookupIPs(g ipinfo.Geolocator, ips []string) ([]ipinfo.IPInfoResponse, error) {
+	addrs := []string{}
+
+	// Invalid addresses are rejected up front rather than once per provider.
+	for _, ip := range ips {
+		addr, err := utils.ParseIPAddress(ip)
+
+		if err != nil {
+			return nil, err
+		}
+
+		addrs = append(addrs, addr.String())
+	}
+
+	results, err := ipinfo.Batch(g, addrs)
+	ordered := []ipinfo.IPInfoResponse{}
+
+	for _, addr := range addrs {
+		r, ok := results[addr]
+
+		if !ok {
+			continue
+		}
+
+		if r.IP == "" {
+			r.IP = addr
+		}
+
+		ordered = append(ordered, r)
+	}
+
+	return ordered, err
+}
+
+// func IPCommand defines a pointer to the ip command.
+//
+// Usage: geocast ip lookup [--json] <ip...>
+func IPCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "ip",
+		Category: "Core",
+		Usage:    "Look up IP addresses.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "lookup",
+				Aliases:   []string{"l"},
+				Usage:     "Geolocate one or more IP addresses, using the IPInfo /batch endpoint when available.",
+				UsageText: "geocast ip lookup [--json] 8.8.8.8 1.1.1.1",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the full responses as JSON.",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					ips := ctx.Args().Slice()
+
+					if len(ips) == 0 {
+						return errors.New("at least one IP address is required")
+					}
+
+					g, err := newGeolocator(ctx, config)
+
+					if err != nil {
+						return err
+					}
+
+					config.log.Debug(fmt.Sprintf("Looking up %d IP addresses.", len(ips)))
+
+					results, lookupErr := lookupIPs(g, ips)
+
+					if len(results) == 0 {
+						return lookupErr
+					}
+
+					if ctx.Bool("json") {
+						if err := ctx.Set("output", view.FormatJSON); err != nil {
+							return err
+						}
+					}
+
+					if err := render(ctx, view.IPList(results)); err != nil {
+						return err
+					}
+
+					// The addresses that were found are shown before failing
+					// for the others.
+					return lookupErr
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,313 @@
+// Submodule notify posts webhook notifications for the alerts and forecast
+// thresholds of the saved places (see internal/notify).
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/notify"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// How often the saved places are checked by default.
+const defaultNotifyInterval time.Duration = 5 * time.Minute
+
+// How far ahead forecast periods are checked against the thresholds by
+// default.
+const defaultNotifyHorizon time.Duration = 24 * time.Hour
+
+// Timeout of each webhook request.
+const webhookTimeout time.Duration = 10 * time.Second
+
+// func StatePath returns the path of the notification state file in
+// $XDG_STATE_HOME/geocast (~/.local/state/geocast by default).
+func StatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return filepath.Join(".local", "state", "geocast", notify.File)
+		}
+
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(dir, "geocast", notify.File)
+}
+
+func validateWebhooks(v string) error {
+	_, err := parseWebhooks([]string{v})
+
+	return err
+}
+
+func validateThresholds(v string) error {
+	_, err := parseThresholds([]string{v})
+
+	return err
+}
+
+// func parseWebhooks parses the --webhook values, each of which may be a comma
+// separated list.
+func parseWebhooks(values []string) ([]notify.Webhook, error) {
+	hooks := []notify.Webhook{}
+
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+
+			w, err := notify.ParseWebhook(s)
+
+			if err != nil {
+				return nil, err
+			}
+
+			hooks = append(hooks, w)
+		}
+	}
+
+	return hooks, nil
+}
+
+// func parseThresholds parses the --threshold values, each of which may be a
+// comma separated list.
+func parseThresholds(values []string) ([]notify.Threshold, error) {
+	thresholds := []notify.Threshold{}
+
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+
+			t, err := notify.ParseThreshold(s)
+
+			if err != nil {
+				return nil, err
+			}
+
+			thresholds = append(thresholds, t)
+		}
+	}
+
+	return thresholds, nil
+}
+
+// func pollPlaces checks the alerts, and the forecast when there are
+// thresholds, of the saved places. Places whose weather can't be fetched are
+// skipped and keep the alerts they had.
+func pollPlaces(w *nws.WeatherClient, state *notify.State, thresholds []notify.Threshold, horizon time.Duration, config *conf) []notify.Event {
+	s, err := openPlaces()
+
+	if err != nil {
+		config.log.Warn(err.Error())
+
+		return nil
+	}
+
+	now := time.Now()
+	events := []notify.Event{}
+	saved := map[string]bool{}
+
+	for _, p := range s.Places {
+		city := p.City()
+		saved[p.Name] = true
+
+		alerts, err := w.GetAlerts(city)
+
+		if err != nil {
+			config.log.Warn(fmt.Sprintf("Could not check the alerts of @%s: %s", p.Name, err.Error()))
+
+			continue
+		}
+
+		found, active := notify.Alerts(p.Name, city, alerts.Alerts(), state.Alerts[p.Name], now)
+		state.Alerts[p.Name] = active
+		events = append(events, found...)
+
+		if len(thresholds) == 0 {
+			continue
+		}
+
+		fc, err := w.GetWeather(city)
+
+		if err != nil {
+			config.log.Warn(fmt.Sprintf("Could not check the forecast of @%s: %s", p.Name, err.Error()))
+
+			continue
+		}
+
+		f := view.NewForecast(city, w.Units(), fc.Properties.Periods)
+		events = append(events, notify.Thresholds(p.Name, f, thresholds, now, now.Add(horizon))...)
+	}
+
+	for name := range state.Alerts {
+		if !saved[name] {
+			delete(state.Alerts, name)
+		}
+	}
+
+	return events
+}
+
+// func undelivered returns the events that weren't sent to every webhook, once
+// each, so that they are retried even when they aren't detected again (e.g.
+// an alert that ended).
+func undelivered(state *notify.State, hooks []notify.Webhook, events []notify.Event) []notify.Event {
+	pending := []notify.Event{}
+	seen := map[string]bool{}
+
+	for _, e := range events {
+		if seen[e.Key] {
+			continue
+		}
+
+		for _, h := range hooks {
+			if !state.IsSent(h, e.Key) {
+				pending = append(pending, e)
+				seen[e.Key] = true
+
+				break
+			}
+		}
+	}
+
+	return pending
+}
+
+// NotifyCommand defines a pointer to the notify command.
+//
+// Usage: geocast notify --webhook URL [--threshold temperature>=100] [--interval 5m] [--once]
+func NotifyCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:      "notify",
+		Category:  "Core",
+		Usage:     "Post webhook notifications for new, updated and cancelled alerts and forecast thresholds of the saved places.",
+		UsageText: "geocast notify --webhook [slack=|discord=|ntfy=|json=]URL [--threshold temperature>=100] [--interval 5m] [--once]",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "webhook",
+				Usage: "Webhook URL, prefixed with slack=, discord=, ntfy= or json= when the format can't be guessed from the host (json by default).",
+			},
+			&cli.StringSliceFlag{
+				Name:  "threshold",
+				Usage: "Forecast threshold in the forecast's units, on temperature, precipitation (%) or wind, e.g. temperature>=100 or wind>30.",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "How often to check the saved places.",
+				Value: defaultNotifyInterval,
+			},
+			&cli.DurationFlag{
+				Name:  "horizon",
+				Usage: "How far ahead to check the forecast against the thresholds.",
+				Value: defaultNotifyHorizon,
+			},
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "Check once and exit, e.g. from cron.",
+			},
+			&cli.PathFlag{
+				Name:      "state",
+				Usage:     "File that remembers the notifications that were sent (default: $XDG_STATE_HOME/geocast/notify.json).",
+				TakesFile: true,
+			},
+			unitsFlag(),
+		},
+		Before: before(config),
+		Action: func(ctx *cli.Context) error {
+			hooks, err := parseWebhooks(ctx.StringSlice("webhook"))
+
+			if err != nil {
+				return err
+			}
+
+			if len(hooks) == 0 {
+				return errors.New("no webhooks to notify, give --webhook URL or set notify_webhooks")
+			}
+
+			for _, h := range hooks {
+				logger.AddSecret(h.URL)
+			}
+
+			thresholds, err := parseThresholds(ctx.StringSlice("threshold"))
+
+			if err != nil {
+				return err
+			}
+
+			w, err := newWeatherClient(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			path := ctx.Path("state")
+
+			if path == "" {
+				path = StatePath()
+			}
+
+			state, err := notify.Open(path)
+
+			if err != nil {
+				return err
+			}
+
+			if s, err := openPlaces(); err == nil && len(s.Places) == 0 {
+				config.log.Warn("No saved places to notify about, add some with geocast places add.")
+			}
+
+			stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			client := &http.Client{Transport: http.DefaultClient.Transport, Timeout: webhookTimeout}
+
+			for {
+				events := append(state.Pending, pollPlaces(w, state, thresholds, ctx.Duration("horizon"), config)...)
+				sent, deliveryErr := state.Deliver(stop, client, hooks, events, time.Now())
+
+				if sent > 0 {
+					config.log.Info(fmt.Sprintf("Sent %d notifications.", sent))
+				}
+
+				if deliveryErr != nil {
+					config.log.Warn(fmt.Sprintf("%s, retrying with the next check.", deliveryErr.Error()))
+				}
+
+				state.Pending = undelivered(state, hooks, events)
+
+				if err := state.Save(time.Now()); err != nil {
+					return fmt.Errorf("failed to save the notification state: %w", err)
+				}
+
+				if ctx.Bool("once") {
+					fmt.Fprintf(ctx.App.Writer, "Notifications sent: %d\n", sent)
+
+					return deliveryErr
+				}
+
+				select {
+				case <-stop.Done():
+					return nil
+				case <-time.After(ctx.Duration("interval")):
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,83 @@
+// Submodule output writes command results in the format selected with
+// --output.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// func setOutput disables colors when the output isn't a terminal and, for
+// the machine readable formats, moves log messages to stderr so that they
+// don't mix with the results.
+func setOutput(ctx *cli.Context, l *log.Logger) {
+	view.SetOutput(ctx.App.Writer)
+
+	if !view.IsText(ctx.String("output")) {
+		l.SetOutput(logger.Writer(os.Stderr))
+	}
+}
+
+// func userTemplate returns the template given with --template or
+// --template-file, or nil.
+func userTemplate(ctx *cli.Context) (*template.Template, error) {
+	text := ctx.String("template")
+	path := ctx.Path("template-file")
+
+	if text != "" && path != "" {
+		return nil, errors.New("--template and --template-file can't be used together")
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+
+		return view.NewTemplate(filepath.Base(path), string(data))
+	}
+
+	if text == "" {
+		return nil, nil
+	}
+
+	return view.ParseTemplate(text)
+}
+
+// func render writes a result with the selected template or in the selected
+// output format.
+func render(ctx *cli.Context, r view.Result) error {
+	t, err := userTemplate(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if t != nil {
+		return view.NewTemplateRenderer(t, ctx.App.Writer).Render(r)
+	}
+
+	o := view.Options{Verbosity: ctx.Int("verbosity")}
+
+	if ctx.Bool("extended") {
+		o.Delay = time.Millisecond * 500
+	}
+
+	rd, err := view.NewRenderer(ctx.String("output"), ctx.App.Writer, o)
+
+	if err != nil {
+		return err
+	}
+
+	return rd.Render(r)
+}
@@ -0,0 +1,272 @@
+// Submodule places provides the "geocast places" commands for managing saved
+// locations, and the lookups behind "@name" and --place.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/places"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// func PlacesPath returns the path of the saved places file, next to the
+// config file.
+func PlacesPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath()), places.File)
+}
+
+func openPlaces() (*places.Store, error) {
+	return places.Open(PlacesPath())
+}
+
+// func geocodePlace looks up a saved place.
+func geocodePlace(name string) (*nws.City, error) {
+	s, err := openPlaces()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.Get(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	city := p.City()
+
+	return &city, nil
+}
+
+// func defaultPlace returns the default saved place, if one is set.
+func defaultPlace(logger *log.Logger) (places.Place, bool) {
+	s, err := openPlaces()
+
+	if err != nil {
+		logger.Warn(err.Error())
+
+		return places.Place{}, false
+	}
+
+	return s.DefaultPlace()
+}
+
+// func PlacesCommand defines a pointer to the places command.
+//
+// Usage: geocast places add|list|remove|rename|default
+func PlacesCommand(config *conf) *cli.Command {
+	// func update opens the store, applies f and saves the result.
+	update := func(f func(s *places.Store) error) error {
+		s, err := openPlaces()
+
+		if err != nil {
+			return err
+		}
+
+		if err := f(s); err != nil {
+			return err
+		}
+
+		return s.Save()
+	}
+
+	return &cli.Command{
+		Name:     "places",
+		Aliases:  []string{"pl"},
+		Category: "Core",
+		Usage:    "Manage saved places, which can be forecast with @name or --place name.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "add",
+				Usage:     "Save a place. Without a location, the device's current location is saved.",
+				UsageText: "geocast places add [--default] home [city|zip|lat,lon|ip]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "default",
+						Usage: "Also make it the default place.",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() == 0 {
+						return errors.New("a name is required, e.g. geocast places add home \"Austin, TX\"")
+					}
+
+					for _, arg := range ctx.Args().Slice() {
+						if isFlag(arg) {
+							return fmt.Errorf("flags must come before the name, put %s before %s", arg, ctx.Args().First())
+						}
+					}
+
+					name := places.Name(ctx.Args().First())
+
+					if err := places.ValidateName(name); err != nil {
+						return err
+					}
+
+					n, err := newGeocoder(ctx, config.log)
+
+					if err != nil {
+						return err
+					}
+
+					var city *nws.City
+
+					loc := resolveArg(strings.Join(ctx.Args().Tail(), " "))
+
+					if loc.kind == locationDevice || loc.kind == locationIP {
+						i, gerr := newGeolocator(ctx, config)
+
+						if gerr != nil {
+							return gerr
+						}
+
+						city, err = geolocate(i, n, ctx, loc.ip(), config.log)
+					} else {
+						city, err = geocodeResolved(n, ctx, loc, config.log)
+					}
+
+					if err != nil {
+						config.log.Error(err.Error())
+
+						return err
+					}
+
+					err = update(func(s *places.Store) error {
+						if err := s.Add(places.Place{Name: name, Label: city.Name, Lat: city.Lat, Lon: city.Long}); err != nil {
+							return err
+						}
+
+						if ctx.Bool("default") {
+							return s.SetDefault(name)
+						}
+
+						return nil
+					})
+
+					if err != nil {
+						return err
+					}
+
+					fmt.Fprintf(ctx.App.Writer, "Saved %s%s: %s\n", places.Prefix, name, city.Fmt())
+
+					return nil
+				},
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "List saved places.",
+				Action: func(ctx *cli.Context) error {
+					s, err := openPlaces()
+
+					if err != nil {
+						return err
+					}
+
+					if len(s.Places) == 0 && view.IsText(ctx.String("output")) {
+						fmt.Fprintln(ctx.App.Writer, "No saved places, add one with geocast places add <name> [location].")
+
+						return nil
+					}
+
+					l := view.List{Headers: []string{"Name", "Location", "Latitude", "Longitude", "Default"}}
+
+					for _, p := range s.Places {
+						def := ""
+
+						if strings.EqualFold(p.Name, s.Default) {
+							def = "*"
+						}
+
+						l.Data = append(l.Data, []string{
+							places.Prefix + p.Name,
+							p.Label,
+							fmt.Sprintf("%.4f", p.Lat),
+							fmt.Sprintf("%.4f", p.Lon),
+							def,
+						})
+
+						l.Values = append(l.Values, struct {
+							places.Place
+							Default bool `json:"default"`
+						}{p, def != ""})
+					}
+
+					return render(ctx, l)
+				},
+			},
+			{
+				Name:      "remove",
+				Aliases:   []string{"rm"},
+				Usage:     "Delete a saved place.",
+				UsageText: "geocast places remove home",
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() != 1 {
+						return errors.New("expected the name of a place, e.g. geocast places remove home")
+					}
+
+					return update(func(s *places.Store) error {
+						return s.Remove(ctx.Args().First())
+					})
+				},
+			},
+			{
+				Name:      "rename",
+				Aliases:   []string{"mv"},
+				Usage:     "Rename a saved place.",
+				UsageText: "geocast places rename home house",
+				Action: func(ctx *cli.Context) error {
+					if ctx.Args().Len() != 2 {
+						return errors.New("expected the current and new names, e.g. geocast places rename home house")
+					}
+
+					return update(func(s *places.Store) error {
+						return s.Rename(ctx.Args().Get(0), ctx.Args().Get(1))
+					})
+				},
+			},
+			{
+				Name:      "default",
+				Usage:     "Print or set the place forecast when no location is given.",
+				UsageText: "geocast places default [--clear] [name]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "clear",
+						Usage: "Geolocate the device again when no location is given.",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.Bool("clear") {
+						return update(func(s *places.Store) error {
+							return s.SetDefault("")
+						})
+					}
+
+					if ctx.Args().Len() == 0 {
+						s, err := openPlaces()
+
+						if err != nil {
+							return err
+						}
+
+						if p, ok := s.DefaultPlace(); ok {
+							fmt.Fprintln(ctx.App.Writer, places.Prefix+p.Name)
+						}
+
+						return nil
+					}
+
+					return update(func(s *places.Store) error {
+						return s.SetDefault(ctx.Args().First())
+					})
+				},
+			},
+		},
+	}
+}
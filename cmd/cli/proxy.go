@@ -0,0 +1,107 @@
+// Submodule proxy runs the caching weather.gov proxy (see internal/proxy).
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/proxy"
+	"github.com/desertthunder/weather/internal/server"
+	"github.com/urfave/cli/v2"
+)
+
+// func pruneProxy removes the responses that can't be served anymore from the
+// proxy's cache every proxy.PruneInterval until ctx is done.
+func pruneProxy(ctx context.Context, p *proxy.Proxy, config *conf) {
+	for {
+		n, err := p.Prune(time.Now())
+
+		if err != nil {
+			config.log.Warn(fmt.Sprintf("Could not prune the proxy cache: %s", err.Error()))
+		} else if n > 0 {
+			config.log.Debug(fmt.Sprintf("Pruned %d expired responses from the proxy cache.", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(proxy.PruneInterval):
+		}
+	}
+}
+
+// ProxyCommand defines a pointer to the proxy command.
+//
+// Usage: geocast proxy [--addr :8081] [--upstream https://api.weather.gov]
+//
+// Clients use the proxy by setting NWS_URL to its address.
+func ProxyCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "proxy",
+		Category: "Core",
+		Usage:    "Run a caching weather.gov proxy for a team (point NWS_URL at it).",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "addr",
+				Usage: "Address to listen on.",
+				Value: proxy.DefaultAddr,
+			},
+			&cli.StringFlag{
+				Name:  "upstream",
+				Usage: "weather.gov API base URL to proxy.",
+				Value: nws.BaseURL,
+			},
+			&cli.StringFlag{
+				Name:  "user-agent",
+				Usage: "User-Agent sent to weather.gov, which asks for a way to contact you, e.g. \"team (ops@example.com)\".",
+				Value: nws.UserAgent,
+			},
+			&cli.DurationFlag{
+				Name:  "max-stale",
+				Usage: "How long expired responses are served when weather.gov fails (0 to never serve them).",
+				Value: proxy.DefaultMaxStale,
+			},
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "Only coalesce identical requests, without caching responses on disk.",
+			},
+		},
+		Before: before(config),
+		Action: func(ctx *cli.Context) error {
+			if err := validateURL(ctx.String("upstream")); err != nil {
+				return fmt.Errorf("invalid --upstream: %w", err)
+			}
+
+			p := proxy.New(ctx.String("upstream"), nil, config.log)
+			p.UserAgent = ctx.String("user-agent")
+			p.MaxStale = ctx.Duration("max-stale")
+
+			stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			if !ctx.Bool("no-cache") {
+				store, err := cacheStore(config)
+
+				if err != nil {
+					return fmt.Errorf("failed to open the cache directory: %w", err)
+				}
+
+				// Responses get their own directory, which is pruned.
+				p.Store = cache.New(filepath.Join(store.Dir, "proxy"))
+
+				config.log.Info(fmt.Sprintf("Caching weather.gov responses in %s", p.Store.Dir))
+
+				go pruneProxy(stop, p, config)
+			}
+
+			return server.Listen(stop, ctx.String("addr"), p.Handler(), config.log)
+		},
+	}
+}
@@ -0,0 +1,372 @@
+// Submodule publish pushes the weather to home automation systems, such as
+// Home Assistant over MQTT.
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/hass"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/mqtt"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/urfave/cli/v2"
+)
+
+// How often the weather is published by default.
+const defaultPublishInterval time.Duration = 5 * time.Minute
+
+// Longest wait between attempts to reach the broker after a failure.
+const maxPublishBackoff time.Duration = time.Minute
+
+func mqttFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "broker",
+			Usage: "MQTT broker URL: tcp://host:1883, or ssl://host:8883 for TLS.",
+			Value: "tcp://localhost:1883",
+		},
+		&cli.StringFlag{
+			Name:  "topic",
+			Usage: "Topic prefix of the conditions, forecast, alerts and availability topics (default: geocast/<location>).",
+		},
+		&cli.StringFlag{
+			Name:  "client-id",
+			Usage: "MQTT client identifier (default: geocast-<location>).",
+		},
+		&cli.StringFlag{
+			Name:  "username",
+			Usage: "MQTT user name.",
+		},
+		&cli.StringFlag{
+			Name:  "password",
+			Usage: "MQTT password (prefer MQTT_PASSWORD).",
+		},
+		&cli.IntFlag{
+			Name:  "qos",
+			Usage: "QoS of the messages, 0 or 1.",
+			Value: 1,
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "How often to publish the weather.",
+			Value: defaultPublishInterval,
+		},
+		&cli.BoolFlag{
+			Name:  "once",
+			Usage: "Publish once and exit, e.g. from cron.",
+		},
+		&cli.BoolFlag{
+			Name:  "discovery",
+			Usage: "Publish Home Assistant MQTT discovery messages (--discovery=false to skip them).",
+			Value: true,
+		},
+		&cli.StringFlag{
+			Name:  "discovery-prefix",
+			Usage: "Home Assistant discovery prefix.",
+			Value: hass.DefaultPrefix,
+		},
+		&cli.PathFlag{
+			Name:      "ca-file",
+			Usage:     "PEM file of the CAs that signed the broker's certificate (default: the system's).",
+			TakesFile: true,
+		},
+		&cli.PathFlag{
+			Name:      "cert-file",
+			Usage:     "PEM client certificate, for brokers that require one (with --key-file).",
+			TakesFile: true,
+		},
+		&cli.PathFlag{
+			Name:      "key-file",
+			Usage:     "PEM private key of the client certificate.",
+			TakesFile: true,
+		},
+		&cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "Don't verify the broker's certificate.",
+		},
+	}
+}
+
+// func mqttTLS builds the TLS configuration from the TLS flags.
+func mqttTLS(ctx *cli.Context) (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: ctx.Bool("insecure")}
+
+	if path := ctx.Path("ca-file"); path != "" {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates in %s", path)
+		}
+	}
+
+	cert, key := ctx.Path("cert-file"), ctx.Path("key-file")
+
+	if (cert == "") != (key == "") {
+		return nil, errors.New("--cert-file and --key-file must be given together")
+	}
+
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	return cfg, nil
+}
+
+// struct publisher publishes the weather of a city on MQTT topics.
+type publisher struct {
+	client *mqtt.Client
+	w      *nws.WeatherClient
+	city   nws.City
+	topics hass.Topics
+	// Discovery prefix, empty to skip discovery.
+	discovery string
+	log       *log.Logger
+}
+
+// func connect connects to the broker and announces the sensors, which is
+// repeated after reconnecting in case the broker lost its retained messages.
+func (p *publisher) connect(ctx context.Context) error {
+	if err := p.client.Connect(ctx); err != nil {
+		return err
+	}
+
+	p.log.Info(fmt.Sprintf("Connected to %s.", p.client.Broker))
+
+	if p.discovery != "" {
+		messages, err := hass.Discovery(p.discovery, p.city.Name, p.topics, p.w.Units())
+
+		if err != nil {
+			return err
+		}
+
+		for _, m := range messages {
+			if err := p.client.Publish(mqtt.Message{Topic: m.Topic, Payload: m.Payload, Retain: true}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.client.Publish(mqtt.Message{Topic: p.topics.Availability, Payload: []byte(hass.Online), Retain: true})
+}
+
+// func publish fetches the conditions, forecast and alerts and publishes
+// them as retained JSON messages. Results that can't be fetched are skipped
+// (the previous ones stay retained); failures to publish are returned.
+func (p *publisher) publish(ctx context.Context) error {
+	if !p.client.Connected() {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	results := []struct {
+		topic string
+		fetch func() (any, error)
+	}{
+		{p.topics.Conditions, func() (any, error) {
+			obs, err := p.w.GetConditions(p.city)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return view.NewConditions(p.city, p.w.Units(), *obs), nil
+		}},
+		{p.topics.Forecast, func() (any, error) {
+			fc, err := p.w.GetWeather(p.city)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return view.NewForecast(p.city, p.w.Units(), fc.Properties.Periods), nil
+		}},
+		{p.topics.Alerts, func() (any, error) {
+			alerts, err := p.w.GetAlerts(p.city)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return view.NewAlerts(p.city, alerts.Alerts()), nil
+		}},
+	}
+
+	for _, r := range results {
+		v, err := r.fetch()
+
+		if err != nil {
+			p.log.Warn(fmt.Sprintf("Not publishing %s: %s", r.topic, err.Error()))
+
+			continue
+		}
+
+		payload, err := json.Marshal(v)
+
+		if err != nil {
+			return err
+		}
+
+		if err := p.client.Publish(mqtt.Message{Topic: r.topic, Payload: payload, Retain: true}); err != nil {
+			return err
+		}
+
+		p.log.Debug(fmt.Sprintf("Published %d bytes to %s.", len(payload), r.topic))
+	}
+
+	return nil
+}
+
+// func run publishes every interval until ctx is done, reconnecting with a
+// growing delay when the broker can't be reached. The availability topic is
+// set to offline before disconnecting.
+func (p *publisher) run(ctx context.Context, interval time.Duration) error {
+	backoff := time.Second
+
+	for {
+		wait := interval
+
+		if err := p.publish(ctx); err != nil {
+			p.log.Warn(fmt.Sprintf("%s, retrying in %s.", err.Error(), backoff))
+
+			wait = min(backoff, interval)
+			backoff = min(backoff*2, maxPublishBackoff)
+		} else {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			if p.client.Connected() {
+				p.client.Publish(mqtt.Message{Topic: p.topics.Availability, Payload: []byte(hass.Offline), Retain: true})
+			}
+
+			return p.client.Close()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// PublishCommand defines a pointer to the publish command.
+//
+// Usage: geocast publish mqtt [--broker tcp://localhost:1883] [location flags]
+func PublishCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "publish",
+		Category: "Core",
+		Usage:    "Publish the weather to home automation systems.",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "mqtt",
+				Usage:     "Publish the conditions, forecast and alerts as retained JSON messages, with Home Assistant discovery.",
+				UsageText: "geocast publish mqtt [--broker tcp://localhost:1883] [--topic geocast/home] [--interval 5m] [--once] [--c]ity [--ip] [--z]ip [--p]t [--place] [location]",
+				Args:      true,
+				Flags:     append(flags(), mqttFlags()...),
+				Before:    before(config),
+				Action: func(ctx *cli.Context) error {
+					qos := ctx.Int("qos")
+
+					if qos != 0 && qos != 1 {
+						return fmt.Errorf("unsupported QoS %d (expected 0 or 1)", qos)
+					}
+
+					tlsConfig, err := mqttTLS(ctx)
+
+					if err != nil {
+						return err
+					}
+
+					w, err := newWeatherClient(ctx, config)
+
+					if err != nil {
+						return err
+					}
+
+					city, err := locate(ctx, config)
+
+					if err != nil {
+						return err
+					}
+
+					id := hass.ID(city.Name)
+					topic := ctx.String("topic")
+
+					if topic == "" {
+						topic = "geocast/" + id
+					}
+
+					clientID := ctx.String("client-id")
+
+					if clientID == "" {
+						clientID = "geocast-" + id
+					}
+
+					if pw := ctx.String("password"); pw != "" {
+						logger.AddSecret(pw)
+					}
+
+					p := &publisher{
+						w:      w,
+						city:   *city,
+						log:    config.log,
+						topics: hass.Topics{Conditions: topic + "/conditions", Forecast: topic + "/forecast", Alerts: topic + "/alerts", Availability: topic + "/availability"},
+					}
+
+					if ctx.Bool("discovery") {
+						p.discovery = ctx.String("discovery-prefix")
+					}
+
+					p.client = mqtt.New(mqtt.Options{
+						Broker:   ctx.String("broker"),
+						ClientID: clientID,
+						Username: ctx.String("username"),
+						Password: ctx.String("password"),
+						TLS:      tlsConfig,
+						QoS:      byte(qos),
+						// The broker marks the sensors unavailable if geocast
+						// goes away without disconnecting.
+						Will: &mqtt.Message{Topic: p.topics.Availability, Payload: []byte(hass.Offline), Retain: true},
+					}, config.log)
+
+					if ctx.Bool("once") {
+						if err := p.publish(ctx.Context); err != nil {
+							return err
+						}
+
+						fmt.Fprintf(ctx.App.Writer, "Published the weather for %s to %s/#\n", city.Name, topic)
+
+						return p.client.Close()
+					}
+
+					stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+					defer cancel()
+
+					return p.run(stop, ctx.Duration("interval"))
+				},
+			},
+		},
+	}
+}
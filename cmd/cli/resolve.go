@@ -0,0 +1,158 @@
+// Submodule resolve works out what a location argument refers to, so that
+// "geocast 78701", "geocast forecast 30.2672,-97.7431" and "geocast geocode
+// @home" mean the same as the matching location flags.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/coords"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/places"
+	"github.com/desertthunder/weather/internal/zcta"
+	"github.com/urfave/cli/v2"
+)
+
+// Kinds of locations, named after the flag that gives them.
+const (
+	locationDevice = "me"
+	locationPlace  = "place"
+	locationIP     = "ip"
+	locationZIP    = "zip"
+	locationPoint  = "pt"
+	locationCity   = "city"
+)
+
+// struct location is a location given as an argument or with a flag.
+type location struct {
+	kind string
+	// The argument or flag value, empty when the device is located because
+	// no location was given.
+	value string
+}
+
+// func ip returns the IP address to geolocate, empty for the device.
+func (l location) ip() string {
+	if l.kind == locationIP {
+		return l.value
+	}
+
+	return ""
+}
+
+// func resolveArg detects what an argument refers to: "me" (the device), a
+// saved "@place", an IP address, a US ZIP code, coordinates in any format
+// accepted by coords.Parse, or otherwise a city.
+func resolveArg(arg string) location {
+	arg = strings.TrimSpace(arg)
+
+	switch {
+	case arg == "":
+		return location{locationDevice, ""}
+	case strings.EqualFold(arg, "me"):
+		return location{locationDevice, arg}
+	case strings.HasPrefix(arg, places.Prefix):
+		return location{locationPlace, arg}
+	case net.ParseIP(arg) != nil:
+		return location{locationIP, arg}
+	case zcta.IsZIP(arg):
+		return location{locationZIP, arg}
+	}
+
+	if _, err := coords.Parse(arg); err == nil {
+		return location{locationPoint, arg}
+	}
+
+	return location{locationCity, arg}
+}
+
+// func isFlag reports whether an argument looks like a flag rather than part
+// of a location (negative coordinates are not flags).
+func isFlag(arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		return true
+	}
+
+	return len(arg) > 1 && arg[0] == '-' && unicode.IsLetter(rune(arg[1]))
+}
+
+// func resolveLocation returns the location given on the command line.
+// Arguments, joined with spaces ("geocast forecast San Antonio"), take
+// precedence over the location flags and so over the configured default
+// location. Without either, the device is located.
+func resolveLocation(ctx *cli.Context) (location, error) {
+	if ctx.Args().Present() {
+		args := ctx.Args().Slice()
+
+		for _, arg := range args {
+			if isFlag(arg) {
+				return location{}, fmt.Errorf("flags must come before the location, put %s before %s", arg, args[0])
+			}
+		}
+
+		return resolveArg(strings.Join(args, " ")), nil
+	}
+
+	switch {
+	case ctx.String("place") != "":
+		return location{locationPlace, ctx.String("place")}, nil
+	case ctx.String("zip") != "":
+		return location{locationZIP, ctx.String("zip")}, nil
+	case len(ctx.StringSlice("pt")) > 0:
+		// The slice flag splits "lat,lon" on the comma, so the parts are
+		// joined back together before detecting the format.
+		return location{locationPoint, strings.Join(ctx.StringSlice("pt"), ",")}, nil
+	case ctx.String("city") != "":
+		return location{locationCity, ctx.String("city")}, nil
+	case ctx.String("ip") != "":
+		return location{locationIP, ctx.String("ip")}, nil
+	}
+
+	return location{locationDevice, ""}, nil
+}
+
+// func geocodeResolved geocodes a saved place, ZIP code, point or city.
+// Points keep their exact coordinates and are only reverse geocoded for their
+// name. The device and IP addresses are located with geolocate instead.
+func geocodeResolved(n Geocoder, ctx *cli.Context, loc location, logger *log.Logger) (*nws.City, error) {
+	var city *nws.City
+	var err error
+
+	switch loc.kind {
+	case locationPlace:
+		return geocodePlace(loc.value)
+	case locationZIP:
+		return geocodeZIP(loc.value, ctx, logger)
+	case locationPoint:
+		p, perr := coords.Parse(loc.value)
+
+		if perr != nil {
+			return nil, perr
+		}
+
+		city, err = n.GeocodeByPoint(p.Lat, p.Lon)
+
+		if city != nil {
+			city.Lat, city.Long = p.Lat, p.Lon
+		}
+	case locationCity:
+		city, err = n.GeocodeByCity(loc.value)
+	default:
+		return nil, errors.New("the device and IP addresses can't be geocoded")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if city == nil {
+		return nil, fmt.Errorf("no results found for %q", loc.value)
+	}
+
+	return city, nil
+}
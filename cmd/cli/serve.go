@@ -0,0 +1,177 @@
+// Submodule serve runs the HTTP API (see internal/server) with the same
+// geocoders, IP providers and caches as the other commands.
+package cli
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/desertthunder/weather/internal/metrics"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/server"
+	"github.com/urfave/cli/v2"
+)
+
+// func requestLocation reads the location of an API request: q (any location
+// argument), lat and lon, zip, city, place or ip. The default location is
+// used for requests without one.
+func requestLocation(r *http.Request, config *conf) (location, error) {
+	q := r.URL.Query()
+
+	switch {
+	case q.Get("q") != "":
+		return resolveArg(q.Get("q")), nil
+	case q.Get("lat") != "" && q.Get("lon") != "":
+		return location{locationPoint, q.Get("lat") + "," + q.Get("lon")}, nil
+	case q.Get("zip") != "":
+		return location{locationZIP, q.Get("zip")}, nil
+	case q.Get("city") != "":
+		return location{locationCity, q.Get("city")}, nil
+	case q.Get("place") != "":
+		return location{locationPlace, q.Get("place")}, nil
+	case q.Get("ip") != "":
+		return location{locationIP, q.Get("ip")}, nil
+	}
+
+	if loc := resolveArg(config.Get("location")); loc.kind != locationDevice {
+		return loc, nil
+	}
+
+	if p, ok := defaultPlace(config.log); ok {
+		return location{locationPlace, p.Name}, nil
+	}
+
+	return location{}, server.ErrNoLocation
+}
+
+// func serverLocator resolves request locations. The server's own location
+// is never used, since it says nothing about where the dashboard is.
+func serverLocator(ctx *cli.Context, config *conf) (server.Locator, error) {
+	i, err := newGeolocator(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := newGeocoder(ctx, config.log)
+
+	if err != nil {
+		return nil, err
+	}
+
+	geocodes := geocodeFetcher(ctx, config)
+
+	return func(r *http.Request) (nws.City, error) {
+		loc, err := requestLocation(r, config)
+
+		if err != nil {
+			return nws.City{}, err
+		}
+
+		var city *nws.City
+
+		switch loc.kind {
+		case locationDevice:
+			return nws.City{}, server.ErrNoLocation
+		case locationIP:
+			city, err = geolocate(i, n, ctx, loc.ip(), config.log)
+		default:
+			city, err = cachedGeocode(geocodes, loc, func() (*nws.City, error) {
+				return geocodeResolved(n, ctx, loc, config.log)
+			})
+		}
+
+		if err != nil {
+			return nws.City{}, err
+		}
+
+		return *city, nil
+	}, nil
+}
+
+// ServeCommand defines a pointer to the serve command.
+//
+// Usage: geocast serve [--addr :8080] [--cors-origin origin] [--metrics]
+//
+// The server stops on SIGINT or SIGTERM, after finishing in-flight requests.
+// With --metrics, Prometheus metrics are served at /metrics, as with geocast
+// exporter.
+func ServeCommand(config *conf) *cli.Command {
+	return &cli.Command{
+		Name:     "serve",
+		Category: "Core",
+		Usage:    "Serve forecasts, alerts and current conditions as a JSON API (see /openapi.json).",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "addr",
+				Usage: "Address to listen on.",
+				Value: server.DefaultAddr,
+			},
+			&cli.StringFlag{
+				Name:  "cors-origin",
+				Usage: "Origin allowed to call the API from browsers, e.g. * or https://dashboard.example.com (default: none).",
+			},
+			geocoderFlag(),
+			ipProviderFlag(),
+			unitsFlag(),
+			&cli.BoolFlag{
+				Name:  "metrics",
+				Usage: "Also serve Prometheus metrics for the weather of the saved places at /metrics, as with geocast exporter.",
+			},
+			metricsIntervalFlag(),
+		},
+		Before: before(config),
+		Action: func(ctx *cli.Context) error {
+			stop, cancel := signal.NotifyContext(ctx.Context, os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			var registry *metrics.Registry
+
+			// Before the clients and caches are built, so that they report
+			// to the metrics.
+			if ctx.Bool("metrics") {
+				r, err := startMetrics(stop, ctx, config)
+
+				if err != nil {
+					return err
+				}
+
+				registry = r
+			}
+
+			locate, err := serverLocator(ctx, config)
+
+			if err != nil {
+				return err
+			}
+
+			weather := func(units string) (*nws.WeatherClient, error) {
+				w, err := newWeatherClient(ctx, config)
+
+				if err != nil || units == "" {
+					return w, err
+				}
+
+				return w, w.SetUnits(units)
+			}
+
+			w, err := weather("")
+
+			if err != nil {
+				return err
+			}
+
+			s := server.New(locate, weather, config.log)
+			s.Cache = weatherFetcher(w, false, config)
+			s.CORSOrigin = ctx.String("cors-origin")
+
+			if registry != nil {
+				s.Metrics = registry
+			}
+
+			return s.ListenAndServe(stop, ctx.String("addr"))
+		},
+	}
+}
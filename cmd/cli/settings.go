@@ -0,0 +1,351 @@
+// Submodule settings lists the configuration keys understood by geocast and
+// applies them as defaults for the command line flags.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/desertthunder/weather/internal/coords"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/places"
+	"github.com/desertthunder/weather/internal/view"
+	"github.com/desertthunder/weather/internal/zcta"
+	"github.com/urfave/cli/v2"
+)
+
+// struct setting describes a configuration key.
+type setting struct {
+	// Key in config.toml.
+	key string
+	// Environment variable that overrides the file.
+	env string
+	// Flag that overrides the environment, if any.
+	flag string
+	// Built-in default, if any.
+	def   string
+	usage string
+	// Checks a value, returning an error that says how to fix it.
+	validate func(string) error
+}
+
+// Sources of a configuration value, from lowest to highest precedence.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+var settings = []setting{
+	{"location", "GEOCAST_LOCATION", "", "", "Default location: a city, US ZIP code, coordinates or saved @place. The device is geolocated when unset.", validateLocation},
+	{"units", "GEOCAST_UNITS", "units", "us", "Forecast units: us or si.", oneOf(nws.UnitsUS, nws.UnitsSI)},
+	{"verbosity", "GEOCAST_VERBOSITY", "verbosity", "0", "Forecast verbosity, 0-3.", validateVerbosity},
+	{"theme", "GEOCAST_THEME", "theme", "default", "Color theme: default or mono.", oneOf(view.Themes...)},
+	{"output", "GEOCAST_OUTPUT", "output", "text", "Output format: text, json, ndjson, yaml, csv, tsv or markdown.", oneOf(view.Formats...)},
+	{"geocoder", "GEOCAST_GEOCODER", "geocoder", "nominatim", "Geocoding backend: nominatim or offline.", oneOf("nominatim", "offline")},
+	{"ip_provider", "GEOCAST_IP_PROVIDER", "ip-provider", "", "Comma separated IP geolocation providers: ipinfo, mmdb, ipapi, ipwhois.", validateIPProviders},
+	{"gpsd", "GEOCAST_GPSD", "gpsd", "localhost:2947", "Address of the gpsd daemon.", validateAddress},
+	{"ipinfo_token", "IPINFO_TOKEN", "", "", "IPInfo API token.", nil},
+	{"nws_url", "NWS_URL", "", "", "weather.gov API base URL, e.g. a geocast proxy shared by a team.", validateURL},
+	{"ipinfo_url", "IPINFO_URL", "", "", "IPInfo API base URL.", validateURL},
+	{"ipapi_url", "IPAPI_URL", "", "", "ip-api.com base URL.", validateURL},
+	{"ipwhois_url", "IPWHOIS_URL", "", "", "ipwho.is base URL.", validateURL},
+	{"mmdb_path", "MMDB_PATH", "", "", "Path of a MaxMind/DB-IP City mmdb file.", validateFile},
+	{"location_ttl", "LOCATION_TTL", "", "6h", "How long to cache the device's location (0 disables the cache).", validateDuration},
+	{"forecast_ttl", "FORECAST_TTL", "", "10m", "How long geocast bar and serve reuse the weather they fetched (0 disables the cache).", validateDuration},
+	{"cache_dir", "CACHE_DIR", "", "", "Cache directory (default: the user cache directory).", nil},
+	{"mqtt_broker", "MQTT_BROKER", "broker", "", "MQTT broker URL for geocast publish mqtt, e.g. tcp://localhost:1883.", validateBroker},
+	{"mqtt_username", "MQTT_USERNAME", "username", "", "MQTT user name.", nil},
+	{"mqtt_password", "MQTT_PASSWORD", "password", "", "MQTT password.", nil},
+	{"notify_webhooks", "NOTIFY_WEBHOOKS", "webhook", "", "Comma separated webhook URLs for geocast notify, prefixed with slack=, discord=, ntfy= or json= when needed.", validateWebhooks},
+	{"notify_thresholds", "NOTIFY_THRESHOLDS", "threshold", "", "Comma separated forecast thresholds for geocast notify, e.g. temperature>=100,wind>30.", validateThresholds},
+}
+
+// func oneOf accepts one of the given values.
+func oneOf(values ...string) func(string) error {
+	return func(v string) error {
+		if slices.Contains(values, v) {
+			return nil
+		}
+
+		return fmt.Errorf("%q is not one of %s", v, strings.Join(values, ", "))
+	}
+}
+
+func validateLocation(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return errors.New("expected a city, US ZIP code, coordinates or @place, or unset it to geolocate the device")
+	}
+
+	if strings.HasPrefix(v, places.Prefix) {
+		return places.ValidateName(places.Name(v))
+	}
+
+	// Values without letters are meant as ZIP codes, IP addresses or
+	// coordinates.
+	if !strings.ContainsFunc(v, unicode.IsLetter) && !zcta.IsZIP(v) && net.ParseIP(v) == nil {
+		if _, err := coords.Parse(v); err != nil {
+			return fmt.Errorf("%w; expected a city, US ZIP code or coordinates", err)
+		}
+	}
+
+	return nil
+}
+
+func validateVerbosity(v string) error {
+	n, err := strconv.Atoi(v)
+
+	if err != nil || n < 0 || n > 3 {
+		return fmt.Errorf("%q is not a verbosity level, expected 0, 1, 2 or 3", v)
+	}
+
+	return nil
+}
+
+func validateIPProviders(v string) error {
+	for _, name := range strings.Split(v, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if name != "" && !slices.Contains(ipProviders, name) {
+			return fmt.Errorf("unknown IP provider %q, expected a comma separated list of %s", name, strings.Join(ipProviders, ", "))
+		}
+	}
+
+	return nil
+}
+
+func validateAddress(v string) error {
+	if _, port, err := net.SplitHostPort(v); err != nil || port == "" {
+		return fmt.Errorf("%q is not a host:port address, e.g. localhost:2947", v)
+	}
+
+	return nil
+}
+
+func validateURL(v string) error {
+	u, err := url.ParseRequestURI(v)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL, e.g. https://ipinfo.io", v)
+	}
+
+	return nil
+}
+
+func validateBroker(v string) error {
+	u, err := url.Parse(v)
+
+	if err != nil || u.Host == "" || !slices.Contains([]string{"tcp", "mqtt", "ssl", "tls", "mqtts"}, u.Scheme) {
+		return fmt.Errorf("%q is not an MQTT broker URL, e.g. tcp://localhost:1883 or ssl://localhost:8883", v)
+	}
+
+	return nil
+}
+
+func validateFile(v string) error {
+	info, err := os.Stat(v)
+
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", v, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory, expected a file", v)
+	}
+
+	return nil
+}
+
+func validateDuration(v string) error {
+	d, err := time.ParseDuration(v)
+
+	if err != nil || d < 0 {
+		return fmt.Errorf("%q is not a duration, e.g. 30m, 6h or 0 to disable", v)
+	}
+
+	return nil
+}
+
+// func lookupSetting returns the setting for a key or environment variable
+// name, e.g. "ipinfo_token" or "IPINFO_TOKEN".
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if strings.EqualFold(s.key, key) || strings.EqualFold(s.env, key) {
+			return s, true
+		}
+	}
+
+	return setting{}, false
+}
+
+// func lookup returns the value of a setting from the environment, the file
+// (profile) or the defaults, along with its source. Flags are not
+// considered.
+func (c *conf) lookup(s setting) (string, string) {
+	if v, ok := os.LookupEnv(s.env); ok && v != "" {
+		return v, sourceEnv
+	}
+
+	values, _ := c.layer()
+
+	if v, ok := values[s.key]; ok {
+		return fmt.Sprint(v), sourceFile
+	}
+
+	return s.def, sourceDefault
+}
+
+// func definesFlag reports whether the command of ctx defines a flag itself.
+func definesFlag(ctx *cli.Context, name string) bool {
+	if ctx.Command == nil {
+		return false
+	}
+
+	for _, f := range ctx.Command.Flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// func setLocally reports whether a flag was given on the command line of
+// ctx's own command.
+func setLocally(ctx *cli.Context, name string) bool {
+	for _, n := range ctx.LocalFlagNames() {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flags that select a location, which take precedence over the configured
+// default location.
+var locationFlags = []string{"city", "zip", "pt", "place", "ip", "gps"}
+
+// Shared flags without a setting, which are only inherited from a parent
+// command.
+var inheritedFlags = []string{"extended", "interactive", "refresh", "template", "template-file", "gps-timeout", "gps-accuracy"}
+
+// func apply is a Before hook that selects the --profile and fills in the
+// flags of the current command that were not given on the command line:
+// first from a parent command's flags (e.g. "geocast --city Austin
+// forecast"), then from the environment and config file.
+func (c *conf) apply(ctx *cli.Context) error {
+	if definesFlag(ctx, "profile") && ctx.String("profile") != "" {
+		if err := c.UseProfile(ctx.String("profile")); err != nil {
+			return err
+		}
+	}
+
+	located := false
+
+	for _, name := range locationFlags {
+		if !definesFlag(ctx, name) || setLocally(ctx, name) {
+			located = located || ctx.IsSet(name)
+
+			continue
+		}
+
+		if c.inherit(ctx, name) {
+			located = true
+		}
+	}
+
+	for _, name := range inheritedFlags {
+		if definesFlag(ctx, name) && !setLocally(ctx, name) {
+			c.inherit(ctx, name)
+		}
+	}
+
+	for _, s := range settings {
+		if s.flag == "" || !definesFlag(ctx, s.flag) || setLocally(ctx, s.flag) || c.inherit(ctx, s.flag) {
+			continue
+		}
+
+		if value, source := c.lookup(s); source != sourceDefault {
+			if err := c.set(ctx, s.flag, value); err != nil {
+				return fmt.Errorf("invalid %s %q from %s: %w", s.key, value, source, err)
+			}
+		}
+	}
+
+	loc, _ := lookupSetting("location")
+
+	// A positional location (e.g. "geocast Austin") also overrides the default,
+	// but a subcommand name does not.
+	positional := ctx.Args().Len() > 0 && ctx.App.Command(ctx.Args().First()) == nil
+
+	if location, source := c.lookup(loc); !located && !positional && source != sourceDefault {
+		return c.applyLocation(ctx, location)
+	}
+
+	return nil
+}
+
+// func set sets a flag from the configuration, remembering that it was not
+// given on the command line.
+func (c *conf) set(ctx *cli.Context, name, value string) error {
+	c.applied[name] = true
+
+	return ctx.Set(name, value)
+}
+
+// func inherit copies a flag given to a parent command on the command line,
+// returning whether it was set.
+func (c *conf) inherit(ctx *cli.Context, name string) bool {
+	if c.applied[name] {
+		return false
+	}
+
+	for _, parent := range ctx.Lineage()[1:] {
+		if parent.Command == nil || !definesFlag(parent, name) || !setLocally(parent, name) {
+			continue
+		}
+
+		value := parent.Value(name)
+
+		if slice, ok := value.(cli.StringSlice); ok {
+			value = strings.Join(slice.Value(), ",")
+		}
+
+		ctx.Set(name, fmt.Sprint(value))
+
+		return true
+	}
+
+	return false
+}
+
+// func applyLocation sets the flag matching the configured default location
+// (see resolveArg): --place for saved places, --zip for ZIP codes, --pt for
+// coordinates, --ip for IP addresses and --city otherwise.
+func (c *conf) applyLocation(ctx *cli.Context, location string) error {
+	name := resolveArg(location).kind
+
+	if name == locationDevice {
+		return nil
+	}
+
+	if !definesFlag(ctx, name) {
+		return nil
+	}
+
+	c.log.Debug(fmt.Sprintf("Using default location %s (--%s).", location, name))
+
+	return c.set(ctx, name, location)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/desertthunder/weather/cmd/cli"
+	"github.com/desertthunder/weather/internal/logger"
 )
 
 // func main is the entrypoint for the CLI.
@@ -15,6 +16,6 @@ func main() {
 	app := cli.Application()
 
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		log.Fatal(logger.Redact(err.Error()))
 	}
 }
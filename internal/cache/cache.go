@@ -0,0 +1,149 @@
+// Package cache is a small JSON file store for values that are expensive to
+// fetch, such as the device's geolocation.
+//
+// Each key is stored in its own file in the cache directory along with the
+// time it was saved and an optional tag (e.g. a network fingerprint) used to
+// invalidate it.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ErrMiss is returned when a key is not cached.
+var ErrMiss = errors.New("cache miss")
+
+// Characters that are not allowed in cache file names.
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// struct Meta describes a cached value.
+type Meta struct {
+	Saved time.Time `json:"saved"`
+	Tag   string    `json:"tag,omitempty"`
+}
+
+// Age returns how long ago the value was saved.
+func (m Meta) Age() time.Duration {
+	return time.Since(m.Saved)
+}
+
+// Fresh reports whether the value is younger than ttl and was saved with the
+// given tag.
+func (m Meta) Fresh(ttl time.Duration, tag string) bool {
+	return m.Tag == tag && m.Age() < ttl
+}
+
+type entry struct {
+	Meta
+	Value json.RawMessage `json:"value"`
+}
+
+// struct Store is a directory of cached JSON values.
+type Store struct {
+	Dir string
+}
+
+// Store constructor.
+func New(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// func Default returns the store in the user's cache directory, e.g.
+// ~/.cache/geocast on Linux.
+func Default() (*Store, error) {
+	dir, err := os.UserCacheDir()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return New(filepath.Join(dir, "geocast")), nil
+}
+
+// Path returns the file used for key.
+func (s *Store) Path(key string) string {
+	return filepath.Join(s.Dir, unsafe.ReplaceAllString(key, "_")+".json")
+}
+
+// func Get decodes the value cached for key into v. ErrMiss is returned when
+// there is no (readable) value.
+func (s *Store) Get(key string, v any) (Meta, error) {
+	data, err := os.ReadFile(s.Path(key))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return Meta{}, ErrMiss
+	}
+
+	if err != nil {
+		return Meta{}, err
+	}
+
+	e := entry{}
+
+	if err := json.Unmarshal(data, &e); err != nil || len(e.Value) == 0 {
+		return Meta{}, ErrMiss
+	}
+
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		return Meta{}, ErrMiss
+	}
+
+	return e.Meta, nil
+}
+
+// func Put caches v for key with a tag. The file is written atomically so
+// concurrent invocations never read a partial value.
+func (s *Store) Put(key, tag string, v any) error {
+	value, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry{Meta{Saved: time.Now(), Tag: tag}, value}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path(key))
+}
+
+// func Delete removes the value cached for key, if any.
+func (s *Store) Delete(key string) error {
+	err := os.Remove(s.Path(key))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,96 @@
+// Submodule fetch caches the results of requests, such as forecasts, and
+// falls back to expired values when a request fails.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Results of Fetch, reported to Fetcher.Observe.
+const (
+	ResultHit  string = "hit"
+	ResultMiss string = "miss"
+	// An expired value was returned because fetching failed.
+	ResultStale string = "stale"
+)
+
+// struct Fetcher configures Fetch.
+type Fetcher struct {
+	// Cache directory, or nil to always fetch.
+	Store *Store
+	// Lifetime of cached values, zero to always fetch.
+	TTL time.Duration
+	// Values cached with another tag are never used, e.g. values fetched
+	// from another server.
+	Tag string
+	// Skip cached values (but still save the new ones).
+	Refresh bool
+	Log     *log.Logger
+	// Called with the result of each lookup, e.g. for metrics.
+	Observe func(result string)
+}
+
+func (f Fetcher) observe(result string) {
+	if f.Observe != nil {
+		f.Observe(result)
+	}
+}
+
+// func Fetch returns the value cached for key when it is fresh, and otherwise
+// calls fetch and caches its result. When fetch fails, an expired value is
+// better than nothing and is returned instead, with a warning.
+func Fetch[T any](f Fetcher, key string, fetch func() (T, error)) (T, error) {
+	if f.Store == nil || f.TTL <= 0 {
+		return fetch()
+	}
+
+	logger := f.Log
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	var cached T
+
+	meta, err := f.Store.Get(key, &cached)
+
+	if err == nil && meta.Fresh(f.TTL, f.Tag) && !f.Refresh {
+		logger.Debug(fmt.Sprintf("Using %s cached %s ago.", key, meta.Age().Round(time.Second)))
+		f.observe(ResultHit)
+
+		return cached, nil
+	}
+
+	if err != nil && !errors.Is(err, ErrMiss) {
+		logger.Debug(fmt.Sprintf("Could not read cached %s: %s", key, err.Error()))
+	}
+
+	stale := err == nil && meta.Tag == f.Tag
+
+	v, ferr := fetch()
+
+	if ferr != nil {
+		if stale {
+			logger.Warn(fmt.Sprintf("%s, using %s cached %s ago.", ferr.Error(), key, meta.Age().Round(time.Second)))
+			f.observe(ResultStale)
+
+			return cached, nil
+		}
+
+		f.observe(ResultMiss)
+
+		return v, ferr
+	}
+
+	f.observe(ResultMiss)
+
+	if err := f.Store.Put(key, f.Tag, v); err != nil {
+		logger.Debug(fmt.Sprintf("Could not cache %s: %s", key, err.Error()))
+	}
+
+	return v, nil
+}
@@ -0,0 +1,316 @@
+// Package coords parses the coordinate formats accepted by geocast:
+//
+//   - decimal degrees, optionally with hemisphere letters
+//     ("30.2672,-97.7431", "30.2672N 97.7431W")
+//   - degrees, minutes and seconds ("30°16'2\"N 97°44'35\"W", "30 16 2 N 97 44 35 W")
+//   - Open Location Codes / Plus Codes ("849VCWC8+R9")
+//   - geohashes of 5 or more characters ("9v6kpm"), or of any length with a
+//     "geohash:" prefix ("geohash:9v6k")
+//   - MGRS ("14RPU2116049893") and UTM ("14R 621160 3349893")
+package coords
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// struct Point is a validated WGS84 latitude/longitude pair.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// String formats the point as "lat,lon".
+func (p Point) String() string {
+	return fmt.Sprintf("%f,%f", p.Lat, p.Lon)
+}
+
+// Validate checks that the latitude and longitude are in range.
+func (p Point) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude %f is out of range [-90, 90]", p.Lat)
+	}
+
+	if p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("longitude %f is out of range [-180, 180]", p.Lon)
+	}
+
+	return nil
+}
+
+var (
+	// ErrEmpty is returned when there is nothing to parse.
+	ErrEmpty = errors.New("no coordinates provided")
+	// ErrUnrecognized is returned when the input matches none of the formats.
+	ErrUnrecognized = errors.New("unrecognized coordinate format")
+)
+
+var (
+	mgrsPattern = regexp.MustCompile(`^(\d{1,2})([C-HJ-NP-X])([A-HJ-NP-Z]{2})(\d*)$`)
+	utmPattern  = regexp.MustCompile(`^(\d{1,2})\s*([C-HJ-NP-X])\s+(\d+(?:\.\d+)?)\s*(?:m?E)?\s+(\d+(?:\.\d+)?)\s*(?:m?N)?$`)
+	token       = regexp.MustCompile(`[NSEWnsew]|[-+]?\d+(?:\.\d+)?`)
+)
+
+// func Parse detects the format of s and converts it to a point.
+func Parse(s string) (Point, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return Point{}, ErrEmpty
+	}
+
+	p, err := parse(s)
+
+	if err != nil {
+		return Point{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return Point{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	return p, nil
+}
+
+func parse(s string) (Point, error) {
+	lower := strings.ToLower(s)
+
+	switch {
+	case strings.HasPrefix(lower, "geohash:"):
+		return ParseGeohash(s[len("geohash:"):])
+	case strings.HasPrefix(lower, "mgrs:"):
+		return ParseMGRS(s[len("mgrs:"):])
+	case strings.HasPrefix(lower, "utm:"):
+		return ParseUTM(s[len("utm:"):])
+	case strings.Contains(s, "+") && !strings.ContainsAny(s, " ,"):
+		return ParsePlusCode(s)
+	}
+
+	upper := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+
+	if mgrsPattern.MatchString(upper) {
+		return ParseMGRS(upper)
+	}
+
+	if utmPattern.MatchString(strings.ToUpper(s)) {
+		return ParseUTM(s)
+	}
+
+	p, err := ParseDegrees(s)
+
+	if err != nil && isGeohash(lower) {
+		return ParseGeohash(lower)
+	}
+
+	return p, err
+}
+
+type component struct {
+	values []float64
+	hemi   byte
+}
+
+// func ParseDegrees parses a latitude/longitude pair written in decimal
+// degrees, degrees and decimal minutes, or degrees, minutes and seconds, with
+// either signs or hemisphere letters (as prefixes or suffixes).
+func ParseDegrees(s string) (Point, error) {
+	cleaned := strings.NewReplacer(
+		"°", " ", "º", " ", "′", " ", "″", " ", "'", " ", "\"", " ",
+		"’", " ", "”", " ",
+	).Replace(s)
+
+	halves := strings.Split(cleaned, ",")
+
+	var parts []component
+	var err error
+
+	switch len(halves) {
+	case 2:
+		parts = make([]component, 2)
+
+		for i, h := range halves {
+			c, err := components(h)
+
+			if err != nil {
+				return Point{}, err
+			}
+
+			if len(c) != 1 {
+				return Point{}, ErrUnrecognized
+			}
+
+			parts[i] = c[0]
+		}
+	case 1:
+		parts, err = components(cleaned)
+
+		if err != nil {
+			return Point{}, err
+		}
+	default:
+		return Point{}, ErrUnrecognized
+	}
+
+	if len(parts) != 2 {
+		return Point{}, ErrUnrecognized
+	}
+
+	a, err := parts[0].degrees()
+
+	if err != nil {
+		return Point{}, err
+	}
+
+	b, err := parts[1].degrees()
+
+	if err != nil {
+		return Point{}, err
+	}
+
+	isLon := func(h byte) bool { return h == 'E' || h == 'W' }
+	isLat := func(h byte) bool { return h == 'N' || h == 'S' }
+
+	if isLat(parts[0].hemi) && isLat(parts[1].hemi) || isLon(parts[0].hemi) && isLon(parts[1].hemi) {
+		return Point{}, errors.New("both coordinates use the same axis")
+	}
+
+	if isLon(parts[0].hemi) || isLat(parts[1].hemi) {
+		a, b = b, a
+	}
+
+	return Point{Lat: a, Lon: b}, nil
+}
+
+// components splits a run of numbers and hemisphere letters into one or two
+// coordinates.
+func components(s string) ([]component, error) {
+	tokens := token.FindAllString(s, -1)
+
+	if strings.TrimSpace(token.ReplaceAllString(s, "")) != "" {
+		return nil, ErrUnrecognized
+	}
+
+	if len(tokens) == 0 {
+		return nil, ErrUnrecognized
+	}
+
+	isHemi := func(t string) bool { return len(t) == 1 && strings.ContainsAny(t, "NSEWnsew") }
+	prefix := isHemi(tokens[0])
+	hasHemi := false
+
+	for _, t := range tokens {
+		hasHemi = hasHemi || isHemi(t)
+	}
+
+	parts := []component{}
+	curr := component{}
+
+	flush := func() {
+		if len(curr.values) > 0 || curr.hemi != 0 {
+			parts = append(parts, curr)
+		}
+
+		curr = component{}
+	}
+
+	for _, t := range tokens {
+		if isHemi(t) {
+			h := strings.ToUpper(t)[0]
+
+			if prefix {
+				flush()
+				curr.hemi = h
+			} else {
+				curr.hemi = h
+				flush()
+			}
+
+			continue
+		}
+
+		v, err := strconv.ParseFloat(t, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		curr.values = append(curr.values, v)
+	}
+
+	flush()
+
+	if hasHemi {
+		return parts, nil
+	}
+
+	// Without hemisphere letters the numbers are split evenly, e.g. two
+	// decimal values, four degree/minute values or six DMS values.
+	if len(parts) != 1 {
+		return nil, ErrUnrecognized
+	}
+
+	values := parts[0].values
+
+	if len(values) == 1 {
+		return parts, nil
+	}
+
+	if len(values)%2 != 0 || len(values) > 6 {
+		return nil, ErrUnrecognized
+	}
+
+	half := len(values) / 2
+
+	return []component{{values: values[:half]}, {values: values[half:]}}, nil
+}
+
+// degrees converts the degree/minute/second values of a component to signed
+// decimal degrees.
+func (c component) degrees() (float64, error) {
+	if len(c.values) == 0 || len(c.values) > 3 {
+		return 0, ErrUnrecognized
+	}
+
+	deg := c.values[0]
+	negative := math.Signbit(deg)
+
+	if negative {
+		deg = -deg
+	}
+
+	for i, v := range c.values[1:] {
+		if v < 0 || v >= 60 {
+			return 0, fmt.Errorf("%s must be in the range [0, 60)", []string{"minutes", "seconds"}[i])
+		}
+
+		if deg != float64(int(deg)) || (i == 0 && len(c.values) == 3 && v != float64(int(v))) {
+			return 0, errors.New("only the last component may have a fractional part")
+		}
+	}
+
+	if len(c.values) > 1 {
+		deg += c.values[1] / 60
+	}
+
+	if len(c.values) > 2 {
+		deg += c.values[2] / 3600
+	}
+
+	if c.hemi == 'S' || c.hemi == 'W' {
+		if negative {
+			return 0, errors.New("use either a sign or a hemisphere letter, not both")
+		}
+
+		negative = true
+	}
+
+	if negative {
+		deg = -deg
+	}
+
+	return deg, nil
+}
@@ -0,0 +1,150 @@
+// Submodule grid decodes the grid based formats: Open Location Codes (Plus
+// Codes) and geohashes.
+package coords
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	geohashAlphabet  string = "0123456789bcdefghjkmnpqrstuvwxyz"
+	plusCodeAlphabet string = "23456789CFGHJMPQRVWX"
+	// Position of the "+" separator in a full Plus Code.
+	plusCodeSeparator int = 8
+	// Number of characters encoded as lat/lon pairs before the grid refinement.
+	plusCodePairs int = 10
+)
+
+// Shortest geohash read without a "geohash:" prefix. Shorter ones describe
+// cells too large for a forecast and look like names ("K2", "b52", "dc3").
+const minGeohashLength int = 5
+
+// isGeohash reports whether s should be read as a geohash: it must have at
+// least minGeohashLength characters of the geohash alphabet and mix letters
+// and digits so that plain numbers (e.g. ZIP codes), words (e.g. "bend") and
+// short names are not mistaken for one. Other geohashes can be given with a
+// "geohash:" prefix.
+func isGeohash(s string) bool {
+	if len(s) < minGeohashLength || len(s) > 12 || !strings.ContainsAny(s, geohashAlphabet[10:]) || !strings.ContainsAny(s, geohashAlphabet[:10]) {
+		return false
+	}
+
+	for _, r := range s {
+		if !strings.ContainsRune(geohashAlphabet, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// func ParseGeohash returns the center of the cell described by a geohash.
+func ParseGeohash(s string) (Point, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if s == "" || len(s) > 12 {
+		return Point{}, errors.New("geohash must be 1-12 characters")
+	}
+
+	lat := [2]float64{-90, 90}
+	lon := [2]float64{-180, 180}
+	even := true
+
+	for _, r := range s {
+		idx := strings.IndexRune(geohashAlphabet, r)
+
+		if idx < 0 {
+			return Point{}, errors.New("invalid geohash character " + string(r))
+		}
+
+		for bit := 4; bit >= 0; bit-- {
+			rng := &lat
+
+			if even {
+				rng = &lon
+			}
+
+			mid := (rng[0] + rng[1]) / 2
+
+			if idx&(1<<bit) != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+
+			even = !even
+		}
+	}
+
+	return Point{Lat: (lat[0] + lat[1]) / 2, Lon: (lon[0] + lon[1]) / 2}, nil
+}
+
+// func ParsePlusCode returns the center of the area described by a full Open
+// Location Code, e.g. "849VCWC8+R9". Short codes need a reference location
+// and are rejected.
+func ParsePlusCode(s string) (Point, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	sep := strings.Index(code, "+")
+
+	if sep < 0 || strings.Count(code, "+") != 1 {
+		return Point{}, errors.New("plus code must contain a single '+'")
+	}
+
+	if sep < plusCodeSeparator {
+		return Point{}, errors.New("short plus codes need a reference location; use the full code")
+	}
+
+	if sep > plusCodeSeparator {
+		return Point{}, errors.New("plus code has too many digits before '+'")
+	}
+
+	digits := code[:sep]
+
+	// Padding zeros may only follow complete pairs and must reach the separator.
+	if pad := strings.Index(digits, "0"); pad >= 0 {
+		if pad%2 != 0 || strings.Trim(digits[pad:], "0") != "" || len(code) > sep+1 {
+			return Point{}, errors.New("invalid plus code padding")
+		}
+
+		digits = digits[:pad]
+	}
+
+	digits += code[sep+1:]
+
+	if len(digits) < plusCodePairs && len(digits)%2 != 0 {
+		return Point{}, errors.New("plus code has an incomplete pair")
+	}
+
+	lat, lon := -90.0, -180.0
+	latRes, lonRes := 400.0, 400.0
+
+	for i, r := range digits {
+		idx := strings.IndexRune(plusCodeAlphabet, r)
+
+		if idx < 0 {
+			return Point{}, errors.New("invalid plus code character " + string(r))
+		}
+
+		switch {
+		case i < plusCodePairs && i%2 == 0:
+			latRes /= 20
+			lat += float64(idx) * latRes
+		case i < plusCodePairs:
+			lonRes /= 20
+			lon += float64(idx) * lonRes
+		default:
+			// Grid refinement: 5 rows by 4 columns per character.
+			latRes /= 5
+			lonRes /= 4
+			lat += float64(idx/4) * latRes
+			lon += float64(idx%4) * lonRes
+		}
+	}
+
+	if lat >= 90 || lon >= 180 {
+		return Point{}, errors.New("plus code is out of range")
+	}
+
+	return Point{Lat: lat + latRes/2, Lon: lon + lonRes/2}, nil
+}
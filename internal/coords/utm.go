@@ -0,0 +1,185 @@
+// Submodule utm converts UTM and MGRS grid references to latitude and
+// longitude on the WGS84 ellipsoid.
+package coords
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// WGS84 semi-major axis in meters.
+	wgs84A float64 = 6378137.0
+	// WGS84 first eccentricity squared.
+	wgs84E2 float64 = 0.00669437999014
+	// UTM scale factor on the central meridian.
+	utmK0 float64 = 0.9996
+	// Latitude band letters from 80°S to 84°N, 8° each (X is 12°).
+	bands string = "CDEFGHJKLMNPQRSTUVWX"
+	// MGRS 100 km column letters, one set per zone modulo 3.
+	mgrsColumns string = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// MGRS 100 km row letters, repeating every 2,000 km.
+	mgrsRows string = "ABCDEFGHJKLMNPQRSTUV"
+)
+
+// Lowest northing (in meters, modulo 2,000 km) of each latitude band, used
+// to resolve the repeating MGRS row letters.
+var bandMinNorthing = map[byte]float64{
+	'C': 1100000, 'D': 2000000, 'E': 2800000, 'F': 3700000, 'G': 4600000,
+	'H': 5500000, 'J': 6400000, 'K': 7300000, 'L': 8200000, 'M': 9100000,
+	'N': 0, 'P': 800000, 'Q': 1700000, 'R': 2600000, 'S': 3500000,
+	'T': 4400000, 'U': 5300000, 'V': 6200000, 'W': 7000000, 'X': 7900000,
+}
+
+// func ParseUTM parses a UTM reference written as zone, latitude band,
+// easting and northing, e.g. "14R 621160 3349893".
+func ParseUTM(s string) (Point, error) {
+	m := utmPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+
+	if m == nil {
+		return Point{}, errors.New("expected a UTM reference like 14R 621160 3349893")
+	}
+
+	zone, band, err := zoneAndBand(m[1], m[2])
+
+	if err != nil {
+		return Point{}, err
+	}
+
+	easting, _ := strconv.ParseFloat(m[3], 64)
+	northing, _ := strconv.ParseFloat(m[4], 64)
+
+	return fromUTM(zone, band, easting, northing)
+}
+
+// func ParseMGRS parses a Military Grid Reference System reference, e.g.
+// "18SUJ2348706483" or "18S UJ 23487 06483". The point returned is the
+// south-west corner of the referenced square.
+func ParseMGRS(s string) (Point, error) {
+	ref := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	m := mgrsPattern.FindStringSubmatch(ref)
+
+	if m == nil {
+		return Point{}, errors.New("expected an MGRS reference like 18SUJ2348706483")
+	}
+
+	zone, band, err := zoneAndBand(m[1], m[2])
+
+	if err != nil {
+		return Point{}, err
+	}
+
+	digits := m[4]
+
+	if len(digits)%2 != 0 || len(digits) > 10 {
+		return Point{}, errors.New("MGRS easting and northing must have the same number of digits (at most 5 each)")
+	}
+
+	// Column letters cycle through three sets of eight, by zone.
+	set := (zone - 1) % 3
+	col := strings.IndexByte(mgrsColumns[set*8:set*8+8], m[3][0])
+
+	if col < 0 {
+		return Point{}, fmt.Errorf("column letter %c is not valid in zone %d", m[3][0], zone)
+	}
+
+	// Even zones start their row letters at F.
+	row := strings.IndexByte(mgrsRows, m[3][1])
+
+	if row < 0 {
+		return Point{}, fmt.Errorf("row letter %c is not valid", m[3][1])
+	}
+
+	if zone%2 == 0 {
+		row = (row - 5 + len(mgrsRows)) % len(mgrsRows)
+	}
+
+	easting := float64(col+1) * 100000
+	northing := float64(row) * 100000
+
+	for northing < bandMinNorthing[band] {
+		northing += 2000000
+	}
+
+	if half := len(digits) / 2; half > 0 {
+		scale := math.Pow(10, float64(5-half))
+		e, _ := strconv.ParseFloat(digits[:half], 64)
+		n, _ := strconv.ParseFloat(digits[half:], 64)
+
+		easting += e * scale
+		northing += n * scale
+	}
+
+	return fromUTM(zone, band, easting, northing)
+}
+
+func zoneAndBand(z, b string) (int, byte, error) {
+	zone, err := strconv.Atoi(z)
+
+	if err != nil || zone < 1 || zone > 60 {
+		return 0, 0, fmt.Errorf("UTM zone %s is out of range [1, 60]", z)
+	}
+
+	if !strings.Contains(bands, b) {
+		return 0, 0, fmt.Errorf("invalid latitude band %s", b)
+	}
+
+	return zone, b[0], nil
+}
+
+// fromUTM converts a UTM easting/northing to latitude and longitude using
+// the series expansion from Snyder's "Map Projections: A Working Manual".
+func fromUTM(zone int, band byte, easting, northing float64) (Point, error) {
+	if easting < 100000 || easting > 900000 {
+		return Point{}, fmt.Errorf("easting %.0f is out of range", easting)
+	}
+
+	if northing < 0 || northing > 10000000 {
+		return Point{}, fmt.Errorf("northing %.0f is out of range", northing)
+	}
+
+	// Bands before N are in the southern hemisphere, which uses a false
+	// northing of 10,000 km.
+	if band < 'N' {
+		northing -= 10000000
+	}
+
+	e2 := wgs84E2
+	ep2 := e2 / (1 - e2)
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+
+	x := easting - 500000
+	m := northing / utmK0
+	mu := m / (wgs84A * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+
+	phi := mu +
+		(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+
+	n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t1 := tan * tan
+	c1 := ep2 * cos * cos
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	d := x / (n1 * utmK0)
+
+	lat := phi - (n1*tan/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+
+	lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos
+
+	centralMeridian := float64(zone-1)*6 - 180 + 3
+
+	return Point{
+		Lat: lat * 180 / math.Pi,
+		Lon: centralMeridian + lon*180/math.Pi,
+	}, nil
+}
@@ -0,0 +1,150 @@
+// Package fuzzy implements approximate string matching used to suggest place
+// names for misspelled queries ("Clevland" -> "Cleveland").
+//
+// Candidates are scored by averaging a normalized edit distance similarity
+// with a trigram (Jaccard) similarity, both in the range [0, 1].
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Candidates scoring below this are never suggested.
+const DefaultThreshold float64 = 0.5
+
+// struct Match is a candidate string and its similarity to the query.
+type Match struct {
+	Value string
+	Score float64
+	// Index of the value in the candidate list passed to Rank.
+	Index int
+}
+
+// func EditDistance returns the optimal string alignment distance between a
+// and b: the insertions, deletions, substitutions and adjacent transpositions
+// needed to turn a into b, without editing a substring more than once (so
+// unlike Damerau-Levenshtein, EditDistance("ca", "abc") is 3, not 2).
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	if len(ra) == 0 {
+		return len(rb)
+	}
+
+	if len(rb) == 0 {
+		return len(ra)
+	}
+
+	// Three rolling rows are enough for the transposition lookback.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+// func Trigrams returns the set of padded three character sequences in s.
+func Trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	r := []rune("  " + s + " ")
+
+	for i := 0; i+3 <= len(r); i++ {
+		set[string(r[i:i+3])] = struct{}{}
+	}
+
+	return set
+}
+
+// func Similarity scores how alike two strings are, from 0 (nothing in
+// common) to 1 (identical after lowercasing).
+func Similarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+
+	if a == b {
+		return 1
+	}
+
+	longest := max(len([]rune(a)), len([]rune(b)))
+	edit := 1 - float64(EditDistance(a, b))/float64(longest)
+
+	ta, tb := Trigrams(a), Trigrams(b)
+	shared := 0
+
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+
+	jaccard := float64(shared) / float64(len(ta)+len(tb)-shared)
+
+	return (edit + jaccard) / 2
+}
+
+// func Rank scores every candidate against the query and returns the matches
+// at or above the threshold, best first. Ties keep the candidate order, so
+// callers can pre-sort candidates by importance (e.g. population).
+func Rank(query string, candidates []string, threshold float64) []Match {
+	matches := []Match{}
+
+	for i, c := range candidates {
+		score := Similarity(query, c)
+
+		if score >= threshold {
+			matches = append(matches, Match{Value: c, Score: score, Index: i})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches
+}
+
+// func Suggest returns up to limit candidate values that closely match the
+// query, best first, without duplicates.
+func Suggest(query string, candidates []string, limit int) []string {
+	suggestions := []string{}
+	seen := map[string]bool{}
+
+	for _, m := range Rank(query, candidates, DefaultThreshold) {
+		if seen[m.Value] {
+			continue
+		}
+
+		seen[m.Value] = true
+		suggestions = append(suggestions, m.Value)
+
+		if limit > 0 && len(suggestions) == limit {
+			break
+		}
+	}
+
+	return suggestions
+}
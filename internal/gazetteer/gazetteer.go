@@ -0,0 +1,477 @@
+// Package gazetteer provides an offline geocoder backed by a compact, embedded
+// list of populated places.
+//
+// The data file (data/cities.tsv.gz) is gzipped and tab separated, with the
+// columns:
+//
+//	name	admin1	country	latitude	longitude	population
+//
+// The bundled file is a hand-compiled sample of 286 US places, mostly the
+// larger cities and state capitals, with rounded coordinates and approximate
+// US Census population estimates; it is not a GeoNames extract. `make
+// gazetteer` replaces it with the full cities1000 extract (places with a
+// population over 1000), and `make gazetteer GEONAMES=cities5000` with the
+// smaller cities5000 cut, both from https://download.geonames.org/export/dump/,
+// in the same format.
+package gazetteer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/desertthunder/weather/internal/fuzzy"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+//go:embed data/cities.tsv.gz
+var bundled []byte
+
+// Mean radius of the earth in kilometers, used for great-circle distances.
+const earthRadiusKm float64 = 6371.0
+
+// Size (in degrees) of a cell in the spatial grid used for reverse lookups.
+const cellSize float64 = 1.0
+
+var (
+	once     sync.Once
+	instance *Gazetteer
+	loadErr  error
+)
+
+// struct Place is a single populated place from the gazetteer.
+type Place struct {
+	Name       string
+	Admin1     string
+	Country    string
+	Lat        float64
+	Lon        float64
+	Population int
+}
+
+// Label returns the display name of the place, e.g. "Austin, TX, US".
+func (p Place) Label() string {
+	parts := []string{p.Name}
+
+	for _, s := range []string{p.Admin1, p.Country} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// City converts the place to a City object.
+func (p Place) City() nws.City {
+	return nws.City{Name: p.Label(), Lat: p.Lat, Long: p.Lon}
+}
+
+type cell struct {
+	lat int
+	lon int
+}
+
+// struct Gazetteer is an in-memory index over a list of places supporting
+// exact and prefix name lookups as well as nearest-place reverse lookups.
+type Gazetteer struct {
+	places []Place
+	// normalized name -> indices into places, ordered by population.
+	names map[string][]int
+	// sorted, de-duplicated normalized names for prefix lookups.
+	keys []string
+	// spatial grid of cellSize degree cells -> indices into places.
+	grid map[cell][]int
+}
+
+// func Default returns the gazetteer built from the bundled data file. The
+// file is only decompressed and indexed the first time it is requested.
+func Default() (*Gazetteer, error) {
+	once.Do(func() {
+		var r *gzip.Reader
+
+		r, loadErr = gzip.NewReader(bytes.NewReader(bundled))
+
+		if loadErr != nil {
+			return
+		}
+
+		defer r.Close()
+
+		instance, loadErr = New(r)
+	})
+
+	return instance, loadErr
+}
+
+// func New reads tab separated place records from r and indexes them.
+func New(r io.Reader) (*Gazetteer, error) {
+	places := []Place{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := scanner.Text()
+
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		p, err := parsePlace(text)
+
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %w", line, err)
+		}
+
+		places = append(places, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return index(places), nil
+}
+
+func parsePlace(text string) (Place, error) {
+	fields := strings.Split(text, "\t")
+
+	if len(fields) < 5 {
+		return Place{}, errors.New("expected at least 5 fields")
+	}
+
+	lat, err := strconv.ParseFloat(fields[3], 64)
+
+	if err != nil {
+		return Place{}, err
+	}
+
+	lon, err := strconv.ParseFloat(fields[4], 64)
+
+	if err != nil {
+		return Place{}, err
+	}
+
+	p := Place{
+		Name:    fields[0],
+		Admin1:  fields[1],
+		Country: fields[2],
+		Lat:     lat,
+		Lon:     lon,
+	}
+
+	if len(fields) > 5 {
+		p.Population, _ = strconv.Atoi(fields[5])
+	}
+
+	return p, nil
+}
+
+func index(places []Place) *Gazetteer {
+	g := &Gazetteer{
+		places: places,
+		names:  map[string][]int{},
+		grid:   map[cell][]int{},
+	}
+
+	for i, p := range places {
+		key := Normalize(p.Name)
+
+		if _, ok := g.names[key]; !ok {
+			g.keys = append(g.keys, key)
+		}
+
+		g.names[key] = append(g.names[key], i)
+
+		c := cellOf(p.Lat, p.Lon)
+		g.grid[c] = append(g.grid[c], i)
+	}
+
+	for _, ids := range g.names {
+		sort.SliceStable(ids, func(a, b int) bool {
+			return places[ids[a]].Population > places[ids[b]].Population
+		})
+	}
+
+	sort.Strings(g.keys)
+
+	return g
+}
+
+// Len returns the number of places in the gazetteer.
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// Places returns every place in the gazetteer.
+func (g *Gazetteer) Places() []Place {
+	return g.places
+}
+
+// func Lookup returns the places whose name exactly matches the query, most
+// populous first.
+//
+// The query may be qualified with comma separated region or country parts,
+// e.g. "Portland, ME", "Portland, Maine" or "Portland, OR, US".
+func (g *Gazetteer) Lookup(q string) []Place {
+	name, qualifiers := splitQuery(q)
+	results := []Place{}
+
+	for _, i := range g.names[name] {
+		if matches(g.places[i], qualifiers) {
+			results = append(results, g.places[i])
+		}
+	}
+
+	return results
+}
+
+// func Prefix returns up to limit places whose name starts with the query,
+// ordered by population. A limit <= 0 means no limit.
+func (g *Gazetteer) Prefix(q string, limit int) []Place {
+	prefix, qualifiers := splitQuery(q)
+	results := []Place{}
+
+	if prefix == "" {
+		return results
+	}
+
+	start := sort.SearchStrings(g.keys, prefix)
+
+	for _, key := range g.keys[start:] {
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+
+		for _, i := range g.names[key] {
+			if matches(g.places[i], qualifiers) {
+				results = append(results, g.places[i])
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Population > results[b].Population
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// func Suggest returns up to limit places whose names are close to the query
+// (e.g. "Clevland" -> Cleveland), best match first and then by population.
+func (g *Gazetteer) Suggest(q string, limit int) []Place {
+	name, qualifiers := splitQuery(q)
+	results := []Place{}
+
+	if name == "" {
+		return results
+	}
+
+	for _, m := range fuzzy.Rank(name, g.keys, fuzzy.DefaultThreshold) {
+		for _, i := range g.names[m.Value] {
+			if !matches(g.places[i], qualifiers) {
+				continue
+			}
+
+			results = append(results, g.places[i])
+
+			if limit > 0 && len(results) == limit {
+				return results
+			}
+		}
+	}
+
+	return results
+}
+
+// func Nearest returns the place closest to the given point along with its
+// distance in kilometers.
+//
+// The search walks outward through the spatial grid one ring of cells at a
+// time and stops once no unvisited cell can contain a closer place.
+func (g *Gazetteer) Nearest(lat, lon float64) (Place, float64, error) {
+	if len(g.places) == 0 {
+		return Place{}, 0, errors.New("gazetteer is empty")
+	}
+
+	origin := cellOf(lat, lon)
+	best := -1
+	bestDist := math.Inf(1)
+	maxRing := int(180 / cellSize)
+
+	for ring := 0; ring <= maxRing; ring++ {
+		for _, c := range ringCells(origin, ring) {
+			for _, i := range g.grid[c] {
+				d := Distance(lat, lon, g.places[i].Lat, g.places[i].Lon)
+
+				if d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+		}
+
+		// Every cell beyond this ring is at least ring * cellSize degrees away.
+		// Longitude degrees shrink toward the poles, so the bound is scaled by
+		// the cosine of the most poleward latitude the next ring can reach.
+		poleward := math.Min(89, math.Abs(lat)+float64(ring+1)*cellSize)
+		bound := float64(ring) * cellSize * (math.Pi / 180) * earthRadiusKm * math.Cos(poleward*math.Pi/180)
+
+		if best >= 0 && bound > bestDist {
+			break
+		}
+	}
+
+	if best < 0 {
+		return Place{}, 0, errors.New("no places found near the provided point")
+	}
+
+	return g.places[best], bestDist, nil
+}
+
+// GeocodeByCity resolves a city name to the most populous matching place.
+func (g *Gazetteer) GeocodeByCity(c string) (*nws.City, error) {
+	results := g.Lookup(c)
+
+	if len(results) == 0 {
+		results = g.Prefix(c, 1)
+	}
+
+	if len(results) == 0 {
+		return nil, errors.New("no results found for the provided city name")
+	}
+
+	city := results[0].City()
+
+	return &city, nil
+}
+
+// GeocodeByPoint names a point after the nearest known place. The returned
+// City keeps the requested coordinates.
+func (g *Gazetteer) GeocodeByPoint(lat, lon float64) (*nws.City, error) {
+	place, _, err := g.Nearest(lat, lon)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &nws.City{Name: place.Label(), Lat: lat, Long: lon}, nil
+}
+
+// func Distance returns the great-circle distance in kilometers between two
+// points using the haversine formula.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// func Normalize lowercases a place name and collapses punctuation and
+// whitespace so that "St. Louis" and "st louis" compare equal.
+func Normalize(s string) string {
+	s = strings.ToLower(s)
+
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '\'', '’':
+			return -1
+		case '-', '_':
+			return ' '
+		}
+
+		return r
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func splitQuery(q string) (string, []string) {
+	parts := strings.Split(q, ",")
+	qualifiers := []string{}
+
+	for _, p := range parts[1:] {
+		if p = Normalize(p); p != "" {
+			qualifiers = append(qualifiers, p)
+		}
+	}
+
+	return Normalize(parts[0]), qualifiers
+}
+
+func matches(p Place, qualifiers []string) bool {
+	for _, q := range qualifiers {
+		switch q {
+		case Normalize(p.Admin1), Normalize(p.Country):
+			continue
+		}
+
+		if state, ok := states[strings.ToUpper(p.Admin1)]; ok && p.Country == "US" && q == Normalize(state) {
+			continue
+		}
+
+		if p.Country == "US" && (q == "usa" || q == "united states") {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func cellOf(lat, lon float64) cell {
+	return cell{
+		lat: int(math.Floor(lat / cellSize)),
+		lon: int(math.Floor(lon / cellSize)),
+	}
+}
+
+// ringCells returns the cells on the square ring at the given distance from
+// the origin, wrapping longitude at the antimeridian.
+func ringCells(origin cell, ring int) []cell {
+	if ring == 0 {
+		return []cell{origin}
+	}
+
+	cells := []cell{}
+	width := int(360 / cellSize)
+
+	wrap := func(lon int) int {
+		half := width / 2
+
+		return ((lon+half)%width+width)%width - half
+	}
+
+	for dLat := -ring; dLat <= ring; dLat++ {
+		step := 2 * ring
+
+		if dLat == -ring || dLat == ring {
+			step = 1
+		}
+
+		for dLon := -ring; dLon <= ring; dLon += step {
+			cells = append(cells, cell{lat: origin.lat + dLat, lon: wrap(origin.lon + dLon)})
+		}
+	}
+
+	return cells
+}
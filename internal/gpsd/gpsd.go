@@ -0,0 +1,181 @@
+// Package gpsd reads position fixes from a gpsd daemon using its JSON
+// protocol over TCP (https://gpsd.gitlab.io/gpsd/gpsd_json.html).
+package gpsd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// Default gpsd address.
+	DefaultAddress string = "localhost:2947"
+	// Default time to wait for a fix.
+	DefaultTimeout time.Duration = 10 * time.Second
+	// Default accuracy threshold in meters.
+	DefaultAccuracy float64 = 100
+)
+
+// Fix modes reported in TPV reports.
+const (
+	ModeUnknown = 0
+	ModeNoFix   = 1
+	Mode2D      = 2
+	Mode3D      = 3
+)
+
+// Command that asks gpsd to stream JSON reports.
+const watch string = `?WATCH={"enable":true,"json":true};` + "\n"
+
+// struct TPV is a time-position-velocity report. The error estimates are in
+// meters (95% confidence) and zero when the receiver does not report them.
+type TPV struct {
+	Class  string  `json:"class"`
+	Device string  `json:"device"`
+	Mode   int     `json:"mode"`
+	Time   string  `json:"time"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Alt    float64 `json:"alt"`
+	Epx    float64 `json:"epx"`
+	Epy    float64 `json:"epy"`
+	Eph    float64 `json:"eph"`
+}
+
+// func Accuracy returns the horizontal error estimate in meters, or 0 when it
+// is unknown.
+func (t TPV) Accuracy() float64 {
+	if t.Eph > 0 {
+		return t.Eph
+	}
+
+	return math.Max(t.Epx, t.Epy)
+}
+
+// func HasFix reports whether the report contains a 2D or 3D position.
+func (t TPV) HasFix() bool {
+	return t.Mode >= Mode2D
+}
+
+// message is used to read the class of each report.
+type message struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// Client for a gpsd daemon.
+type Client struct {
+	// host:port of the daemon. Defaults to localhost:2947.
+	Address string
+	// Time to wait for an acceptable fix.
+	Timeout time.Duration
+	// Largest accepted horizontal error in meters, or 0 to accept any fix.
+	// Fixes without an error estimate are accepted.
+	Accuracy float64
+	// Logger for the client.
+	Log *log.Logger
+}
+
+// gpsd Client constructor.
+func NewClient() *Client {
+	return &Client{
+		Address:  DefaultAddress,
+		Timeout:  DefaultTimeout,
+		Accuracy: DefaultAccuracy,
+		Log:      log.Default(),
+	}
+}
+
+// gpsd Client logger setter.
+func (c *Client) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// func Fix connects to gpsd, enables watching and waits for the first TPV
+// report with a position at least as accurate as the threshold.
+func (c *Client) Fix() (TPV, error) {
+	deadline := time.Now().Add(c.Timeout)
+
+	conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+
+	if err != nil {
+		return TPV{}, fmt.Errorf("failed to connect to gpsd at %s: %w", c.Address, err)
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return TPV{}, err
+	}
+
+	if _, err := conn.Write([]byte(watch)); err != nil {
+		return TPV{}, fmt.Errorf("failed to send WATCH to gpsd: %w", err)
+	}
+
+	var last *TPV
+
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		m := message{}
+
+		if err := json.Unmarshal(line, &m); err != nil {
+			c.Log.Debug(fmt.Sprintf("Ignoring malformed gpsd report: %s", err.Error()))
+
+			continue
+		}
+
+		switch m.Class {
+		case "ERROR":
+			return TPV{}, errors.New("gpsd error: " + m.Message)
+		case "TPV":
+			tpv := TPV{}
+
+			if err := json.Unmarshal(line, &tpv); err != nil {
+				return TPV{}, fmt.Errorf("failed to parse TPV report: %w", err)
+			}
+
+			last = &tpv
+
+			if !tpv.HasFix() {
+				c.Log.Debug(fmt.Sprintf("Waiting for a GPS fix (mode %d).", tpv.Mode))
+
+				continue
+			}
+
+			if acc := tpv.Accuracy(); c.Accuracy > 0 && acc > c.Accuracy {
+				c.Log.Debug(fmt.Sprintf("GPS fix is accurate to %.0f m, waiting for %.0f m.", acc, c.Accuracy))
+
+				continue
+			}
+
+			return tpv, nil
+		}
+	}
+
+	err = scanner.Err()
+
+	if err == nil {
+		err = errors.New("gpsd closed the connection")
+	}
+
+	var ne net.Error
+
+	if errors.As(err, &ne) && ne.Timeout() {
+		err = fmt.Errorf("no GPS fix within %s", c.Timeout)
+	}
+
+	if last != nil && last.HasFix() {
+		return TPV{}, fmt.Errorf("%w (last fix was accurate to %.0f m)", err, last.Accuracy())
+	}
+
+	return TPV{}, err
+}
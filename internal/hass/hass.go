@@ -0,0 +1,158 @@
+// Package hass builds Home Assistant MQTT discovery messages for the weather
+// published by geocast publish mqtt, so that its sensors show up in Home
+// Assistant without any YAML.
+//
+// Each sensor is announced with a retained config message on
+// <prefix>/<component>/<node>/<object>/config, and reads its state from the
+// JSON published on the conditions, forecast or alerts topic.
+package hass
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// Default discovery prefix of Home Assistant.
+const DefaultPrefix string = "homeassistant"
+
+// Payloads of the availability topic.
+const (
+	Online  string = "online"
+	Offline string = "offline"
+)
+
+// Characters that are not allowed in node and object IDs.
+var invalidID = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// func ID converts a name to a node or object ID, e.g. "Austin, TX" to
+// "austin_tx".
+func ID(name string) string {
+	return strings.Trim(invalidID.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// struct Topics are the topics that the weather is published on.
+type Topics struct {
+	Conditions   string
+	Forecast     string
+	Alerts       string
+	Availability string
+}
+
+// struct Device groups the sensors of a location in Home Assistant.
+type Device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// struct Config is the discovery payload of a sensor.
+type Config struct {
+	Name              string `json:"name"`
+	UniqueID          string `json:"unique_id"`
+	ObjectID          string `json:"object_id"`
+	StateTopic        string `json:"state_topic"`
+	ValueTemplate     string `json:"value_template"`
+	JSONAttributes    string `json:"json_attributes_topic,omitempty"`
+	AttributeTemplate string `json:"json_attributes_template,omitempty"`
+	Unit              string `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string `json:"device_class,omitempty"`
+	StateClass        string `json:"state_class,omitempty"`
+	Icon              string `json:"icon,omitempty"`
+	AvailabilityTopic string `json:"availability_topic"`
+	Device            Device `json:"device"`
+}
+
+// struct Message is a discovery message to publish (retained).
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// struct sensor describes a sensor before it is bound to a location.
+type sensor struct {
+	component   string
+	object      string
+	name        string
+	topic       string
+	template    string
+	unit        string
+	deviceClass string
+	stateClass  string
+	icon        string
+}
+
+// func sensors lists the sensors of a location, with units matching the
+// published weather.
+func sensors(t Topics, units string) []sensor {
+	return []sensor{
+		{"sensor", "temperature", "Temperature", t.Conditions, "{{ value_json.temperature }}", view.Unit("temperature", units), "temperature", "measurement", ""},
+		{"sensor", "feels_like", "Feels like", t.Conditions, "{{ value_json.feels_like }}", view.Unit("temperature", units), "temperature", "measurement", ""},
+		{"sensor", "dewpoint", "Dew point", t.Conditions, "{{ value_json.dewpoint }}", view.Unit("temperature", units), "temperature", "measurement", ""},
+		{"sensor", "humidity", "Humidity", t.Conditions, "{{ value_json.humidity }}", "%", "humidity", "measurement", ""},
+		{"sensor", "pressure", "Pressure", t.Conditions, "{{ value_json.pressure }}", view.Unit("pressure", units), "atmospheric_pressure", "measurement", ""},
+		{"sensor", "wind_speed", "Wind speed", t.Conditions, "{{ value_json.wind_speed }}", view.Unit("speed", units), "wind_speed", "measurement", ""},
+		{"sensor", "wind_gust", "Wind gust", t.Conditions, "{{ value_json.wind_gust }}", view.Unit("speed", units), "wind_speed", "measurement", ""},
+		{"sensor", "wind_direction", "Wind direction", t.Conditions, "{{ value_json.wind_direction }}", "°", "", "measurement", "mdi:compass-outline"},
+		{"sensor", "visibility", "Visibility", t.Conditions, "{{ value_json.visibility }}", view.Unit("distance", units), "distance", "measurement", ""},
+		{"sensor", "conditions", "Conditions", t.Conditions, "{{ value_json.description }}", "", "", "", "mdi:weather-partly-cloudy"},
+		{"sensor", "forecast", "Forecast", t.Forecast, "{{ value_json.periods[0].short_forecast if value_json.periods else '' }}", "", "", "", "mdi:weather-cloudy-clock"},
+		{"sensor", "precipitation_chance", "Chance of precipitation", t.Forecast, "{{ value_json.periods[0].precipitation_chance if value_json.periods else 0 }}", "%", "", "measurement", "mdi:weather-rainy"},
+		{"sensor", "alerts", "Alerts", t.Alerts, "{{ value_json.alerts | count }}", "", "", "measurement", "mdi:alert"},
+		{"binary_sensor", "alert", "Alert", t.Alerts, "{{ 'ON' if value_json.alerts else 'OFF' }}", "", "safety", "", ""},
+	}
+}
+
+// func Discovery returns the discovery messages for the sensors of a
+// location (e.g. "Austin, TX") whose weather is published on t in the given
+// units.
+func Discovery(prefix, location string, t Topics, units string) ([]Message, error) {
+	node := "geocast_" + ID(location)
+	device := Device{
+		Identifiers:  []string{node},
+		Name:         "Weather for " + location,
+		Manufacturer: "geocast",
+		Model:        "weather.gov",
+	}
+
+	messages := []Message{}
+
+	for _, s := range sensors(t, units) {
+		c := Config{
+			Name:              s.name,
+			UniqueID:          node + "_" + s.object,
+			ObjectID:          node + "_" + s.object,
+			StateTopic:        s.topic,
+			ValueTemplate:     s.template,
+			Unit:              s.unit,
+			DeviceClass:       s.deviceClass,
+			StateClass:        s.stateClass,
+			Icon:              s.icon,
+			AvailabilityTopic: t.Availability,
+			Device:            device,
+		}
+
+		// The alert sensors list the active alerts as attributes.
+		if s.topic == t.Alerts {
+			c.JSONAttributes = t.Alerts
+			c.AttributeTemplate = "{{ {'events': value_json.alerts | map(attribute='event') | list} | tojson }}"
+		}
+
+		payload, err := json.Marshal(c)
+
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, Message{
+			Topic:   fmt.Sprintf("%s/%s/%s/%s/config", prefix, s.component, node, s.object),
+			Payload: payload,
+		})
+	}
+
+	return messages, nil
+}
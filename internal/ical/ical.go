@@ -0,0 +1,333 @@
+// Package ical writes iCalendar (RFC 5545) files, so that forecasts and
+// alerts can be imported into or subscribed to from calendar apps.
+//
+// Event times are written in the calendar's time zone (with TZID) along with
+// a VTIMEZONE describing the zone's offsets over the span of the events, or in
+// UTC when the calendar has no time zone.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Content type of iCalendar files.
+const ContentType string = "text/calendar; charset=utf-8"
+
+// Identifies the program that wrote a calendar.
+const productID string = "-//desertthunder//geocast//EN"
+
+// Lines are folded at 75 octets, not counting the line break.
+const maxLineLength int = 75
+
+// Local date-time format, e.g. 20240614T180000.
+const localFormat string = "20060102T150405"
+
+// struct Event is a VEVENT.
+type Event struct {
+	// Globally unique and stable identifier, so that calendar apps update
+	// the event when a calendar is imported again instead of duplicating it.
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	// Coordinates of the event, written as GEO when HasGeo is set.
+	Lat, Lon   float64
+	HasGeo     bool
+	Start, End time.Time
+	Categories []string
+	URL        string
+	// Transparent events don't block time in the calendar.
+	Transparent bool
+}
+
+// struct Calendar is a VCALENDAR.
+type Calendar struct {
+	Name string
+	// Time zone of event times, UTC when nil.
+	Location *time.Location
+	Events   []Event
+	// Time of the DTSTAMP of events, now when zero.
+	Stamp time.Time
+}
+
+// func Escape escapes a TEXT value: backslashes, semicolons, commas and line
+// breaks.
+func Escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// func fold splits a content line into lines of at most 75 octets, each
+// continuation starting with a space. UTF-8 characters are never split.
+func fold(line string) string {
+	if len(line) <= maxLineLength {
+		return line + "\r\n"
+	}
+
+	b := strings.Builder{}
+	n := 0
+
+	for len(line) > 0 {
+		_, size := utf8.DecodeRuneInString(line)
+
+		if n+size > maxLineLength {
+			b.WriteString("\r\n ")
+			// The leading space counts towards the next line.
+			n = 1
+		}
+
+		b.WriteString(line[:size])
+		n += size
+		line = line[size:]
+	}
+
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+// struct writer writes folded content lines.
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) line(name, value string) {
+	w.buf.WriteString(fold(name + ":" + value))
+}
+
+// func time writes a DATE-TIME property in loc, or in UTC when loc is nil.
+func (w *writer) time(name string, t time.Time, loc *time.Location) {
+	if loc == nil {
+		w.line(name, t.UTC().Format(localFormat)+"Z")
+
+		return
+	}
+
+	w.line(name+";TZID="+loc.String(), t.In(loc).Format(localFormat))
+}
+
+// func Encode writes the calendar to w.
+func (c Calendar) Encode(w io.Writer) error {
+	out := writer{}
+	stamp := c.Stamp
+
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	loc := c.Location
+
+	if loc == time.UTC {
+		loc = nil
+	}
+
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", productID)
+	out.line("CALSCALE", "GREGORIAN")
+	out.line("METHOD", "PUBLISH")
+
+	if c.Name != "" {
+		out.line("X-WR-CALNAME", Escape(c.Name))
+	}
+
+	if loc != nil {
+		out.line("X-WR-TIMEZONE", loc.String())
+		c.writeTimeZone(&out, loc)
+	}
+
+	for _, e := range c.Events {
+		out.line("BEGIN", "VEVENT")
+		out.line("UID", Escape(e.UID))
+		out.time("DTSTAMP", stamp, nil)
+		out.time("DTSTART", e.Start, loc)
+
+		if !e.End.IsZero() && e.End.After(e.Start) {
+			out.time("DTEND", e.End, loc)
+		}
+
+		out.line("SUMMARY", Escape(e.Summary))
+
+		if e.Description != "" {
+			out.line("DESCRIPTION", Escape(e.Description))
+		}
+
+		if e.Location != "" {
+			out.line("LOCATION", Escape(e.Location))
+		}
+
+		if e.HasGeo {
+			out.line("GEO", fmt.Sprintf("%.6f;%.6f", e.Lat, e.Lon))
+		}
+
+		if len(e.Categories) > 0 {
+			categories := []string{}
+
+			for _, category := range e.Categories {
+				categories = append(categories, Escape(category))
+			}
+
+			out.line("CATEGORIES", strings.Join(categories, ","))
+		}
+
+		if e.URL != "" {
+			out.line("URL", e.URL)
+		}
+
+		if e.Transparent {
+			out.line("TRANSP", "TRANSPARENT")
+		}
+
+		out.line("END", "VEVENT")
+	}
+
+	out.line("END", "VCALENDAR")
+
+	_, err := w.Write(out.buf.Bytes())
+
+	return err
+}
+
+// struct transition is a change of UTC offset, e.g. the start of daylight
+// saving time.
+type transition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+// func transitions finds the offset changes of loc between from and to, to
+// the second.
+func transitions(loc *time.Location, from, to time.Time) []transition {
+	found := []transition{}
+
+	offset := func(t time.Time) int {
+		_, o := t.In(loc).Zone()
+
+		return o
+	}
+
+	for t := from; t.Before(to); t = t.Add(24 * time.Hour) {
+		next := t.Add(24 * time.Hour)
+
+		if offset(t) == offset(next) {
+			continue
+		}
+
+		// Binary search for the first second with the new offset.
+		lo, hi := t, next
+
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+
+			if offset(mid) == offset(lo) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		name, _ := hi.In(loc).Zone()
+		found = append(found, transition{hi, offset(lo), offset(hi), name, hi.In(loc).IsDST()})
+	}
+
+	return found
+}
+
+// func writeTimeZone writes a VTIMEZONE with the observances in effect from
+// the first to the last event. Each observance is a single onset (without
+// RRULE), which every calendar app understands.
+func (c Calendar) writeTimeZone(out *writer, loc *time.Location) {
+	start, end := time.Now(), time.Now()
+
+	for i, e := range c.Events {
+		if i == 0 || e.Start.Before(start) {
+			start = e.Start
+		}
+
+		if i == 0 || e.End.After(end) {
+			end = e.End
+		}
+
+		if e.Start.After(end) {
+			end = e.Start
+		}
+	}
+
+	// The observance in effect at the first event began at the last
+	// transition before it, at most a year earlier.
+	all := transitions(loc, start.AddDate(-1, 0, 0), end.Add(24*time.Hour))
+	observances := []transition{}
+
+	for _, t := range all {
+		if t.at.After(start) {
+			observances = append(observances, t)
+		} else {
+			observances = []transition{t}
+		}
+	}
+
+	if len(observances) == 0 || observances[0].at.After(start) {
+		name, offset := start.In(loc).Zone()
+		// Zones without transitions in the last year (e.g. America/Phoenix)
+		// have a single observance since the epoch.
+		epoch := transition{time.Unix(0, 0).UTC(), offset, offset, name, start.In(loc).IsDST()}
+		observances = append([]transition{epoch}, observances...)
+	}
+
+	out.line("BEGIN", "VTIMEZONE")
+	out.line("TZID", loc.String())
+
+	for _, t := range observances {
+		kind := "STANDARD"
+
+		if t.dst {
+			kind = "DAYLIGHT"
+		}
+
+		// The onset is written in the local time before the transition.
+		onset := t.at.In(time.FixedZone("", t.offsetFrom))
+
+		if t.at.Unix() == 0 {
+			onset = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+
+		out.line("BEGIN", kind)
+		out.line("DTSTART", onset.Format(localFormat))
+		out.line("TZOFFSETFROM", formatOffset(t.offsetFrom))
+		out.line("TZOFFSETTO", formatOffset(t.offsetTo))
+
+		if t.name != "" {
+			out.line("TZNAME", t.name)
+		}
+
+		out.line("END", kind)
+	}
+
+	out.line("END", "VTIMEZONE")
+}
+
+// func formatOffset formats a UTC offset in seconds as +hhmm.
+func formatOffset(seconds int) string {
+	sign := "+"
+
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
@@ -0,0 +1,172 @@
+// Submodule batch looks up many IP addresses at once with the IPInfo /batch
+// endpoint.
+package ipinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/desertthunder/weather/internal/utils"
+)
+
+// Maximum number of IP addresses per /batch request.
+const batchSize int = 1000
+
+// BatchGeolocator is implemented by backends that can geolocate several IP
+// addresses in a single request.
+type BatchGeolocator interface {
+	GeolocateBatch(ips []string) (map[string]IPInfoResponse, error)
+}
+
+// func GeolocateBatch geolocates the given IP addresses with the /batch
+// endpoint, splitting them into requests of up to 1000 addresses. The results
+// are keyed by the (normalized) IP address. Addresses missing from the
+// responses or without a location are left out, and their errors are joined
+// in the returned error.
+func (c *IPInfoClient) GeolocateBatch(ips []string) (map[string]IPInfoResponse, error) {
+	results := map[string]IPInfoResponse{}
+
+	if c.Token == "" {
+		return results, errors.New("IPInfo token is required")
+	}
+
+	addrs := []string{}
+
+	for _, ip := range ips {
+		addr, err := utils.ParseIPAddress(ip)
+
+		if err != nil {
+			return results, err
+		}
+
+		addrs = append(addrs, addr.String())
+	}
+
+	uri, err := url.ParseRequestURI(c.BaseURL)
+
+	if err != nil {
+		return results, err
+	}
+
+	uri.Path = "/batch"
+
+	errs := []error{}
+
+	for start := 0; start < len(addrs); start += batchSize {
+		end := min(start+batchSize, len(addrs))
+
+		if err := c.batch(uri.String(), addrs[start:end], results); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// batch posts a single chunk of IP addresses and adds the responses to
+// results. The addresses that can't be geolocated are skipped, and their
+// errors joined.
+func (c *IPInfoClient) batch(uri string, addrs []string, results map[string]IPInfoResponse) error {
+	body, err := json.Marshal(addrs)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := c.request(http.MethodPost, uri, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if err := parseError(rsp.StatusCode, data); err != nil {
+		return err
+	}
+
+	raw := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse batch response: %w", err)
+	}
+
+	errs := []error{}
+
+	for _, addr := range addrs {
+		item, ok := raw[addr]
+
+		if !ok {
+			errs = append(errs, fmt.Errorf("batch response is missing %s", addr))
+
+			continue
+		}
+
+		r := IPInfoResponse{}
+
+		if err := r.Validate(item); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", addr, err))
+
+			continue
+		}
+
+		results[addr] = r
+	}
+
+	return errors.Join(errs...)
+}
+
+// func Batch geolocates several IP addresses with any Geolocator, using its
+// batch endpoint when it has one. The results are keyed by the normalized IP
+// address. One address that can't be geolocated doesn't fail the others: it
+// is left out of the results and its error is joined in the returned error.
+func Batch(g Geolocator, ips []string) (map[string]IPInfoResponse, error) {
+	if b, ok := g.(BatchGeolocator); ok {
+		return b.GeolocateBatch(ips)
+	}
+
+	results := map[string]IPInfoResponse{}
+	errs := []error{}
+
+	for _, ip := range ips {
+		addr, err := utils.ParseIPAddress(ip)
+
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		s := addr.String()
+		r, err := g.Geolocate(&s)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s, err))
+
+			continue
+		}
+
+		results[s] = r
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+// Submodule cached remembers where the current device is, so that repeated
+// invocations do not geolocate it again.
+package ipinfo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/network"
+)
+
+// Default lifetime of a cached self-location.
+const DefaultCacheTTL time.Duration = 6 * time.Hour
+
+// CachedGeolocator wraps a Geolocator and caches the location of the current
+// device (lookups with a nil IP address). The cached value is used until its
+// TTL expires or the network fingerprint changes, e.g. after moving from the
+// office to home. Lookups of explicit IP addresses are never cached.
+type CachedGeolocator struct {
+	Geolocator
+	Store *cache.Store
+	// Cache key, which should identify the wrapped providers.
+	Key string
+	TTL time.Duration
+	// Skip the cached value (but still save the new one).
+	Refresh bool
+	// Returns the current network fingerprint. Defaults to
+	// network.Fingerprint.
+	Fingerprint func() (string, error)
+	// Logger for the cache.
+	Log *log.Logger
+}
+
+// Cached Geolocator constructor.
+func NewCachedGeolocator(g Geolocator, store *cache.Store, key string, ttl time.Duration) *CachedGeolocator {
+	return &CachedGeolocator{
+		Geolocator:  g,
+		Store:       store,
+		Key:         key,
+		TTL:         ttl,
+		Fingerprint: network.Fingerprint,
+		Log:         log.Default(),
+	}
+}
+
+// Cached Geolocator logger setter.
+func (c *CachedGeolocator) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// func Geolocate returns the cached location of the current device when it
+// is fresh, and otherwise asks the wrapped Geolocator and caches the result.
+func (c *CachedGeolocator) Geolocate(ipaddr *string) (IPInfoResponse, error) {
+	if ipaddr != nil {
+		return c.Geolocator.Geolocate(ipaddr)
+	}
+
+	fingerprint, err := c.Fingerprint()
+
+	if err != nil {
+		// Without a fingerprint a move cannot be detected, so the cache is
+		// bypassed.
+		c.Log.Debug(fmt.Sprintf("Could not fingerprint the network: %s", err.Error()))
+
+		return c.Geolocator.Geolocate(nil)
+	}
+
+	if !c.Refresh {
+		r := IPInfoResponse{}
+		meta, err := c.Store.Get(c.Key, &r)
+
+		switch {
+		case err == nil && meta.Fresh(c.TTL, fingerprint):
+			c.Log.Debug(fmt.Sprintf("Using location cached %s ago.", meta.Age().Round(time.Second)))
+
+			return r, nil
+		case err == nil && meta.Tag != fingerprint:
+			c.Log.Debug("Network changed since the location was cached.")
+		case err == nil:
+			c.Log.Debug("Cached location expired.")
+		case !errors.Is(err, cache.ErrMiss):
+			c.Log.Debug(fmt.Sprintf("Could not read cached location: %s", err.Error()))
+		}
+	}
+
+	r, err := c.Geolocator.Geolocate(nil)
+
+	if err != nil {
+		return r, err
+	}
+
+	if err := c.Store.Put(c.Key, fingerprint, r); err != nil {
+		c.Log.Debug(fmt.Sprintf("Could not cache location: %s", err.Error()))
+	}
+
+	return r, nil
+}
+
+// func GeolocateBatch geolocates several IP addresses with the wrapped
+// Geolocator, using its batch endpoint when it has one. Explicit IP addresses
+// are never cached.
+func (c *CachedGeolocator) GeolocateBatch(ips []string) (map[string]IPInfoResponse, error) {
+	return Batch(c.Geolocator, ips)
+}
@@ -0,0 +1,109 @@
+// Submodule chain tries a list of IP geolocation providers in order.
+package ipinfo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/utils"
+)
+
+// struct Provider is a named Geolocator.
+type Provider struct {
+	Name string
+	Geolocator
+}
+
+// Chain is a Geolocator that falls back through its providers in order and
+// returns the first successful result.
+type Chain struct {
+	Providers []Provider
+	// Logger for the chain.
+	Log *log.Logger
+}
+
+// Chain constructor.
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{Providers: providers, Log: log.Default()}
+}
+
+// Chain logger setter.
+func (c *Chain) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// func Geolocate asks each provider in turn. If every provider fails, the
+// returned error wraps all of their errors.
+func (c *Chain) Geolocate(ipaddr *string) (IPInfoResponse, error) {
+	errs := []error{}
+
+	if len(c.Providers) == 0 {
+		return IPInfoResponse{}, errors.New("no IP geolocation providers configured")
+	}
+
+	for _, p := range c.Providers {
+		r, err := p.Geolocate(ipaddr)
+
+		if err == nil {
+			c.Log.Debug(fmt.Sprintf("Located IP with the %s provider.", p.Name))
+
+			return r, nil
+		}
+
+		c.Log.Debug(fmt.Sprintf("IP provider %s failed: %s", p.Name, err.Error()))
+
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+
+	return IPInfoResponse{}, errors.Join(errs...)
+}
+
+// func GeolocateBatch geolocates several IP addresses, falling back through
+// the providers in order. Providers with a batch endpoint are asked for all of
+// the remaining addresses at once, the others one address at a time. Only the
+// addresses that a provider couldn't geolocate are sent to the next one; the
+// returned error joins the errors of every provider when some are left.
+func (c *Chain) GeolocateBatch(ips []string) (map[string]IPInfoResponse, error) {
+	errs := []error{}
+	results := map[string]IPInfoResponse{}
+
+	if len(c.Providers) == 0 {
+		return nil, errors.New("no IP geolocation providers configured")
+	}
+
+	remaining := ips
+
+	for _, p := range c.Providers {
+		r, err := Batch(p.Geolocator, remaining)
+
+		for addr, res := range r {
+			results[addr] = res
+		}
+
+		if err == nil {
+			return results, nil
+		}
+
+		c.Log.Debug(fmt.Sprintf("IP provider %s failed: %s", p.Name, err.Error()))
+
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		left := []string{}
+
+		for _, ip := range remaining {
+			key := ip
+
+			if addr, err := utils.ParseIPAddress(ip); err == nil {
+				key = addr.String()
+			}
+
+			if _, ok := r[key]; !ok {
+				left = append(left, ip)
+			}
+		}
+
+		remaining = left
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+// Submodule http contains the request helper shared by the token-free IP
+// geolocation providers.
+package ipinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/desertthunder/weather/internal/utils"
+)
+
+// getJSON performs a GET request and decodes the JSON body into v.
+func getJSON(uri string, v any) error {
+	rsp, err := http.Get(uri)
+
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if rsp.StatusCode != http.StatusOK && len(data) == 0 {
+		return fmt.Errorf("request failed with status %s", rsp.Status)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse response (status %s): %w", rsp.Status, err)
+	}
+
+	return nil
+}
+
+// lookupPath validates an optional IP address and returns the path segment
+// used to look it up ("" for the current device).
+func lookupPath(ipaddr *string) (string, error) {
+	if ipaddr == nil {
+		return "", nil
+	}
+
+	addr, err := utils.ParseIPAddress(*ipaddr)
+
+	if err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
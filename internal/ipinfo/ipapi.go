@@ -0,0 +1,99 @@
+// Submodule ipapi is a token-free provider for ip-api.com style JSON APIs.
+package ipinfo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// Overridable base URL for the ip-api.com client. The free tier is only
+	// served over plain HTTP.
+	ipapiBaseURL string = "http://ip-api.com"
+	ipapiFields  string = "status,message,query,city,regionName,countryCode,lat,lon,zip,timezone,org,as"
+)
+
+// Object representing the response from the ip-api.com JSON endpoint.
+type ipapiResponse struct {
+	Status      string  `json:"status"`
+	Message     string  `json:"message"`
+	Query       string  `json:"query"`
+	City        string  `json:"city"`
+	RegionName  string  `json:"regionName"`
+	CountryCode string  `json:"countryCode"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Zip         string  `json:"zip"`
+	Timezone    string  `json:"timezone"`
+	Org         string  `json:"org"`
+	AS          string  `json:"as"`
+}
+
+// Client for ip-api.com style APIs (/json/{ip}).
+type IPAPIClient struct {
+	// Base URL for the API. Defaults to http://ip-api.com.
+	BaseURL string
+	// Logger for the client.
+	Log *log.Logger
+}
+
+// IP-API Client constructor.
+func NewIPAPIClient() *IPAPIClient {
+	return &IPAPIClient{BaseURL: ipapiBaseURL, Log: log.Default()}
+}
+
+// IP-API Client URL setter.
+func (c *IPAPIClient) SetURL(url string) {
+	c.BaseURL = url
+}
+
+// IP-API Client logger setter.
+func (c *IPAPIClient) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// func Geolocate looks up an IP address (or the current device when nil).
+func (c *IPAPIClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
+	ipinfo := IPInfoResponse{}
+
+	path, err := lookupPath(ipaddr)
+
+	if err != nil {
+		return ipinfo, err
+	}
+
+	uri := fmt.Sprintf("%s/json/%s?fields=%s", strings.TrimSuffix(c.BaseURL, "/"), path, ipapiFields)
+	r := ipapiResponse{}
+
+	if err := getJSON(uri, &r); err != nil {
+		return ipinfo, err
+	}
+
+	if r.Status != "success" {
+		if r.Message == "" {
+			r.Message = "unknown error"
+		}
+
+		return ipinfo, errors.New("ip-api lookup failed: " + r.Message)
+	}
+
+	org := r.Org
+
+	if r.AS != "" {
+		org = r.AS
+	}
+
+	return IPInfoResponse{
+		IP:           r.Query,
+		City:         r.City,
+		Region:       r.RegionName,
+		Country:      r.CountryCode,
+		Location:     fmt.Sprintf("%.4f,%.4f", r.Lat, r.Lon),
+		Organization: org,
+		Postal:       r.Zip,
+		Timezone:     r.Timezone,
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
@@ -31,6 +32,78 @@ type IPInfoResponse struct {
 	Organization string `json:"org"`
 	Postal       string `json:"postal"`
 	Timezone     string `json:"timezone"`
+	// Set for private and reserved addresses, which have no location.
+	Bogon bool `json:"bogon,omitempty"`
+	// Optional fields, only returned for plans that include them.
+	ASN     *ASN     `json:"asn,omitempty"`
+	Company *Company `json:"company,omitempty"`
+	Privacy *Privacy `json:"privacy,omitempty"`
+	Abuse   *Abuse   `json:"abuse,omitempty"`
+}
+
+// Autonomous system details for an IP address.
+type ASN struct {
+	ASN    string `json:"asn"`
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	Route  string `json:"route"`
+	Type   string `json:"type"`
+}
+
+// Company that owns the IP address.
+type Company struct {
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+}
+
+// Privacy detection flags, i.e. whether the IP address belongs to a VPN,
+// proxy, Tor exit node, relay or hosting provider.
+type Privacy struct {
+	VPN     bool   `json:"vpn"`
+	Proxy   bool   `json:"proxy"`
+	Tor     bool   `json:"tor"`
+	Relay   bool   `json:"relay"`
+	Hosting bool   `json:"hosting"`
+	Service string `json:"service"`
+}
+
+// Abuse contact for the network of the IP address.
+type Abuse struct {
+	Address string `json:"address"`
+	Country string `json:"country"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Phone   string `json:"phone"`
+}
+
+// func Flags lists the privacy detections that are set, e.g. ["vpn", "tor"].
+func (p *Privacy) Flags() []string {
+	flags := []string{}
+
+	if p == nil {
+		return flags
+	}
+
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"vpn", p.VPN}, {"proxy", p.Proxy}, {"tor", p.Tor}, {"relay", p.Relay}, {"hosting", p.Hosting},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+
+	return flags
+}
+
+// Geolocator is implemented by the IP geolocation backends (the IPInfo API
+// and local mmdb files). A nil IP address geolocates the current device.
+type Geolocator interface {
+	Geolocate(ipaddr *string) (IPInfoResponse, error)
 }
 
 // Client for the https://ipinfo.io API.
@@ -44,15 +117,112 @@ type IPInfoClient struct {
 	Log *log.Logger
 }
 
+var (
+	// ErrInvalidToken is returned when the API rejects the token (HTTP 401/403).
+	ErrInvalidToken = errors.New("invalid IPInfo token")
+	// ErrQuotaExceeded is returned when the token's request quota has been
+	// used up (HTTP 429).
+	ErrQuotaExceeded = errors.New("IPInfo request quota exceeded")
+	// ErrBogon is returned for private and reserved addresses.
+	ErrBogon = errors.New("IP address is private or reserved (bogon) and has no location")
+)
+
+// struct APIError is the error envelope returned by the IPInfo API, e.g.
+// {"status": 401, "error": {"title": "...", "message": "..."}}.
+type APIError struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	msg := strings.TrimSuffix(strings.Join(nonEmpty(e.Title, e.Message), ": "), ".")
+
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+
+	return fmt.Sprintf("IPInfo API error (%d): %s", e.Status, msg)
+}
+
+// Unwrap maps the status code to ErrInvalidToken or ErrQuotaExceeded so
+// callers can use errors.Is.
+func (e *APIError) Unwrap() error {
+	switch e.Status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrInvalidToken
+	case http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	default:
+		return nil
+	}
+}
+
+// errorEnvelope is used to detect an error body. The error is usually an
+// object but older responses use a plain string.
+type errorEnvelope struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// func parseError returns the API error described by an error body, or nil if
+// data is not one. A non-200 status without an envelope still produces an
+// error.
+func parseError(status int, data []byte) error {
+	env := errorEnvelope{}
+
+	// Bodies that are not JSON objects are reported by status alone.
+	_ = json.Unmarshal(data, &env)
+
+	if len(env.Error) == 0 && (status == 0 || status == http.StatusOK) {
+		return nil
+	}
+
+	e := &APIError{Status: env.Status}
+
+	if status != 0 && status != http.StatusOK {
+		e.Status = status
+	}
+
+	detail := struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(env.Error, &detail); err == nil {
+		e.Title, e.Message = detail.Title, detail.Message
+	} else {
+		_ = json.Unmarshal(env.Error, &e.Message)
+	}
+
+	return e
+}
+
+func nonEmpty(values ...string) []string {
+	out := []string{}
+
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
 // BuildCity converts an IPInfoResponse to a City object.
-func (r IPInfoResponse) BuildCity() nws.City {
-	lat, lon := r.Point()
+func (r IPInfoResponse) BuildCity() (nws.City, error) {
+	lat, lon, err := r.Point()
+
+	if err != nil {
+		return nws.City{}, err
+	}
 
 	return nws.City{
 		Name: r.City,
 		Lat:  lat,
 		Long: lon,
-	}
+	}, nil
 }
 
 // IPInfo Client token setter.
@@ -75,22 +245,67 @@ func (i *IPInfoClient) SetLogger(logger *log.Logger) {
 //
 // It returns the latitude and longitude of the IP address by parsing/converting
 // the string representation of the location to two floats as a
-// tuple (lat, lon).
-func (i *IPInfoResponse) Point() (float64, float64) {
+// tuple (lat, lon). An error is returned when the location is missing or
+// malformed.
+func (i *IPInfoResponse) Point() (float64, float64, error) {
+	if i.Location == "" {
+		return 0, 0, fmt.Errorf("no location returned for %s", i.describe())
+	}
+
 	coords := strings.Split(i.Location, ",")
 
-	lat, _ := strconv.ParseFloat(coords[0], 64)
-	lon, _ := strconv.ParseFloat(coords[1], 64)
+	if len(coords) != 2 {
+		return 0, 0, fmt.Errorf("malformed location %q for %s", i.Location, i.describe())
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(coords[0]), 64)
+
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("malformed latitude in location %q for %s", i.Location, i.describe())
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(coords[1]), 64)
+
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("malformed longitude in location %q for %s", i.Location, i.describe())
+	}
 
-	return lat, lon
+	return lat, lon, nil
 }
 
-// func Geolocate calls the IPInfo API to geolocate a given IP address.
+func (i *IPInfoResponse) describe() string {
+	if i.IP == "" {
+		return "the IP address"
+	}
+
+	return i.IP
+}
+
+// func Geolocate calls the IPInfo API to geolocate a given IPv4 or IPv6
+// address. Addresses that cannot be geolocated (private, loopback, etc.) are
+// rejected before any request is made.
 //
 // If no IP address is provided, no param is passed to the API, which means the
 // client's IP address is used.
 func (c *IPInfoClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
 	ipinfo := IPInfoResponse{}
+	logger := c.Log
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	var addr netip.Addr
+
+	if ipaddr != nil {
+		parsed, err := utils.ParseIPAddress(*ipaddr)
+
+		if err != nil {
+			return ipinfo, err
+		}
+
+		addr = parsed
+	}
 
 	if c.Token == "" {
 		err := errors.New("IPInfo token is required")
@@ -101,36 +316,25 @@ func (c *IPInfoClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
 	uri, err := url.ParseRequestURI(c.BaseURL)
 
 	if err != nil {
-		fmt.Printf("Failed to parse URL: %s\n", err.Error())
+		logger.Error(fmt.Sprintf("Failed to parse URL: %s", err.Error()))
 
 		return ipinfo, err
 	}
 
-	valid := true
-
 	if ipaddr != nil {
-		valid = utils.ValidateIPAddress(*ipaddr)
-
-		uri.Path = fmt.Sprintf("/%s", *ipaddr)
+		uri.Path = fmt.Sprintf("/%s", addr)
 	}
 
-	if !valid {
-		err := errors.New("invalid IP address")
-
-		fmt.Printf("Invalid IP address: %s\n", *ipaddr)
+	req, err := c.request(http.MethodGet, uri.String(), nil)
 
+	if err != nil {
 		return ipinfo, err
 	}
 
-	query := uri.Query()
-	query.Add("token", c.Token)
-
-	withQuery := fmt.Sprintf("%s?%s", uri.String(), query.Encode())
-
-	rsp, err := http.Get(withQuery)
+	rsp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
-		fmt.Printf("Request to %s failed with error: %s\n", uri, err.Error())
+		logger.Error(fmt.Sprintf("Request to %s failed with error: %s", uri, err.Error()))
 
 		return ipinfo, err
 	}
@@ -140,27 +344,56 @@ func (c *IPInfoClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
 	data, err := io.ReadAll(rsp.Body)
 
 	if err != nil {
-		fmt.Printf("Failed to parse response body: %s\n", err.Error())
+		logger.Error(fmt.Sprintf("Failed to read response body: %s", err.Error()))
 
 		return ipinfo, err
 	}
 
+	if err := parseError(rsp.StatusCode, data); err != nil {
+		return ipinfo, err
+	}
+
 	err = ipinfo.Validate(data)
 
 	return ipinfo, err
 }
 
 // Validate the response from the IPInfo API for usable data.
+//
+// Error envelopes are returned as an *APIError, private/reserved addresses as
+// ErrBogon and responses without a usable "loc" as an error from Point.
 func (r *IPInfoResponse) Validate(data []byte) error {
-	s := utils.GetRawJSON(data)
+	if err := parseError(0, data); err != nil {
+		return err
+	}
 
-	if strings.Contains(s, "bogon") {
-		return errors.New("IP address is private (may be local)")
-	} else {
-		err := json.Unmarshal(data, &r)
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("failed to parse IPInfo response: %w", err)
+	}
 
-		return err
+	if r.Bogon {
+		return ErrBogon
+	}
+
+	_, _, err := r.Point()
+
+	return err
+}
+
+// func request builds an API request authenticated with the token in an
+// Authorization header, which (unlike a token query parameter) is not
+// included in URLs that end up in logs and error messages.
+func (c *IPInfoClient) request(method, uri string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, uri, body)
+
+	if err != nil {
+		return nil, err
 	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
 }
 
 // IPInfo Client constructor.
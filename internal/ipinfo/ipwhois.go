@@ -0,0 +1,99 @@
+// Submodule ipwhois is a token-free provider for ipwho.is style JSON APIs.
+package ipinfo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Overridable base URL for the ipwho.is client.
+const ipwhoisBaseURL string = "https://ipwho.is"
+
+// Object representing the response from the ipwho.is API.
+type ipwhoisResponse struct {
+	IP          string  `json:"ip"`
+	Success     bool    `json:"success"`
+	Message     string  `json:"message"`
+	City        string  `json:"city"`
+	Region      string  `json:"region"`
+	CountryCode string  `json:"country_code"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Postal      string  `json:"postal"`
+	Connection  struct {
+		ASN int    `json:"asn"`
+		Org string `json:"org"`
+	} `json:"connection"`
+	Timezone struct {
+		ID string `json:"id"`
+	} `json:"timezone"`
+}
+
+// Client for ipwho.is style APIs (/{ip}).
+type IPWhoisClient struct {
+	// Base URL for the API. Defaults to https://ipwho.is.
+	BaseURL string
+	// Logger for the client.
+	Log *log.Logger
+}
+
+// IPWhois Client constructor.
+func NewIPWhoisClient() *IPWhoisClient {
+	return &IPWhoisClient{BaseURL: ipwhoisBaseURL, Log: log.Default()}
+}
+
+// IPWhois Client URL setter.
+func (c *IPWhoisClient) SetURL(url string) {
+	c.BaseURL = url
+}
+
+// IPWhois Client logger setter.
+func (c *IPWhoisClient) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// func Geolocate looks up an IP address (or the current device when nil).
+func (c *IPWhoisClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
+	ipinfo := IPInfoResponse{}
+
+	path, err := lookupPath(ipaddr)
+
+	if err != nil {
+		return ipinfo, err
+	}
+
+	uri := fmt.Sprintf("%s/%s", strings.TrimSuffix(c.BaseURL, "/"), path)
+	r := ipwhoisResponse{}
+
+	if err := getJSON(uri, &r); err != nil {
+		return ipinfo, err
+	}
+
+	if !r.Success {
+		if r.Message == "" {
+			r.Message = "unknown error"
+		}
+
+		return ipinfo, errors.New("ipwho.is lookup failed: " + r.Message)
+	}
+
+	org := r.Connection.Org
+
+	if r.Connection.ASN != 0 {
+		org = fmt.Sprintf("AS%d %s", r.Connection.ASN, r.Connection.Org)
+	}
+
+	return IPInfoResponse{
+		IP:           r.IP,
+		City:         r.City,
+		Region:       r.Region,
+		Country:      r.CountryCode,
+		Location:     fmt.Sprintf("%.4f,%.4f", r.Latitude, r.Longitude),
+		Organization: org,
+		Postal:       r.Postal,
+		Timezone:     r.Timezone.ID,
+	}, nil
+}
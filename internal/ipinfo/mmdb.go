@@ -0,0 +1,146 @@
+// Submodule mmdb geolocates IP addresses offline using a local MaxMind DB
+// file, e.g. GeoLite2-City.mmdb or DB-IP's dbip-city-lite.mmdb.
+package ipinfo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/utils"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbRecord is the subset of the GeoIP2/GeoLite2 City schema (also used by
+// DB-IP) that maps onto an IPInfoResponse.
+type mmdbRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+}
+
+// Client that geolocates IP addresses with a local MaxMind DB (mmdb) file.
+type MMDBClient struct {
+	// Path to the mmdb file.
+	Path string
+	// Logger for the client.
+	Log    *log.Logger
+	reader *maxminddb.Reader
+}
+
+// MMDB Client constructor. The database is opened (memory mapped where
+// supported) until Close is called.
+func NewMMDBClient(path string) (*MMDBClient, error) {
+	if path == "" {
+		return nil, errors.New("an mmdb file path is required (set MMDB_PATH)")
+	}
+
+	reader, err := maxminddb.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mmdb file %s: %w", path, err)
+	}
+
+	return &MMDBClient{Path: path, reader: reader, Log: log.Default()}, nil
+}
+
+// MMDB Client logger setter.
+func (c *MMDBClient) SetLogger(logger *log.Logger) {
+	c.Log = logger
+}
+
+// Close releases the database file.
+func (c *MMDBClient) Close() error {
+	return c.reader.Close()
+}
+
+// func Geolocate looks up an IP address in the database and returns the
+// result in the same shape as the IPInfo API.
+//
+// Without an IP address the first public address assigned to a local network
+// interface is used, since the database cannot discover the public address of
+// a machine behind NAT.
+func (c *MMDBClient) Geolocate(ipaddr *string) (IPInfoResponse, error) {
+	ipinfo := IPInfoResponse{}
+
+	var addr netip.Addr
+	var err error
+
+	if ipaddr != nil {
+		addr, err = utils.ParseIPAddress(*ipaddr)
+	} else {
+		addr, err = publicInterfaceAddress()
+	}
+
+	if err != nil {
+		return ipinfo, err
+	}
+
+	record := mmdbRecord{}
+
+	_, ok, err := c.reader.LookupNetwork(net.IP(addr.AsSlice()), &record)
+
+	if err != nil {
+		return ipinfo, fmt.Errorf("mmdb lookup for %s failed: %w", addr, err)
+	}
+
+	if !ok || record.Location.Latitude == nil || record.Location.Longitude == nil {
+		return ipinfo, fmt.Errorf("no location found for %s in %s", addr, c.Path)
+	}
+
+	ipinfo = IPInfoResponse{
+		IP:       addr.String(),
+		City:     record.City.Names["en"],
+		Country:  record.Country.IsoCode,
+		Location: fmt.Sprintf("%.4f,%.4f", *record.Location.Latitude, *record.Location.Longitude),
+		Postal:   record.Postal.Code,
+		Timezone: record.Location.TimeZone,
+	}
+
+	if len(record.Subdivisions) > 0 {
+		ipinfo.Region = record.Subdivisions[0].Names["en"]
+	}
+
+	return ipinfo, nil
+}
+
+// publicInterfaceAddress returns the first public unicast address assigned
+// to a local network interface.
+func publicInterfaceAddress() (netip.Addr, error) {
+	addrs, err := net.InterfaceAddrs()
+
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+
+		if err != nil {
+			continue
+		}
+
+		if addr, err := utils.ParseIPAddress(prefix.Addr().String()); err == nil {
+			return addr, nil
+		}
+	}
+
+	return netip.Addr{}, errors.New("no public address found on local interfaces; pass one with --ip for mmdb lookups")
+}
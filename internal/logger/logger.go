@@ -33,10 +33,11 @@ func colors() []color {
 }
 
 // Init initializes the logger with a set of default styles and colors while
-// also streaming the logs to the console.
+// also streaming the logs to the console. Secrets are redacted from the output
+// (see AddSecret).
 func Init() *log.Logger {
 	styles := log.DefaultStyles()
-	logger := log.New(os.Stdout)
+	logger := log.New(Writer(os.Stdout))
 
 	for _, item := range colors() {
 		styles.Levels[item.level] = lipgloss.NewStyle().
@@ -0,0 +1,112 @@
+// Submodule redact scrubs tokens, keys and other secrets from log output.
+package logger
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder written in place of a secret.
+const Redacted string = "[REDACTED]"
+
+// Secrets shorter than this are not registered, since replacing them would
+// mangle unrelated output.
+const minSecretLength int = 6
+
+var (
+	mu      sync.RWMutex
+	secrets = []string{}
+	// Credentials that follow a well known query parameter, header or JSON
+	// key are redacted even when they were never registered.
+	patterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)((?:[?&]|\b)(?:token|access_token|api_?key|key|secret|password)=)[^&\s"']+`),
+		regexp.MustCompile(`(?i)(\bBearer\s+)[A-Za-z0-9._~+/=-]+`),
+		regexp.MustCompile(`(?i)("(?:token|access_token|api_?key|secret|password)"\s*:\s*")[^"]+`),
+	}
+)
+
+// func AddSecret registers values (e.g. configured API tokens) that must
+// never appear in output.
+func AddSecret(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+
+		if len(v) < minSecretLength {
+			continue
+		}
+
+		exists := false
+
+		for _, s := range secrets {
+			exists = exists || s == v
+		}
+
+		if !exists {
+			secrets = append(secrets, v)
+		}
+	}
+
+	// Longer secrets are replaced first so that one containing another is
+	// fully redacted.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// func Redact replaces registered secrets and credential-looking values in s.
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, "${1}"+Redacted)
+	}
+
+	return s
+}
+
+// redactingWriter redacts everything written through it.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// redactingFile keeps the file descriptor of the underlying file visible so
+// that terminal (and color) detection still works.
+type redactingFile struct {
+	redactingWriter
+	f *os.File
+}
+
+func (r *redactingFile) Read(p []byte) (int, error) {
+	return r.f.Read(p)
+}
+
+func (r *redactingFile) Fd() uintptr {
+	return r.f.Fd()
+}
+
+// func Writer wraps w so that secrets are redacted before they are written.
+func Writer(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok {
+		return &redactingFile{redactingWriter{f}, f}
+	}
+
+	return &redactingWriter{w}
+}
@@ -0,0 +1,70 @@
+// Submodule client records the requests made by geocast's API clients
+// (weather.gov, Nominatim, IP geolocation providers) and the use of its
+// caches.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// struct Client holds the client-side metrics.
+type Client struct {
+	requests *Counter
+	duration *Histogram
+	errors   *Counter
+	cache    *Counter
+
+	hits, lookups atomic.Int64
+}
+
+// Client constructor, registering its metrics.
+func NewClient(r *Registry) *Client {
+	c := &Client{
+		requests: r.Counter("geocast_http_requests_total", "Requests made to upstream APIs, by host and status code (\"error\" when no response was received).", "host", "code"),
+		duration: r.Histogram("geocast_http_request_duration_seconds", "Duration of requests made to upstream APIs, by host.", DefBuckets, "host"),
+		errors:   r.Counter("geocast_http_errors_total", "Requests to upstream APIs that failed or returned a server error (5xx), by host.", "host"),
+		cache:    r.Counter("geocast_cache_requests_total", "Cache lookups, by result: hit, miss or stale (expired values used because a request failed).", "result"),
+	}
+
+	r.GaugeFunc("geocast_cache_hit_ratio", "Ratio of cache lookups answered with a fresh value.", func() float64 {
+		if n := c.lookups.Load(); n > 0 {
+			return float64(c.hits.Load()) / float64(n)
+		}
+
+		return 0
+	})
+
+	return c
+}
+
+// func ObserveRequest records a request to an upstream API. err is the
+// transport error, if the request failed without a response.
+func (c *Client) ObserveRequest(req *http.Request, status int, err error, d time.Duration) {
+	host := req.URL.Host
+	code := strconv.Itoa(status)
+
+	if err != nil {
+		code = "error"
+	}
+
+	c.requests.Inc(host, code)
+	c.duration.Observe(d.Seconds(), host)
+
+	if err != nil || status >= http.StatusInternalServerError {
+		c.errors.Inc(host)
+	}
+}
+
+// func ObserveCache records a cache lookup, with the results reported by
+// cache.Fetch.
+func (c *Client) ObserveCache(result string) {
+	c.cache.Inc(result)
+	c.lookups.Add(1)
+
+	if result == "hit" {
+		c.hits.Add(1)
+	}
+}
@@ -0,0 +1,312 @@
+// Package metrics is a small registry of counters, gauges and histograms
+// written in the Prometheus text exposition format, for the /metrics
+// endpoint of geocast serve and geocast exporter.
+//
+// It only implements what geocast exposes, rather than depending on the
+// Prometheus client library:
+//
+//	# HELP geocast_temperature_celsius Observed air temperature.
+//	# TYPE geocast_temperature_celsius gauge
+//	geocast_temperature_celsius{place="home"} 21.7
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the text exposition format.
+const ContentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+// Histogram buckets in seconds, suited to HTTP request durations.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metric types.
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// struct series is a metric with label values.
+type series struct {
+	values []string
+	value  float64
+	// Histograms only: observations per bucket (not cumulative), their sum
+	// and count.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// struct family is a metric and its series.
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	// Gauges computed when written, without series.
+	fn     func() float64
+	series map[string]*series
+}
+
+// struct Registry holds the metrics written by Write.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// Registry constructor.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// func register adds a family. Registering a name twice is a programming
+// error, and panics.
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+
+	f.series = map[string]*series{}
+	r.families[f.name] = f
+
+	return f
+}
+
+// func get returns the series of f with the given label values, creating it
+// if needed. It must be called with r.mu held.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects labels %v, got %v", f.name, f.labels, values))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+
+		if f.typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// struct Counter is a value that only goes up, e.g. a number of requests.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// func Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.register(&family{name: name, help: help, typ: typeCounter, labels: labels})}
+}
+
+// func Add adds v (which must not be negative) to the series with the given
+// label values.
+func (c *Counter) Add(v float64, values ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+
+	c.f.get(values).value += v
+}
+
+// func Inc adds one to the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// struct Gauge is a value that goes up and down, e.g. a temperature.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// func Gauge registers a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.register(&family{name: name, help: help, typ: typeGauge, labels: labels})}
+}
+
+// func GaugeFunc registers a gauge without labels whose value is computed by
+// fn each time the metrics are written.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, typ: typeGauge, fn: fn})
+}
+
+// func Set sets the series with the given label values.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+
+	g.f.get(values).value = v
+}
+
+// func Delete removes the series with the given label values, e.g. when a
+// value is unknown or a place was removed.
+func (g *Gauge) Delete(values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+
+	delete(g.f.series, strings.Join(values, "\xff"))
+}
+
+// struct Histogram counts observations, e.g. durations, in buckets.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// func Histogram registers a histogram with the given upper bounds (sorted,
+// without +Inf) and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := slices.Clone(buckets)
+	sort.Float64s(b)
+
+	return &Histogram{r, r.register(&family{name: name, help: help, typ: typeHistogram, labels: labels, buckets: b})}
+}
+
+// func Observe adds an observation to the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+
+	s := h.f.get(values)
+
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+
+	s.sum += v
+	s.count++
+}
+
+// func Write writes the metrics in the text exposition format, sorted by
+// name and label values. Metrics without series are left out.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+
+	for name := range r.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	b := bufio.NewWriter(w)
+
+	for _, name := range names {
+		f := r.families[name]
+
+		if f.fn == nil && len(f.series) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.typ)
+
+		if f.fn != nil {
+			fmt.Fprintf(b, "%s %s\n", f.name, formatFloat(f.fn()))
+
+			continue
+		}
+
+		keys := make([]string, 0, len(f.series))
+
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+
+			if f.typ != typeHistogram {
+				fmt.Fprintf(b, "%s%s %s\n", f.name, labels(f.labels, s.values), formatFloat(s.value))
+
+				continue
+			}
+
+			var cumulative uint64
+
+			for i, le := range f.buckets {
+				cumulative += s.counts[i]
+
+				fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, labels(append(slices.Clone(f.labels), "le"), append(slices.Clone(s.values), formatFloat(le))), cumulative)
+			}
+
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, labels(append(slices.Clone(f.labels), "le"), append(slices.Clone(s.values), "+Inf")), s.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", f.name, labels(f.labels, s.values), formatFloat(s.sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", f.name, labels(f.labels, s.values), s.count)
+		}
+	}
+
+	return b.Flush()
+}
+
+// func ServeHTTP serves the metrics, for scrapers.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	r.Write(w)
+}
+
+// func labels formats label pairs, e.g. {host="api.weather.gov",code="200"}.
+func labels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
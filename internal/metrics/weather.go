@@ -0,0 +1,101 @@
+// Submodule weather exposes the observed and forecast weather of the saved
+// places as gauges, in base units.
+package metrics
+
+import (
+	"time"
+
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// struct Weather holds the weather gauges, labeled by place.
+type Weather struct {
+	up            *Gauge
+	observed      *Gauge
+	temperature   *Gauge
+	dewpoint      *Gauge
+	humidity      *Gauge
+	windSpeed     *Gauge
+	windGust      *Gauge
+	windDirection *Gauge
+	pressure      *Gauge
+	pop           *Gauge
+	forecast      *Gauge
+}
+
+// Weather constructor, registering its gauges.
+func NewWeather(r *Registry) *Weather {
+	return &Weather{
+		up:            r.Gauge("geocast_weather_up", "Whether the last update of a place's weather succeeded (1) or failed (0).", "place"),
+		observed:      r.Gauge("geocast_observation_timestamp_seconds", "Unix time of the latest observation.", "place"),
+		temperature:   r.Gauge("geocast_temperature_celsius", "Observed air temperature.", "place"),
+		dewpoint:      r.Gauge("geocast_dewpoint_celsius", "Observed dew point.", "place"),
+		humidity:      r.Gauge("geocast_relative_humidity_percent", "Observed relative humidity.", "place"),
+		windSpeed:     r.Gauge("geocast_wind_speed_meters_per_second", "Observed wind speed.", "place"),
+		windGust:      r.Gauge("geocast_wind_gust_meters_per_second", "Observed wind gust speed.", "place"),
+		windDirection: r.Gauge("geocast_wind_direction_degrees", "Observed wind direction, clockwise from north.", "place"),
+		pressure:      r.Gauge("geocast_pressure_pascals", "Observed barometric pressure.", "place"),
+		pop:           r.Gauge("geocast_forecast_precipitation_probability_percent", "Chance of precipitation in the next forecast period.", "place"),
+		forecast:      r.Gauge("geocast_forecast_temperature_celsius", "Forecast temperature of the next period.", "place"),
+	}
+}
+
+// func setOptional sets a gauge to v times scale, or removes it when the
+// station didn't report v.
+func setOptional(g *Gauge, v *float64, scale float64, place string) {
+	if v == nil {
+		g.Delete(place)
+
+		return
+	}
+
+	g.Set(*v*scale, place)
+}
+
+// func SetUp records whether a place's weather could be updated.
+func (w *Weather) SetUp(place string, up bool) {
+	v := 0.0
+
+	if up {
+		v = 1
+	}
+
+	w.up.Set(v, place)
+}
+
+// func SetConditions sets the observed weather of a place, from conditions
+// in SI units.
+func (w *Weather) SetConditions(place string, c view.Conditions) {
+	setOptional(w.temperature, c.Temperature, 1, place)
+	setOptional(w.dewpoint, c.Dewpoint, 1, place)
+	setOptional(w.humidity, c.Humidity, 1, place)
+	// km/h and hPa.
+	setOptional(w.windSpeed, c.WindSpeed, 1/3.6, place)
+	setOptional(w.windGust, c.WindGust, 1/3.6, place)
+	setOptional(w.windDirection, c.WindDirection, 1, place)
+	setOptional(w.pressure, c.Pressure, 100, place)
+
+	if t, err := time.Parse(time.RFC3339, c.Observed); err == nil {
+		w.observed.Set(float64(t.Unix()), place)
+	}
+}
+
+// func SetForecast sets the next forecast period of a place.
+func (w *Weather) SetForecast(place string, p view.Period) {
+	w.pop.Set(float64(p.PrecipitationChance), place)
+
+	t := float64(p.Temperature)
+
+	if p.TemperatureUnit == "F" {
+		t = (t - 32) * 5 / 9
+	}
+
+	w.forecast.Set(t, place)
+}
+
+// func Delete removes the gauges of a place, e.g. after it was removed.
+func (w *Weather) Delete(place string) {
+	for _, g := range []*Gauge{w.up, w.observed, w.temperature, w.dewpoint, w.humidity, w.windSpeed, w.windGust, w.windDirection, w.pressure, w.pop, w.forecast} {
+		g.Delete(place)
+	}
+}
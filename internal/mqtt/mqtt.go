@@ -0,0 +1,525 @@
+// Package mqtt is a minimal MQTT 3.1.1 client for geocast publish mqtt. It
+// only publishes (at QoS 0 or 1), which is all geocast needs, so it does not
+// depend on a full client library.
+//
+// Brokers are given as URLs: tcp://host:1883 (or mqtt://) for plain TCP, and
+// ssl://, tls:// or mqtts://host:8883 for TLS.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Control packet types, shifted into the high nibble of the fixed header.
+const (
+	typeConnect    byte = 1 << 4
+	typeConnack    byte = 2 << 4
+	typePublish    byte = 3 << 4
+	typePuback     byte = 4 << 4
+	typePingreq    byte = 12 << 4
+	typePingresp   byte = 13 << 4
+	typeDisconnect byte = 14 << 4
+)
+
+// Default keep alive interval.
+const DefaultKeepAlive time.Duration = time.Minute
+
+// Default timeout for connecting and for acknowledgements.
+const DefaultTimeout time.Duration = 10 * time.Second
+
+// ErrNotConnected is returned when publishing without a connection.
+var ErrNotConnected = errors.New("not connected to the MQTT broker")
+
+// Reasons for refused connections, by CONNACK return code.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// struct Options configures a client.
+type Options struct {
+	// Broker URL, e.g. tcp://localhost:1883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TLS configuration for ssl://, tls:// and mqtts:// brokers. The server
+	// name defaults to the broker's host.
+	TLS *tls.Config
+	// QoS of published messages, 0 or 1.
+	QoS       byte
+	KeepAlive time.Duration
+	Timeout   time.Duration
+	// Message the broker publishes when the client disconnects without a
+	// DISCONNECT, e.g. "offline" on an availability topic.
+	Will *Message
+}
+
+// struct Message is a published message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// struct session is a connection and its acknowledgements in flight.
+type session struct {
+	conn net.Conn
+	// Serializes writes.
+	wmu  sync.Mutex
+	amu  sync.Mutex
+	acks map[uint16]chan struct{}
+	// Closed when the connection is lost.
+	done chan struct{}
+	err  error
+}
+
+// struct Client publishes messages to a broker.
+type Client struct {
+	Options
+	Log *log.Logger
+
+	mu     sync.Mutex
+	s      *session
+	nextID uint16
+}
+
+// Client constructor.
+func New(opts Options, logger *log.Logger) *Client {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	return &Client{Options: opts, Log: logger}
+}
+
+// func dial opens a TCP or TLS connection to the broker.
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(c.Broker)
+
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid MQTT broker %q, expected e.g. tcp://localhost:1883", c.Broker)
+	}
+
+	secure := false
+	port := "1883"
+
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure, port = true, "8883"
+	default:
+		return nil, fmt.Errorf("unsupported MQTT broker scheme %q, expected tcp, mqtt, ssl, tls or mqtts", u.Scheme)
+	}
+
+	addr := u.Host
+
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	d := &net.Dialer{Timeout: c.Timeout}
+
+	if !secure {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	cfg := &tls.Config{}
+
+	if c.TLS != nil {
+		cfg = c.TLS.Clone()
+	}
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+
+	return (&tls.Dialer{NetDialer: d, Config: cfg}).DialContext(ctx, "tcp", addr)
+}
+
+// func Connect connects to the broker, replacing any previous connection.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.s != nil {
+		c.s.conn.Close()
+		c.s = nil
+	}
+
+	conn, err := c.dial(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", c.Broker, err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	if _, err := conn.Write(c.connectPacket()); err != nil {
+		conn.Close()
+
+		return fmt.Errorf("failed to connect to %s: %w", c.Broker, err)
+	}
+
+	r := bufio.NewReader(conn)
+	typ, body, err := readPacket(r)
+
+	if err != nil {
+		conn.Close()
+
+		return fmt.Errorf("failed to connect to %s: %w", c.Broker, err)
+	}
+
+	if typ != typeConnack || len(body) != 2 {
+		conn.Close()
+
+		return fmt.Errorf("failed to connect to %s: unexpected packet %d", c.Broker, typ>>4)
+	}
+
+	if code := body[1]; code != 0 {
+		conn.Close()
+
+		reason, ok := connackErrors[code]
+
+		if !ok {
+			reason = fmt.Sprintf("return code %d", code)
+		}
+
+		return fmt.Errorf("%s refused the connection: %s", c.Broker, reason)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	s := &session{conn: conn, acks: map[uint16]chan struct{}{}, done: make(chan struct{})}
+	c.s = s
+
+	go c.read(s, r)
+	go c.ping(s)
+
+	c.Log.Debug(fmt.Sprintf("Connected to MQTT broker %s as %s.", c.Broker, c.ClientID))
+
+	return nil
+}
+
+// func Connected reports whether the client has a live connection.
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.s == nil {
+		return false
+	}
+
+	select {
+	case <-c.s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// func session returns the live connection.
+func (c *Client) session() (*session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.s == nil {
+		return nil, ErrNotConnected
+	}
+
+	select {
+	case <-c.s.done:
+		return nil, fmt.Errorf("connection to %s lost: %w", c.Broker, c.s.err)
+	default:
+		return c.s, nil
+	}
+}
+
+// func read handles the broker's packets until the connection is lost.
+func (c *Client) read(s *session, r *bufio.Reader) {
+	var err error
+
+	for {
+		var typ byte
+		var body []byte
+
+		if typ, body, err = readPacket(r); err != nil {
+			break
+		}
+
+		switch typ {
+		case typePuback:
+			if len(body) < 2 {
+				continue
+			}
+
+			id := binary.BigEndian.Uint16(body)
+
+			s.amu.Lock()
+
+			if ack, ok := s.acks[id]; ok {
+				close(ack)
+				delete(s.acks, id)
+			}
+
+			s.amu.Unlock()
+		case typePingresp:
+		default:
+			c.Log.Debug(fmt.Sprintf("Ignoring MQTT packet type %d.", typ>>4))
+		}
+	}
+
+	s.err = err
+	close(s.done)
+	s.conn.Close()
+}
+
+// func ping keeps the connection alive, as the broker drops clients that are
+// silent for longer than the keep alive interval.
+func (c *Client) ping(s *session) {
+	t := time.NewTicker(c.KeepAlive / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			if err := s.write([]byte{typePingreq, 0}, c.Timeout); err != nil {
+				s.conn.Close()
+
+				return
+			}
+		}
+	}
+}
+
+func (s *session) write(p []byte, timeout time.Duration) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	_, err := s.conn.Write(p)
+
+	return err
+}
+
+// func Publish sends a message, and waits for the broker to acknowledge it
+// at QoS 1.
+func (c *Client) Publish(m Message) error {
+	s, err := c.session()
+
+	if err != nil {
+		return err
+	}
+
+	var id uint16
+	var ack chan struct{}
+
+	if c.QoS > 0 {
+		c.mu.Lock()
+		c.nextID++
+
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+
+		id = c.nextID
+		c.mu.Unlock()
+
+		ack = make(chan struct{})
+
+		s.amu.Lock()
+		s.acks[id] = ack
+		s.amu.Unlock()
+
+		defer func() {
+			s.amu.Lock()
+			delete(s.acks, id)
+			s.amu.Unlock()
+		}()
+	}
+
+	if err := s.write(publishPacket(m, c.QoS, id), c.Timeout); err != nil {
+		s.conn.Close()
+
+		return fmt.Errorf("failed to publish to %s: %w", m.Topic, err)
+	}
+
+	if ack == nil {
+		return nil
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-s.done:
+		return fmt.Errorf("connection lost while publishing to %s: %w", m.Topic, s.err)
+	case <-time.After(c.Timeout):
+		return fmt.Errorf("no acknowledgement from the broker for %s", m.Topic)
+	}
+}
+
+// func Close disconnects cleanly, so that the broker doesn't publish the
+// will message.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	s := c.s
+	c.s = nil
+	c.mu.Unlock()
+
+	if s == nil {
+		return nil
+	}
+
+	err := s.write([]byte{typeDisconnect, 0}, c.Timeout)
+
+	s.conn.Close()
+
+	return err
+}
+
+// func connectPacket encodes the CONNECT packet with a clean session.
+func (c *Client) connectPacket() []byte {
+	flags := byte(0x02)
+	payload := appendString(nil, c.ClientID)
+
+	if c.Will != nil {
+		flags |= 0x04 | c.QoS<<3
+
+		if c.Will.Retain {
+			flags |= 0x20
+		}
+
+		payload = appendString(payload, c.Will.Topic)
+		payload = appendBytes(payload, c.Will.Payload)
+	}
+
+	if c.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, c.Username)
+
+		if c.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, c.Password)
+		}
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.KeepAlive/time.Second))
+	body = append(body, payload...)
+
+	return packet(typeConnect, body)
+}
+
+func publishPacket(m Message, qos byte, id uint16) []byte {
+	header := typePublish | qos<<1
+
+	if m.Retain {
+		header |= 0x01
+	}
+
+	body := appendString(nil, m.Topic)
+
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+
+	return packet(header, append(body, m.Payload...))
+}
+
+// func packet prefixes a body with its fixed header.
+func packet(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+
+	// The remaining length is encoded 7 bits at a time, least significant
+	// first, with the high bit set on all but the last byte.
+	for {
+		b := byte(n % 128)
+		n /= 128
+
+		if n > 0 {
+			b |= 0x80
+		}
+
+		p = append(p, b)
+
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(p, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+
+	return append(b, s...)
+}
+
+// func readPacket reads a packet, returning its type (the high nibble of the
+// fixed header, with the flags cleared) and its body.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, mult := 0, 1
+
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		n += int(b&0x7f) * mult
+
+		if b&0x80 == 0 {
+			break
+		}
+
+		if i == 3 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+
+		mult *= 128
+	}
+
+	body := make([]byte, n)
+
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header & 0xf0, body, nil
+}
@@ -0,0 +1,143 @@
+// Package network fingerprints the machine's network attachment (its default
+// routes and interface addresses) so that cached location data can be
+// invalidated when the machine moves to another network.
+package network
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Linux routing table. Other platforms fall back to the interface addresses.
+const routeTable string = "/proc/net/route"
+
+// struct Route is a default route from the routing table.
+type Route struct {
+	Interface string
+	Gateway   netip.Addr
+}
+
+func (r Route) String() string {
+	return fmt.Sprintf("route %s via %s", r.Interface, r.Gateway)
+}
+
+// func ParseRoutes reads the default routes from a /proc/net/route style
+// table, whose destination, gateway and mask are little endian hex IPv4
+// addresses.
+func ParseRoutes(r io.Reader) ([]Route, error) {
+	routes := []Route{}
+	scanner := bufio.NewScanner(r)
+
+	// Skip the header.
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 8 {
+			continue
+		}
+
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid gateway %q: %w", fields[2], err)
+		}
+
+		addr := [4]byte{}
+		binary.LittleEndian.PutUint32(addr[:], uint32(gw))
+
+		routes = append(routes, Route{Interface: fields[0], Gateway: netip.AddrFrom4(addr)})
+	}
+
+	return routes, scanner.Err()
+}
+
+// func Addresses lists the addresses of the interfaces that are up, skipping
+// loopback and link-local addresses, as "interface address" strings.
+func Addresses() ([]string, error) {
+	ifaces, err := net.Interfaces()
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := []string{}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+
+		if err != nil {
+			continue
+		}
+
+		for _, a := range addrs {
+			prefix, err := netip.ParsePrefix(a.String())
+
+			if err != nil {
+				continue
+			}
+
+			addr := prefix.Addr()
+
+			if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+				continue
+			}
+
+			out = append(out, fmt.Sprintf("%s %s", iface.Name, addr))
+		}
+	}
+
+	return out, nil
+}
+
+// func Fingerprint returns a short hash of the default routes and interface
+// addresses. It changes when the machine joins a different network.
+func Fingerprint() (string, error) {
+	parts, err := Addresses()
+
+	if err != nil {
+		return "", err
+	}
+
+	if f, err := os.Open(routeTable); err == nil {
+		routes, err := ParseRoutes(f)
+		f.Close()
+
+		if err == nil {
+			for _, r := range routes {
+				parts = append(parts, r.String())
+			}
+		}
+	}
+
+	return Hash(parts), nil
+}
+
+// func Hash returns an order independent hash of parts.
+func Hash(parts []string) string {
+	sorted := append([]string{}, parts...)
+	sort.Strings(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+
+	return hex.EncodeToString(sum[:8])
+}
@@ -16,7 +16,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
+	"github.com/charmbracelet/log"
 	"github.com/desertthunder/weather/internal/nws"
 )
 
@@ -44,11 +46,16 @@ const BaseURL string = "https://nominatim.openstreetmap.org"
 // User-Agent for testing purposes.
 const UserAgent string = "geocast-desertthunder@github.com"
 
+// ErrNoResults is returned when Nominatim answered but found nothing, as
+// opposed to request errors when it is unreachable or unavailable.
+var ErrNoResults = errors.New("no results found")
+
 // struct Nominatim represents the Nominatim API client.
 type Nominatim struct {
 	baseURL   string
 	params    Params
 	userAgent string
+	logger    *log.Logger
 }
 
 type nominatimSearchResult struct {
@@ -72,10 +79,14 @@ type NominatimSearchResponse = []nominatimSearchResult
 
 type Params struct {
 	// free form search query string
-	Q           string
-	Format      Formats
-	Limit       int
-	NameDetails bool
+	Q string
+	// structured search by postal code, used when Q is empty
+	PostalCode string
+	// comma separated ISO 3166-1 alpha2 codes to restrict results to
+	CountryCodes string
+	Format       Formats
+	Limit        int
+	NameDetails  bool
 }
 
 func (n *Nominatim) SetURL(url string) {
@@ -94,6 +105,10 @@ func (n *Nominatim) SetUserAgent(ua string) {
 	n.userAgent = ua
 }
 
+func (n *Nominatim) SetLogger(logger *log.Logger) {
+	n.logger = logger
+}
+
 func (n *Nominatim) GetParams() Params {
 	return n.params
 }
@@ -105,11 +120,18 @@ func (n *Nominatim) UserAgent() string {
 func (p Params) String() string {
 	qs := ""
 
-	if p.Q == "" {
+	switch {
+	case p.Q != "":
+		qs = fmt.Sprintf("q=%s", url.QueryEscape(p.Q))
+	case p.PostalCode != "":
+		qs = fmt.Sprintf("postalcode=%s", url.QueryEscape(p.PostalCode))
+	default:
 		return qs
 	}
 
-	qs = fmt.Sprintf("q=%s", p.Q)
+	if p.CountryCodes != "" {
+		qs = fmt.Sprintf("%s&countrycodes=%s", qs, url.QueryEscape(p.CountryCodes))
+	}
 
 	if p.Format == "" {
 		p.Format = JsonV2
@@ -146,15 +168,21 @@ func (n *Nominatim) getRequest(endpoint Endpoints) ([]byte, error) {
 	rsp, err := client.Do(req)
 
 	if err != nil {
-		fmt.Printf("Request to %s failed with error: %s\n", uri, err.Error())
+		n.logger.Error(fmt.Sprintf("Request to %s failed with error: %s", uri, err.Error()))
 
 		return nil, err
 	}
 
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to %s failed with status %s", uri, rsp.Status)
+	}
+
 	data, err := io.ReadAll(rsp.Body)
 
 	if err != nil {
-		fmt.Printf("Failed to read response body: %s\n", err.Error())
+		n.logger.Error(fmt.Sprintf("Failed to read response body: %s", err.Error()))
 
 		return nil, err
 	}
@@ -162,23 +190,30 @@ func (n *Nominatim) getRequest(endpoint Endpoints) ([]byte, error) {
 	return data, nil
 }
 
-func handleSimpleError(err error) {
-	fmt.Printf("Error: %s\n", err)
+func (n *Nominatim) Search() NominatimSearchResponse {
+	rsp, err := n.search()
+
+	if err != nil {
+		n.logger.Error(err.Error())
+	}
+
+	return rsp
 }
 
-func (n *Nominatim) Search() NominatimSearchResponse {
+// search is Search but surfaces request and decoding errors so that callers
+// can tell "no results" apart from "Nominatim is unavailable".
+func (n *Nominatim) search() (NominatimSearchResponse, error) {
+	rsp := NominatimSearchResponse{}
+
 	d, err := n.getRequest(Search)
 
 	if err != nil {
-		handleSimpleError(err)
-
-		return NominatimSearchResponse{}
+		return rsp, err
 	}
 
-	rsp := NominatimSearchResponse{}
-	json.Unmarshal(d, &rsp)
+	err = json.Unmarshal(d, &rsp)
 
-	return rsp
+	return rsp, err
 }
 
 func (n *Nominatim) GeocodeByPoint(lat, lon float64) (*nws.City, error) {
@@ -186,10 +221,14 @@ func (n *Nominatim) GeocodeByPoint(lat, lon float64) (*nws.City, error) {
 		Q: fmt.Sprintf("%f,%f", lat, lon),
 	})
 
-	results := n.Search()
+	results, err := n.search()
+
+	if err != nil {
+		return nil, err
+	}
 
 	if len(results) == 0 {
-		return nil, errors.New("no results found for the provided point")
+		return nil, fmt.Errorf("%w for the provided point", ErrNoResults)
 	}
 
 	result := results[0]
@@ -204,10 +243,39 @@ func (n *Nominatim) GeocodeByCity(c string) (*nws.City, error) {
 		Q: c,
 	})
 
-	results := n.Search()
+	results, err := n.search()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w for the provided city name", ErrNoResults)
+	}
+
+	result := results[0]
+
+	city := nws.BuildCity(result.DisplayName, result.Lat, result.Lon)
+
+	return &city, nil
+}
+
+// GeocodeByPostalCode uses Nominatim's structured search to resolve a postal
+// code within a country (e.g. "78701", "us").
+func (n *Nominatim) GeocodeByPostalCode(code, country string) (*nws.City, error) {
+	n.SetParams(Params{
+		PostalCode:   code,
+		CountryCodes: country,
+	})
+
+	results, err := n.search()
+
+	if err != nil {
+		return nil, err
+	}
 
 	if len(results) == 0 {
-		return nil, errors.New("no results found for the provided city name")
+		return nil, fmt.Errorf("%w for the provided postal code", ErrNoResults)
 	}
 
 	result := results[0]
@@ -222,6 +290,7 @@ func Init() *Nominatim {
 		baseURL:   BaseURL,
 		params:    Params{},
 		userAgent: UserAgent,
+		logger:    log.Default(),
 	}
 }
 
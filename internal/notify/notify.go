@@ -0,0 +1,288 @@
+// Package notify detects the weather events that geocast notify sends to
+// webhooks: new, updated and cancelled alerts, and forecast periods that trip
+// a threshold such as "temperature>=100".
+//
+// Detection is stateless apart from the alerts seen in the previous poll;
+// events are deduplicated by their key (e.g. the alert ID) with a State that
+// remembers what was sent to each webhook.
+package notify
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// Event kinds.
+const (
+	// A new alert was issued.
+	KindAlert string = "alert"
+	// An alert was updated, e.g. extended or upgraded from a watch to a
+	// warning.
+	KindUpdate string = "update"
+	// An alert was cancelled, or ended before it expired.
+	KindCancel string = "cancel"
+	// A forecast period trips a threshold.
+	KindThreshold string = "threshold"
+)
+
+// Alert message types of weather.gov.
+const (
+	messageUpdate string = "Update"
+	messageCancel string = "Cancel"
+)
+
+// Alert severities, from lowest to highest.
+var severities = []string{"Unknown", "Minor", "Moderate", "Severe", "Extreme"}
+
+// Alert levels by the last word of the event, e.g. "Tornado Watch", from
+// lowest to highest.
+var levels = []string{"Statement", "Advisory", "Watch", "Warning", "Emergency"}
+
+// struct Event is a notification, posted as is to JSON webhooks.
+type Event struct {
+	// Deduplication key, e.g. the ID of the alert.
+	Key      string        `json:"key"`
+	Kind     string        `json:"kind"`
+	Place    string        `json:"place"`
+	Location view.Location `json:"location"`
+	Title    string        `json:"title"`
+	Message  string        `json:"message"`
+	// Severity of the alert: Extreme, Severe, Moderate, Minor or Unknown.
+	Severity string      `json:"severity,omitempty"`
+	Alert    *view.Alert `json:"alert,omitempty"`
+	// IDs of the alerts that an update or cancellation replaces.
+	References []string `json:"references,omitempty"`
+	// Whether an update raised the severity or level of the alert.
+	Upgraded  bool         `json:"upgraded,omitempty"`
+	Threshold string       `json:"threshold,omitempty"`
+	Period    *view.Period `json:"period,omitempty"`
+	// RFC 3339 time the event was detected.
+	Time string `json:"time"`
+}
+
+func rank(values []string, v string) int {
+	return slices.Index(values, v)
+}
+
+func level(event string) int {
+	words := strings.Fields(event)
+
+	if len(words) == 0 {
+		return -1
+	}
+
+	return rank(levels, words[len(words)-1])
+}
+
+// func upgraded reports whether an update raised the severity of an alert or
+// its level, e.g. a Tornado Watch replaced by a Tornado Warning.
+func upgraded(previous, current view.Alert) bool {
+	return rank(severities, current.Severity) > rank(severities, previous.Severity) || level(current.Event) > level(previous.Event)
+}
+
+// func alertMessage returns the headline of an alert, or the start of its
+// description when it has none.
+func alertMessage(a view.Alert) string {
+	if a.Headline != "" {
+		return a.Headline
+	}
+
+	first, _, _ := strings.Cut(strings.TrimSpace(a.Description), "\n\n")
+
+	return strings.Join(strings.Fields(first), " ")
+}
+
+// func Alerts compares the active alerts of a place with the alerts seen in
+// the previous poll, keyed by ID. It returns an event for each active alert
+// (deduplicated later by ID) and for each alert that disappeared before it
+// expired, along with the alerts to compare the next poll with.
+func Alerts(place string, city nws.City, alerts []nws.AlertAPIResponse, seen map[string]view.Alert, now time.Time) ([]Event, map[string]view.Alert) {
+	loc := view.NewLocation(city)
+	converted := view.NewAlerts(city, alerts).Alerts
+	active := map[string]view.Alert{}
+	replaced := map[string]bool{}
+	events := []Event{}
+
+	for i, a := range alerts {
+		alert := converted[i]
+		e := Event{
+			Key:      a.ID,
+			Kind:     KindAlert,
+			Place:    place,
+			Location: loc,
+			Title:    fmt.Sprintf("%s for %s", a.Event, city.Name),
+			Message:  alertMessage(alert),
+			Severity: a.Severity,
+			Alert:    &alert,
+			Time:     now.Format(time.RFC3339),
+		}
+
+		for _, r := range a.References {
+			e.References = append(e.References, r.Identifier)
+			replaced[r.Identifier] = true
+		}
+
+		switch {
+		case a.MessageType == messageCancel:
+			e.Kind = KindCancel
+			e.Title = fmt.Sprintf("Cancelled: %s for %s", a.Event, city.Name)
+		case a.MessageType == messageUpdate || len(e.References) > 0:
+			e.Kind = KindUpdate
+			e.Title = fmt.Sprintf("Updated: %s for %s", a.Event, city.Name)
+
+			for _, id := range e.References {
+				if previous, ok := seen[id]; ok && upgraded(previous, alert) {
+					e.Upgraded = true
+					e.Title = fmt.Sprintf("Upgraded: %s for %s", a.Event, city.Name)
+				}
+			}
+		}
+
+		if e.Kind != KindCancel {
+			active[a.ID] = alert
+		}
+
+		events = append(events, e)
+	}
+
+	// Alerts that were replaced were reported with their update, and those
+	// that expired need no notification.
+	for id, previous := range seen {
+		if _, ok := active[id]; ok || replaced[id] {
+			continue
+		}
+
+		if expires, err := time.Parse(time.RFC3339, previous.Expires); err != nil || !expires.After(now) {
+			continue
+		}
+
+		events = append(events, Event{
+			Key:        "ended:" + id,
+			Kind:       KindCancel,
+			Place:      place,
+			Location:   loc,
+			Title:      fmt.Sprintf("Ended: %s for %s", previous.Event, city.Name),
+			Message:    fmt.Sprintf("The %s is no longer in effect.", previous.Event),
+			Severity:   previous.Severity,
+			References: []string{id},
+			Time:       now.Format(time.RFC3339),
+		})
+	}
+
+	return events, active
+}
+
+// Forecast fields that thresholds can be set on.
+var Fields = []string{"temperature", "precipitation", "wind"}
+
+var thresholdPattern = regexp.MustCompile(`^([a-z]+)\s*(>=|<=|>|<)\s*(-?\d+(?:\.\d+)?)$`)
+
+var number = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// struct Threshold is a condition on the forecast periods, in the forecast's
+// units, e.g. "temperature>=100" or "precipitation>60".
+type Threshold struct {
+	Field string
+	Op    string
+	Value float64
+}
+
+// func ParseThreshold parses a threshold such as "wind > 30".
+func ParseThreshold(s string) (Threshold, error) {
+	m := thresholdPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+
+	if m == nil || !slices.Contains(Fields, m[1]) {
+		return Threshold{}, fmt.Errorf("invalid threshold %q, expected a field (%s), an operator (>, >=, < or <=) and a number, e.g. temperature>=100", s, strings.Join(Fields, ", "))
+	}
+
+	v, _ := strconv.ParseFloat(m[3], 64)
+
+	return Threshold{Field: m[1], Op: m[2], Value: v}, nil
+}
+
+func (t Threshold) String() string {
+	return t.Field + t.Op + strconv.FormatFloat(t.Value, 'f', -1, 64)
+}
+
+// func value returns the field of a period. The wind speed is the highest
+// speed given, e.g. 15 for "10 to 15 mph".
+func (t Threshold) value(p view.Period) (float64, bool) {
+	switch t.Field {
+	case "temperature":
+		return float64(p.Temperature), true
+	case "precipitation":
+		return float64(p.PrecipitationChance), true
+	}
+
+	speeds := number.FindAllString(p.WindSpeed, -1)
+
+	if len(speeds) == 0 {
+		return 0, false
+	}
+
+	v, err := strconv.ParseFloat(speeds[len(speeds)-1], 64)
+
+	return v, err == nil
+}
+
+// func Trips reports whether a period trips the threshold.
+func (t Threshold) Trips(p view.Period) bool {
+	v, ok := t.value(p)
+
+	if !ok {
+		return false
+	}
+
+	switch t.Op {
+	case ">":
+		return v > t.Value
+	case ">=":
+		return v >= t.Value
+	case "<":
+		return v < t.Value
+	default:
+		return v <= t.Value
+	}
+}
+
+// func Thresholds returns an event for each period starting before until
+// that trips a threshold, keyed by the place, threshold and period so that a
+// period trips each threshold once.
+func Thresholds(place string, f view.Forecast, thresholds []Threshold, now, until time.Time) []Event {
+	events := []Event{}
+
+	for _, p := range f.Periods {
+		if start, err := time.Parse(time.RFC3339, p.Start); err == nil && !start.Before(until) {
+			continue
+		}
+
+		for _, t := range thresholds {
+			if !t.Trips(p) {
+				continue
+			}
+
+			period := p
+
+			events = append(events, Event{
+				Key:       fmt.Sprintf("threshold:%s:%s:%s", place, t, p.Start),
+				Kind:      KindThreshold,
+				Place:     place,
+				Location:  f.Location,
+				Title:     fmt.Sprintf("%s: %s for %s", p.Name, t, f.Location.Name),
+				Message:   fmt.Sprintf("%s, %d°%s, %d%% chance of precipitation, wind %s %s.", p.ShortForecast, p.Temperature, p.TemperatureUnit, p.PrecipitationChance, p.WindDirection, p.WindSpeed),
+				Threshold: t.String(),
+				Period:    &period,
+				Time:      now.Format(time.RFC3339),
+			})
+		}
+	}
+
+	return events
+}
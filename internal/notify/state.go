@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// Name of the state file in the state directory.
+const File string = "notify.json"
+
+// How long sent events are remembered. Alerts and forecast periods are long
+// over by then.
+const Retention time.Duration = 30 * 24 * time.Hour
+
+// struct State remembers the events sent to each webhook, the events that
+// weren't delivered yet and the alerts seen in the last poll, so that nothing
+// is sent twice or lost across restarts.
+type State struct {
+	Path string `json:"-"`
+	// Time each event was sent, by webhook ID and event key.
+	Sent map[string]map[string]time.Time `json:"sent"`
+	// Active alerts of each place in the last poll, by alert ID.
+	Alerts map[string]map[string]view.Alert `json:"alerts"`
+	// Events that failed to be sent to some webhook, retried until they are
+	// delivered or the retention period is over. Events such as an alert that
+	// ended are not detected again.
+	Pending []Event `json:"pending,omitempty"`
+}
+
+// func Open reads the state file at path. A missing file is an empty state.
+func Open(path string) (*State, error) {
+	s := &State{}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("invalid notification state %s: %w", path, err)
+		}
+	}
+
+	s.Path = path
+
+	if s.Sent == nil {
+		s.Sent = map[string]map[string]time.Time{}
+	}
+
+	if s.Alerts == nil {
+		s.Alerts = map[string]map[string]view.Alert{}
+	}
+
+	return s, nil
+}
+
+// func Save forgets the events sent or detected before the retention period
+// and writes the state, replacing the file atomically.
+func (s *State) Save(now time.Time) error {
+	pending := []Event{}
+
+	for _, e := range s.Pending {
+		if t, err := time.Parse(time.RFC3339, e.Time); err == nil && now.Sub(t) > Retention {
+			continue
+		}
+
+		pending = append(pending, e)
+	}
+
+	s.Pending = pending
+
+	for id, sent := range s.Sent {
+		for key, t := range sent {
+			if now.Sub(t) > Retention {
+				delete(sent, key)
+			}
+		}
+
+		if len(sent) == 0 {
+			delete(s.Sent, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp := s.Path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.Path)
+}
+
+// func IsSent reports whether an event was sent to a webhook.
+func (s *State) IsSent(w Webhook, key string) bool {
+	_, ok := s.Sent[w.ID()][key]
+
+	return ok
+}
+
+func (s *State) markSent(w Webhook, key string, t time.Time) {
+	if s.Sent[w.ID()] == nil {
+		s.Sent[w.ID()] = map[string]time.Time{}
+	}
+
+	s.Sent[w.ID()][key] = t
+}
+
+// func Deliver sends each webhook the events it hasn't been sent yet,
+// returning the number of events sent. Failed deliveries are retried by the
+// next call; the first failure of each webhook is returned.
+func (s *State) Deliver(ctx context.Context, client *http.Client, hooks []Webhook, events []Event, now time.Time) (int, error) {
+	sent := 0
+	errs := []error{}
+
+	for _, w := range hooks {
+		for _, e := range events {
+			if s.IsSent(w, e.Key) {
+				continue
+			}
+
+			if err := w.Send(ctx, client, e); err != nil {
+				errs = append(errs, err)
+
+				break
+			}
+
+			s.markSent(w, e.Key, now)
+			sent++
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
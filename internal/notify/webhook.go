@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+// Webhook formats.
+const (
+	// The Event as JSON.
+	FormatJSON string = "json"
+	// Slack incoming webhooks.
+	FormatSlack string = "slack"
+	// Discord webhooks.
+	FormatDiscord string = "discord"
+	// ntfy topics, e.g. https://ntfy.sh/mytopic.
+	FormatNtfy string = "ntfy"
+)
+
+// Formats lists the webhook formats.
+var Formats = []string{FormatJSON, FormatSlack, FormatDiscord, FormatNtfy}
+
+// Longest message accepted by Discord, in characters.
+const discordLimit int = 2000
+
+// struct Webhook is a URL that events are posted to.
+type Webhook struct {
+	Format string
+	URL    string
+}
+
+// func ParseWebhook parses a webhook given as "[format=]url". The format is
+// guessed from the host when it is not given, e.g. slack for
+// hooks.slack.com, and is json otherwise.
+func ParseWebhook(s string) (Webhook, error) {
+	s = strings.TrimSpace(s)
+	w := Webhook{URL: s}
+
+	if format, rest, ok := strings.Cut(s, "="); ok && slices.Contains(Formats, format) {
+		w.Format, w.URL = format, rest
+	}
+
+	u, err := url.Parse(w.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook %q, expected an http(s) URL optionally prefixed with %s=", s, strings.Join(Formats, "=, "))
+	}
+
+	if w.Format != "" {
+		return w, nil
+	}
+
+	switch host := u.Hostname(); {
+	case host == "hooks.slack.com":
+		w.Format = FormatSlack
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		w.Format = FormatDiscord
+	case host == "ntfy.sh":
+		w.Format = FormatNtfy
+	default:
+		w.Format = FormatJSON
+	}
+
+	return w, nil
+}
+
+// func ID identifies the webhook in the state file without storing its URL,
+// which usually embeds a secret.
+func (w Webhook) ID() string {
+	sum := sha256.Sum256([]byte(w.Format + "=" + w.URL))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// func String describes the webhook for logs, leaving out the path and query
+// that may hold a secret.
+func (w Webhook) String() string {
+	host := "?"
+
+	if u, err := url.Parse(w.URL); err == nil {
+		host = u.Host
+	}
+
+	return fmt.Sprintf("%s webhook on %s", w.Format, host)
+}
+
+// func ntfyPriority maps the severity of alerts to ntfy priorities, from 1
+// (min) to 5 (max).
+func ntfyPriority(e Event) string {
+	switch {
+	case e.Kind == KindCancel:
+		return "2"
+	case e.Severity == "Extreme":
+		return "5"
+	case e.Severity == "Severe":
+		return "4"
+	default:
+		return "3"
+	}
+}
+
+func ntfyTags(e Event) string {
+	switch e.Kind {
+	case KindCancel:
+		return "white_check_mark"
+	case KindThreshold:
+		return "thermometer"
+	default:
+		return "warning"
+	}
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-1]) + "…"
+}
+
+// func body encodes an event in the webhook's format, returning the body and
+// its content type.
+func (w Webhook) body(e Event) ([]byte, string, error) {
+	var v any
+
+	switch w.Format {
+	case FormatNtfy:
+		return []byte(e.Message), "text/plain; charset=utf-8", nil
+	case FormatSlack:
+		v = map[string]string{"text": fmt.Sprintf("*%s*\n%s", e.Title, e.Message)}
+	case FormatDiscord:
+		v = map[string]string{"content": truncate(fmt.Sprintf("**%s**\n%s", e.Title, e.Message), discordLimit)}
+	default:
+		v = e
+	}
+
+	data, err := json.Marshal(v)
+
+	return data, "application/json", err
+}
+
+// func Send posts an event to the webhook, failing on responses other than
+// 2xx.
+func (w Webhook) Send(ctx context.Context, client *http.Client, e Event) error {
+	data, contentType, err := w.body(e)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", nws.UserAgent)
+
+	if w.Format == FormatNtfy {
+		req.Header.Set("Title", mime.QEncoding.Encode("utf-8", e.Title))
+		req.Header.Set("Priority", ntfyPriority(e))
+		req.Header.Set("Tags", ntfyTags(e))
+	}
+
+	rsp, err := client.Do(req)
+
+	if err != nil {
+		// Leave out the URL.
+		if u := (*url.Error)(nil); errors.As(err, &u) {
+			err = u.Err
+		}
+
+		return fmt.Errorf("failed to post to the %s: %w", w, err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 512))
+
+		return fmt.Errorf("the %s returned %s: %s", w, rsp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
@@ -2,21 +2,62 @@ package nws
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 
 	"github.com/charmbracelet/log"
 )
 
-const baseURL string = "https://api.weather.gov"
+// BaseURL is the weather.gov API.
+const BaseURL string = "https://api.weather.gov"
+
+// UserAgent identifies geocast to weather.gov, which rejects requests
+// without one.
+const UserAgent string = "geocast (https://github.com/desertthunder/weather)"
+
+// Forecast units accepted by the API.
+const (
+	UnitsUS string = "us"
+	UnitsSI string = "si"
+)
+
+// Number of nearby observation stations tried for current conditions.
+const maxStations int = 3
 
 type WeatherClient struct {
 	baseURL string
+	units   string
 	Log     *log.Logger
 	logger  *log.Logger
 }
 
+// SetUnits selects US customary ("us", the default) or SI ("si") units.
+func (c *WeatherClient) SetUnits(units string) error {
+	switch units {
+	case "", UnitsUS:
+		c.units = ""
+	case UnitsSI:
+		c.units = UnitsSI
+	default:
+		return fmt.Errorf("unknown units %q (expected us or si)", units)
+	}
+
+	return nil
+}
+
+// Units returns the selected units, "us" or "si".
+func (c *WeatherClient) Units() string {
+	if c.units == "" {
+		return UnitsUS
+	}
+
+	return c.units
+}
+
 func (c *WeatherClient) SetURL(url string) {
 	c.baseURL = url
 }
@@ -30,17 +71,36 @@ func (c *WeatherClient) SetLogger(logger *log.Logger) {
 	c.Log = logger
 }
 
-func (c *WeatherClient) GetWeather(city City) (*ForecastAPIResponse, error) {
-	uri := city.OfficeURL()
+// PointURL returns the /points endpoint for a city, relative to the client's
+// base URL.
+func (c *WeatherClient) PointURL(city City) string {
+	return fmt.Sprintf("%s/points/%f,%f", c.baseURL, city.Lat, city.Long)
+}
+
+// struct ProblemAPIResponse is the error body returned by weather.gov.
+type ProblemAPIResponse struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
 
-	rsp, err := http.Get(uri)
+// func get fetches uri and decodes the JSON response into v.
+func (c *WeatherClient) get(uri string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
 
 	if err != nil {
-		fmt.Printf("Request to %s failed with error: %s\n", uri, err.Error())
+		return err
+	}
+
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "application/geo+json")
 
+	rsp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
 		c.logger.Error(fmt.Sprintf("Request to %s failed with error: %s", uri, err.Error()))
 
-		return nil, err
+		return err
 	}
 
 	defer rsp.Body.Close()
@@ -49,53 +109,155 @@ func (c *WeatherClient) GetWeather(city City) (*ForecastAPIResponse, error) {
 
 	if err != nil {
 		c.logger.Error(fmt.Sprintf("Failed to read response body: %s", err.Error()))
-	}
 
-	office := ForecastOfficeAPIResponse{}
+		return err
+	}
 
-	err = json.Unmarshal(data, &office)
+	if rsp.StatusCode != http.StatusOK {
+		problem := ProblemAPIResponse{}
 
-	forecastURL := office.ForecastURL()
+		if json.Unmarshal(data, &problem) == nil && problem.Detail != "" {
+			return fmt.Errorf("weather.gov returned %d: %s", rsp.StatusCode, problem.Detail)
+		}
 
-	c.logger.Debug(fmt.Sprintf("Found: %s", forecastURL))
+		return fmt.Errorf("weather.gov returned %d for %s", rsp.StatusCode, uri)
+	}
 
-	if err != nil {
+	if err := json.Unmarshal(data, v); err != nil {
 		c.logger.Error(fmt.Sprintf("Failed to unmarshal response body: %s", err.Error()))
 
+		return err
+	}
+
+	return nil
+}
+
+// func Office fetches the forecast office metadata for a city's point.
+func (c *WeatherClient) Office(city City) (*ForecastOfficeAPIResponse, error) {
+	office := ForecastOfficeAPIResponse{}
+
+	if err := c.get(c.PointURL(city), &office); err != nil {
 		return nil, err
 	}
 
-	rsp, err = http.Get(forecastURL)
+	return &office, nil
+}
+
+func (c *WeatherClient) GetWeather(city City) (*ForecastAPIResponse, error) {
+	office, err := c.Office(city)
 
 	if err != nil {
-		c.logger.Error(fmt.Sprintf("Request to %s failed with error: %s", forecastURL, err.Error()))
+		return nil, err
+	}
+
+	return c.GetForecast(office, city)
+}
 
+// func GetForecast fetches the forecast of an office's grid point, for
+// callers that also need the office metadata (e.g. its time zone).
+func (c *WeatherClient) GetForecast(office *ForecastOfficeAPIResponse, city City) (*ForecastAPIResponse, error) {
+	// /points/{lat},{lon}/forecast redirects to the office's grid forecast.
+	return c.forecast(office.ForecastURL(), c.PointURL(city)+"/forecast")
+}
+
+// func GetHourly fetches the hourly forecast of an office's grid point.
+func (c *WeatherClient) GetHourly(office *ForecastOfficeAPIResponse, city City) (*ForecastAPIResponse, error) {
+	return c.forecast(office.Properties.ForecastHourly, c.PointURL(city)+"/forecast/hourly")
+}
+
+// func forecast fetches a forecast from forecastURL, or from fallback when the
+// office didn't list one.
+func (c *WeatherClient) forecast(forecastURL, fallback string) (*ForecastAPIResponse, error) {
+	if forecastURL == "" {
+		forecastURL = fallback
+	}
+
+	c.logger.Debug(fmt.Sprintf("Found: %s", forecastURL))
+
+	if c.units != "" {
+		forecastURL = fmt.Sprintf("%s?units=%s", forecastURL, c.units)
+	}
+
+	fc := ForecastAPIResponse{}
+
+	if err := c.get(forecastURL, &fc); err != nil {
 		return nil, err
 	}
 
-	defer rsp.Body.Close()
+	return &fc, nil
+}
 
-	data, err = io.ReadAll(rsp.Body)
+// func GetAlerts fetches the active alerts for a city's point.
+func (c *WeatherClient) GetAlerts(city City) (*AlertsAPIResponse, error) {
+	uri := fmt.Sprintf("%s/alerts/active?point=%s", c.baseURL, url.QueryEscape(fmt.Sprintf("%.4f,%.4f", city.Lat, city.Long)))
 
-	if err != nil {
-		c.logger.Error(fmt.Sprintf("Failed to read response body: %s", err.Error()))
+	alerts := AlertsAPIResponse{}
 
+	if err := c.get(uri, &alerts); err != nil {
 		return nil, err
 	}
 
-	fc := ForecastAPIResponse{}
+	return &alerts, nil
+}
 
-	err = json.Unmarshal(data, &fc)
+// func GetConditions fetches the latest observation from the stations nearest
+// to a city, skipping stations that have not reported a temperature.
+func (c *WeatherClient) GetConditions(city City) (*ObservationAPIResponse, error) {
+	office, err := c.Office(city)
 
 	if err != nil {
-		c.logger.Error(fmt.Sprintf("Failed to unmarshal response body: %s", err.Error()))
+		return nil, err
+	}
+
+	stationsURL := office.Properties.Stations
+
+	if stationsURL == "" {
+		stationsURL = c.PointURL(city) + "/stations"
+	}
 
+	stations := StationsAPIResponse{}
+
+	if err := c.get(stationsURL, &stations); err != nil {
 		return nil, err
 	}
 
-	return &fc, nil
+	var last *ObservationAPIResponse
+
+	for i, station := range stations.Features {
+		if i == maxStations {
+			break
+		}
+
+		id := station.Properties.StationIdentifier
+
+		if id == "" {
+			id = path.Base(station.ID)
+		}
+
+		obs := ObservationAPIResponse{}
+
+		if err := c.get(fmt.Sprintf("%s/stations/%s/observations/latest", c.baseURL, url.PathEscape(id)), &obs); err != nil {
+			c.logger.Debug(fmt.Sprintf("No observation from %s: %s", id, err.Error()))
+
+			continue
+		}
+
+		obs.Station = station.Properties
+		obs.Station.StationIdentifier = id
+		last = &obs
+
+		if obs.Properties.Temperature.Value != nil {
+			return &obs, nil
+		}
+	}
+
+	if last == nil {
+		return nil, errors.New("no observation stations reported current conditions")
+	}
+
+	return last, nil
 }
 
 func NewWeatherClient() *WeatherClient {
-	return &WeatherClient{baseURL: baseURL}
+	return &WeatherClient{baseURL: BaseURL}
 }
@@ -79,6 +79,142 @@ type ForecastOfficeAPIResponse struct {
 	} `json:"properties"`
 }
 
+// struct Quantity is a measurement with a WMO unit code, e.g.
+// {"unitCode": "wmoUnit:degC", "value": 21.5}. Value is nil when the station
+// did not report it.
+type Quantity struct {
+	UnitCode string   `json:"unitCode"`
+	Value    *float64 `json:"value"`
+}
+
+type StationAPIResponse struct {
+	StationIdentifier string `json:"stationIdentifier"`
+	Name              string `json:"name"`
+	TimeZone          string `json:"timeZone"`
+}
+
+type StationsAPIResponse struct {
+	Features []struct {
+		ID         string             `json:"id"`
+		Properties StationAPIResponse `json:"properties"`
+	} `json:"features"`
+}
+
+type ObservationAPIResponse struct {
+	// Station is filled in from the stations list, it is not part of the
+	// observation response.
+	Station    StationAPIResponse `json:"-"`
+	Properties struct {
+		Station            string   `json:"station"`
+		Timestamp          string   `json:"timestamp"`
+		TextDescription    string   `json:"textDescription"`
+		Temperature        Quantity `json:"temperature"`
+		Dewpoint           Quantity `json:"dewpoint"`
+		WindDirection      Quantity `json:"windDirection"`
+		WindSpeed          Quantity `json:"windSpeed"`
+		WindGust           Quantity `json:"windGust"`
+		BarometricPressure Quantity `json:"barometricPressure"`
+		Visibility         Quantity `json:"visibility"`
+		RelativeHumidity   Quantity `json:"relativeHumidity"`
+		HeatIndex          Quantity `json:"heatIndex"`
+		WindChill          Quantity `json:"windChill"`
+	} `json:"properties"`
+}
+
+type AlertAPIResponse struct {
+	ID          string `json:"id"`
+	AreaDesc    string `json:"areaDesc"`
+	Sent        string `json:"sent"`
+	Effective   string `json:"effective"`
+	Onset       string `json:"onset"`
+	Expires     string `json:"expires"`
+	Ends        string `json:"ends"`
+	Status      string `json:"status"`
+	MessageType string `json:"messageType"`
+	Severity    string `json:"severity"`
+	Certainty   string `json:"certainty"`
+	Urgency     string `json:"urgency"`
+	Event       string `json:"event"`
+	SenderName  string `json:"senderName"`
+	Headline    string `json:"headline"`
+	Description string `json:"description"`
+	Instruction string `json:"instruction"`
+	// Earlier alerts that an update or cancellation replaces.
+	References []AlertReference `json:"references"`
+}
+
+// struct AlertReference identifies an earlier alert.
+type AlertReference struct {
+	URL        string `json:"@id"`
+	Identifier string `json:"identifier"`
+	Sender     string `json:"sender"`
+	Sent       string `json:"sent"`
+}
+
+type AlertsAPIResponse struct {
+	Features []struct {
+		Properties AlertAPIResponse `json:"properties"`
+	} `json:"features"`
+}
+
+// Alerts returns the properties of each alert.
+func (a AlertsAPIResponse) Alerts() []AlertAPIResponse {
+	alerts := []AlertAPIResponse{}
+
+	for _, f := range a.Features {
+		alerts = append(alerts, f.Properties)
+	}
+
+	return alerts
+}
+
+// Convert returns the quantity in US customary ("us") or SI ("si") units:
+// °F or °C, mph or km/h, inHg or hPa and mi or km. Other units (percent,
+// degrees) are returned as is.
+func (q Quantity) Convert(units string) *float64 {
+	if q.Value == nil {
+		return nil
+	}
+
+	v := *q.Value
+	us := units != UnitsSI
+
+	switch strings.TrimPrefix(q.UnitCode, "wmoUnit:") {
+	case "degC":
+		if us {
+			v = v*9/5 + 32
+		}
+	case "degF":
+		if !us {
+			v = (v - 32) * 5 / 9
+		}
+	case "km_h-1":
+		if us {
+			v = v / 1.609344
+		}
+	case "m_s-1":
+		v = v * 3.6
+
+		if us {
+			v = v / 1.609344
+		}
+	case "Pa":
+		if us {
+			v = v / 3386.389
+		} else {
+			v = v / 100
+		}
+	case "m":
+		if us {
+			v = v / 1609.344
+		} else {
+			v = v / 1000
+		}
+	}
+
+	return &v
+}
+
 func (p PeriodAPIResponse) Wind() string {
 	return fmt.Sprintf("%s %s", p.WindSpeed, p.WindDirection)
 }
@@ -0,0 +1,229 @@
+// Package places stores the user's saved locations (e.g. "home" or
+// "office") so they can be forecast without geocoding.
+//
+// The places are kept in a TOML file next to the config file:
+//
+//	default = "home"
+//
+//	[[places]]
+//	name = "home"
+//	label = "Austin, Texas"
+//	lat = 30.2672
+//	lon = -97.7431
+package places
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Name of the places file in the config directory.
+const File string = "places.toml"
+
+// Prefix used to refer to a saved place in place of a location, e.g. "@home".
+const Prefix string = "@"
+
+// ErrNotFound is returned for names that are not saved.
+var ErrNotFound = errors.New("no saved place")
+
+// Names start with a letter and may contain letters, digits, "-" and "_".
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// struct Place is a saved location.
+type Place struct {
+	Name  string  `toml:"name" json:"name"`
+	Label string  `toml:"label,omitempty" json:"label"`
+	Lat   float64 `toml:"lat" json:"latitude"`
+	Lon   float64 `toml:"lon" json:"longitude"`
+}
+
+// func City converts the place to a nws.City, named after its label.
+func (p Place) City() nws.City {
+	name := p.Label
+
+	if name == "" {
+		name = p.Name
+	}
+
+	return nws.City{Name: name, Lat: p.Lat, Long: p.Lon}
+}
+
+// struct Store is the list of saved places and the path it is saved to.
+type Store struct {
+	Path    string  `toml:"-"`
+	Default string  `toml:"default,omitempty"`
+	Places  []Place `toml:"places"`
+}
+
+// func Open reads the places file at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{Path: path}
+
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := toml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid places file %s: %w", path, err)
+	}
+
+	s.Path = path
+
+	return s, nil
+}
+
+// func Save writes the store to its path, creating the directory if needed.
+func (s *Store) Save() error {
+	data, err := toml.Marshal(s)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return os.WriteFile(s.Path, data, 0o644)
+}
+
+// func Name strips the "@" prefix from a place name, if present.
+func Name(s string) string {
+	return strings.TrimPrefix(s, Prefix)
+}
+
+// func ValidateName checks that a name can be used as "@name".
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid place name %q, use a letter followed by letters, digits, - or _", name)
+	}
+
+	return nil
+}
+
+func (s *Store) index(name string) int {
+	name = Name(name)
+
+	return slices.IndexFunc(s.Places, func(p Place) bool {
+		return strings.EqualFold(p.Name, name)
+	})
+}
+
+// func Get returns the place saved as name (case insensitive, with or
+// without the "@" prefix).
+func (s *Store) Get(name string) (Place, error) {
+	i := s.index(name)
+
+	if i < 0 {
+		return Place{}, fmt.Errorf("%w named %q, see geocast places list", ErrNotFound, Name(name))
+	}
+
+	return s.Places[i], nil
+}
+
+// func DefaultPlace returns the default place, if one is set.
+func (s *Store) DefaultPlace() (Place, bool) {
+	if s.Default == "" {
+		return Place{}, false
+	}
+
+	p, err := s.Get(s.Default)
+
+	return p, err == nil
+}
+
+// func Add saves a new place.
+func (s *Store) Add(p Place) error {
+	p.Name = Name(p.Name)
+
+	if err := ValidateName(p.Name); err != nil {
+		return err
+	}
+
+	if s.index(p.Name) >= 0 {
+		return fmt.Errorf("a place named %q is already saved", p.Name)
+	}
+
+	s.Places = append(s.Places, p)
+
+	return nil
+}
+
+// func Remove deletes a place, clearing the default if it was the default.
+func (s *Store) Remove(name string) error {
+	p, err := s.Get(name)
+
+	if err != nil {
+		return err
+	}
+
+	s.Places = slices.Delete(s.Places, s.index(name), s.index(name)+1)
+
+	if strings.EqualFold(s.Default, p.Name) {
+		s.Default = ""
+	}
+
+	return nil
+}
+
+// func Rename changes the name of a place, keeping it as the default if it
+// was the default.
+func (s *Store) Rename(from, to string) error {
+	to = Name(to)
+
+	if err := ValidateName(to); err != nil {
+		return err
+	}
+
+	i := s.index(from)
+
+	if i < 0 {
+		return fmt.Errorf("%w named %q, see geocast places list", ErrNotFound, Name(from))
+	}
+
+	if j := s.index(to); j >= 0 && j != i {
+		return fmt.Errorf("a place named %q is already saved", to)
+	}
+
+	if strings.EqualFold(s.Default, s.Places[i].Name) {
+		s.Default = to
+	}
+
+	s.Places[i].Name = to
+
+	return nil
+}
+
+// func SetDefault selects the place used when no location is given. An empty
+// name clears the default.
+func (s *Store) SetDefault(name string) error {
+	if name == "" {
+		s.Default = ""
+
+		return nil
+	}
+
+	p, err := s.Get(name)
+
+	if err != nil {
+		return err
+	}
+
+	s.Default = p.Name
+
+	return nil
+}
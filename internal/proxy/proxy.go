@@ -0,0 +1,478 @@
+// Package proxy is the caching reverse proxy for the weather.gov API started
+// by geocast proxy. Teams point NWS_URL at a shared proxy, so that CI and
+// developer machines don't each hit weather.gov for the same forecasts.
+//
+// Responses are cached on disk for as long as weather.gov's Cache-Control
+// (or Expires) headers allow, identical requests that arrive while one is in
+// flight share its response, and every request is sent with the User-Agent
+// weather.gov requires. Absolute URLs in responses (e.g. a point's forecast
+// URL) are rewritten to the proxy, so that clients keep using it.
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+// Address geocast proxy listens on by default.
+const DefaultAddr string = ":8081"
+
+// Path of the stats endpoint, which weather.gov paths never start with.
+const StatsPath string = "/_proxy/stats"
+
+// How long expired responses are served by default when weather.gov fails.
+const DefaultMaxStale time.Duration = time.Hour
+
+// How often responses that can't be served anymore are removed from the
+// cache.
+const PruneInterval time.Duration = 10 * time.Minute
+
+// Prefix of the cache keys of responses.
+const keyPrefix string = "proxy-"
+
+// Response headers kept in the cache and sent to clients.
+var keepHeaders = []string{"Content-Type", "Cache-Control", "Expires", "Last-Modified", "X-Correlation-Id"}
+
+// Request headers that change responses and are sent upstream.
+var varyHeaders = []string{"Accept", "Feature-Flags"}
+
+// struct response is a cached upstream response.
+type response struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Fetched time.Time   `json:"fetched"`
+	Expires time.Time   `json:"expires"`
+}
+
+func (r response) fresh(now time.Time) bool {
+	return now.Before(r.Expires)
+}
+
+// struct flight is a request to weather.gov that identical requests wait for.
+type flight struct {
+	done chan struct{}
+	rsp  response
+	err  error
+}
+
+// struct Stats counts the requests served since the proxy started.
+type Stats struct {
+	Upstream string `json:"upstream"`
+	Uptime   string `json:"uptime"`
+	Requests int64  `json:"requests"`
+	// Served from the cache.
+	Hits int64 `json:"hits"`
+	// Sent to weather.gov.
+	Misses int64 `json:"misses"`
+	// Answered with the response of an identical request in flight.
+	Coalesced int64 `json:"coalesced"`
+	// Answered with an expired response because weather.gov failed.
+	Stale int64 `json:"stale"`
+	// Failed requests to weather.gov.
+	Errors   int64   `json:"errors"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// struct Proxy serves weather.gov through a cache.
+type Proxy struct {
+	// weather.gov, or another proxy.
+	Upstream  string
+	UserAgent string
+	// Cache directory, or nil to only coalesce requests.
+	Store *cache.Store
+	// How long expired responses are served when weather.gov fails.
+	MaxStale time.Duration
+	Client   *http.Client
+	Log      *log.Logger
+
+	mu      sync.Mutex
+	flights map[string]*flight
+	started time.Time
+
+	requests, hits, misses, coalesced, stale, errors atomic.Int64
+}
+
+// Proxy constructor.
+func New(upstream string, store *cache.Store, logger *log.Logger) *Proxy {
+	return &Proxy{
+		Upstream:  strings.TrimSuffix(upstream, "/"),
+		UserAgent: nws.UserAgent,
+		Store:     store,
+		MaxStale:  DefaultMaxStale,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		Log:       logger,
+		flights:   map[string]*flight{},
+		started:   time.Now(),
+	}
+}
+
+// func Stats returns the proxy's counters.
+func (p *Proxy) Stats() Stats {
+	s := Stats{
+		Upstream:  p.Upstream,
+		Uptime:    time.Since(p.started).Round(time.Second).String(),
+		Requests:  p.requests.Load(),
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+		Coalesced: p.coalesced.Load(),
+		Stale:     p.stale.Load(),
+		Errors:    p.errors.Load(),
+	}
+
+	if s.Requests > 0 {
+		s.HitRatio = float64(s.Hits+s.Coalesced) / float64(s.Requests)
+	}
+
+	return s
+}
+
+// func Handler returns the proxy and its stats endpoint.
+func (p *Proxy) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+StatsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(p.Stats())
+	})
+	mux.HandleFunc("/", p.proxy)
+
+	return mux
+}
+
+// func cacheKey identifies a request by its URL and the headers that change
+// its response.
+func cacheKey(r *http.Request) string {
+	h := sha256.New()
+
+	io.WriteString(h, r.URL.RequestURI())
+
+	for _, name := range varyHeaders {
+		io.WriteString(h, "\n"+r.Header.Get(name))
+	}
+
+	return keyPrefix + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// func expiry reads how long a response may be cached from its
+// Cache-Control or Expires header. Responses without either are not cached.
+func expiry(h http.Header, now time.Time) (time.Time, bool) {
+	maxAge, sMaxAge := -1, -1
+
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(d)), "=")
+
+		switch name {
+		case "no-store", "no-cache", "private":
+			return time.Time{}, false
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				maxAge = n
+			}
+		case "s-maxage":
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				sMaxAge = n
+			}
+		}
+	}
+
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+
+	if maxAge >= 0 {
+		return now.Add(time.Duration(maxAge) * time.Second), maxAge > 0
+	}
+
+	expires, err := http.ParseTime(h.Get("Expires"))
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// Expires is relative to the upstream clock.
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		expires = now.Add(expires.Sub(date))
+	}
+
+	return expires, expires.After(now)
+}
+
+// func fetch sends a request to weather.gov and caches its response when the
+// headers allow it.
+func (p *Proxy) fetch(r *http.Request, key string) (response, error) {
+	p.misses.Add(1)
+
+	// HEAD requests are sent as GET, so that their responses can be cached
+	// and shared with GET requests.
+	req, err := http.NewRequest(http.MethodGet, p.Upstream+r.URL.RequestURI(), nil)
+
+	if err != nil {
+		return response{}, err
+	}
+
+	for _, name := range varyHeaders {
+		if v := r.Header.Get(name); v != "" {
+			req.Header.Set(name, v)
+		}
+	}
+
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	rsp, err := p.Client.Do(req)
+
+	if err != nil {
+		return response{}, err
+	}
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+
+	if err != nil {
+		return response{}, err
+	}
+
+	now := time.Now()
+	res := response{Status: rsp.StatusCode, Header: http.Header{}, Body: body, Fetched: now}
+
+	for _, name := range keepHeaders {
+		if v := rsp.Header.Get(name); v != "" {
+			res.Header.Set(name, v)
+		}
+	}
+
+	expires, ok := expiry(rsp.Header, now)
+
+	if !ok || rsp.StatusCode != http.StatusOK || p.Store == nil {
+		return res, nil
+	}
+
+	res.Expires = expires
+
+	if err := p.Store.Put(key, p.Upstream, res); err != nil {
+		p.Log.Debug(fmt.Sprintf("Could not cache %s: %s", r.URL.RequestURI(), err.Error()))
+	}
+
+	return res, nil
+}
+
+// func coalesce fetches a request, unless an identical one is in flight, in
+// which case its response is shared. The second result is true for shared
+// responses.
+func (p *Proxy) coalesce(r *http.Request, key string) (response, bool, error) {
+	p.mu.Lock()
+
+	if f, ok := p.flights[key]; ok {
+		p.mu.Unlock()
+		<-f.done
+
+		return f.rsp, true, f.err
+	}
+
+	f := &flight{done: make(chan struct{})}
+	p.flights[key] = f
+	p.mu.Unlock()
+
+	f.rsp, f.err = p.fetch(r, key)
+
+	p.mu.Lock()
+	delete(p.flights, key)
+	p.mu.Unlock()
+	close(f.done)
+
+	return f.rsp, false, f.err
+}
+
+// func cached returns the response cached for key, fresh or not.
+func (p *Proxy) cached(key string) (response, bool) {
+	if p.Store == nil {
+		return response{}, false
+	}
+
+	res := response{}
+	meta, err := p.Store.Get(key, &res)
+
+	if err != nil || meta.Tag != p.Upstream {
+		return response{}, false
+	}
+
+	return res, true
+}
+
+// func Prune removes the cached responses that expired more than MaxStale
+// ago, which are never served again, returning how many were removed. Every
+// distinct URL is cached in its own file, so the cache would otherwise grow
+// without bound.
+func (p *Proxy) Prune(now time.Time) (int, error) {
+	if p.Store == nil {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(p.Store.Dir)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, e := range entries {
+		key, ok := strings.CutSuffix(e.Name(), ".json")
+
+		if !ok || !strings.HasPrefix(key, keyPrefix) {
+			continue
+		}
+
+		res := response{}
+
+		// Unreadable entries are misses and are removed too.
+		if _, err := p.Store.Get(key, &res); err == nil && now.Sub(res.Expires) < p.MaxStale {
+			continue
+		}
+
+		if err := p.Store.Delete(key); err != nil {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (p *Proxy) proxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		problem(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported by the proxy", r.Method))
+
+		return
+	}
+
+	p.requests.Add(1)
+
+	key := cacheKey(r)
+	now := time.Now()
+	cached, ok := p.cached(key)
+
+	if ok && cached.fresh(now) {
+		p.hits.Add(1)
+		p.write(w, r, cached, "HIT")
+
+		return
+	}
+
+	res, shared, err := p.coalesce(r, key)
+
+	status := "MISS"
+
+	if shared {
+		p.coalesced.Add(1)
+		status = "COALESCED"
+	}
+
+	if err == nil && res.Status < http.StatusInternalServerError {
+		p.write(w, r, res, status)
+
+		return
+	}
+
+	if !shared {
+		p.errors.Add(1)
+	}
+
+	if ok && now.Sub(cached.Expires) < p.MaxStale {
+		if !shared {
+			p.Log.Warn(fmt.Sprintf("weather.gov failed, serving %s cached %s ago.", r.URL.RequestURI(), now.Sub(cached.Fetched).Round(time.Second)))
+		}
+
+		p.stale.Add(1)
+		p.write(w, r, cached, "STALE")
+
+		return
+	}
+
+	if err != nil {
+		problem(w, http.StatusBadGateway, err.Error())
+
+		return
+	}
+
+	p.write(w, r, res, status)
+}
+
+// func write sends a response with the upstream's URLs rewritten to the
+// proxy. X-Cache tells whether it came from the cache.
+func (p *Proxy) write(w http.ResponseWriter, r *http.Request, res response, status string) {
+	body := bytes.ReplaceAll(res.Body, []byte(p.Upstream), []byte(externalURL(r)))
+
+	for name, values := range res.Header {
+		w.Header()[name] = values
+	}
+
+	if !res.Fetched.IsZero() {
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(res.Fetched).Seconds())))
+	}
+
+	w.Header().Set("X-Cache", status)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(res.Status)
+
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+
+	p.Log.Debug(fmt.Sprintf("%s %s %d %s", r.Method, r.URL.RequestURI(), res.Status, status))
+}
+
+// func externalURL returns the proxy's URL as seen by the client, behind
+// other proxies too.
+func externalURL(r *http.Request) string {
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if s := r.Header.Get("X-Forwarded-Proto"); s != "" {
+		scheme = s
+	}
+
+	host := r.Host
+
+	if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+		host = h
+	}
+
+	return scheme + "://" + host
+}
+
+// func problem writes an error in the same format as weather.gov.
+func problem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(nws.ProblemAPIResponse{Title: http.StatusText(status), Status: status, Detail: detail})
+}
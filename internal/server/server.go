@@ -0,0 +1,385 @@
+// Package server is the HTTP API started by geocast serve. It exposes the
+// results of the forecast, alerts, now and geocode commands as JSON, so that
+// dashboards don't each deal with weather.gov and Nominatim themselves.
+//
+// Every endpoint takes a location (see Locator) and responds with the json
+// schema of the matching command, or with a problem document on errors:
+//
+//	{"title": "Bad Request", "status": 400, "detail": "..."}
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/ical"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// Address geocast serve listens on by default.
+const DefaultAddr string = ":8080"
+
+// How long in-flight requests are given to finish on shutdown.
+const ShutdownTimeout time.Duration = 10 * time.Second
+
+//go:embed openapi.json
+var openAPI []byte
+
+// ErrNoLocation is returned by Locators for requests without a location.
+var ErrNoLocation = errors.New("a location is required: q, lat and lon, zip, city, place or ip")
+
+// func Locator resolves the location of a request from its query parameters:
+// q (anything geocast accepts as a location argument), lat and lon, zip,
+// city, place or ip.
+type Locator func(r *http.Request) (nws.City, error)
+
+// func WeatherClient returns a weather.gov client in the given units ("us",
+// "si" or empty for the default).
+type WeatherClient func(units string) (*nws.WeatherClient, error)
+
+// struct Server serves the API.
+type Server struct {
+	Locate  Locator
+	Weather WeatherClient
+	// Caches weather.gov responses. The tag is set to the client's base URL.
+	Cache cache.Fetcher
+	// Served at /metrics, if set.
+	Metrics http.Handler
+	// Origin allowed to call the API from browsers (e.g. * or
+	// https://dashboard.example.com). Cross-origin requests are not allowed
+	// when empty.
+	CORSOrigin string
+	Log        *log.Logger
+}
+
+// Server constructor.
+func New(locate Locator, weather WeatherClient, logger *log.Logger) *Server {
+	return &Server{Locate: locate, Weather: weather, Log: logger}
+}
+
+// struct forecastResult is a forecast with the time zone of its office,
+// cached for both /forecast and /forecast.ics.
+type forecastResult struct {
+	Forecast view.Forecast `json:"forecast"`
+	TimeZone string        `json:"time_zone"`
+}
+
+// func Handler returns the API's routes, with request logging.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /forecast", s.forecast)
+	mux.HandleFunc("GET /forecast.ics", s.calendar)
+	mux.HandleFunc("GET /hourly", s.hourly)
+	mux.HandleFunc("GET /alerts", s.alerts)
+	mux.HandleFunc("GET /now", s.conditions)
+	mux.HandleFunc("GET /geocode", s.geocode)
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	if s.Metrics != nil {
+		mux.Handle("GET /metrics", s.Metrics)
+	}
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeProblem(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed, the API is read only", r.Method))
+
+			return
+		}
+
+		writeProblem(w, http.StatusNotFound, fmt.Errorf("no endpoint %s, see /openapi.json", r.URL.Path))
+	})
+
+	return s.logRequests(mux)
+}
+
+// func ListenAndServe serves the API on addr until ctx is done, then waits
+// up to ShutdownTimeout for in-flight requests.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	return Listen(ctx, addr, s.Handler(), s.Log)
+}
+
+// func Listen serves h on addr until ctx is done, then waits up to
+// ShutdownTimeout for in-flight requests. It is shared by the commands that
+// run servers, such as geocast serve and geocast proxy.
+func Listen(ctx context.Context, addr string, h http.Handler, logger *log.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	logger.Info(fmt.Sprintf("Listening on http://%s", ln.Addr()))
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down.")
+
+	shutdown, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdown)
+}
+
+// struct statusWriter records the status of a response for logging.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// func logRequests logs each request with its status and duration, and
+// allows dashboards on CORSOrigin to call the API.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{w, http.StatusOK}
+
+		if s.CORSOrigin != "" {
+			sw.Header().Set("Access-Control-Allow-Origin", s.CORSOrigin)
+		}
+
+		next.ServeHTTP(sw, r)
+
+		s.Log.Info(fmt.Sprintf("%s %s %d %s", r.Method, r.URL.RequestURI(), sw.status, time.Since(start).Round(time.Millisecond)))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// func writeProblem writes an error in the same format as weather.gov.
+func writeProblem(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(nws.ProblemAPIResponse{Title: http.StatusText(status), Status: status, Detail: err.Error()})
+}
+
+// func fetch locates a request and fetches its weather with fn, cached per
+// endpoint, location and units. On errors, the status of the response is
+// returned along with the error.
+func fetch[T any](s *Server, r *http.Request, endpoint string, fn func(*nws.WeatherClient, nws.City) (T, error)) (T, int, error) {
+	var zero T
+
+	city, err := s.Locate(r)
+
+	if err != nil {
+		return zero, http.StatusBadRequest, err
+	}
+
+	client, err := s.Weather(r.URL.Query().Get("units"))
+
+	if err != nil {
+		return zero, http.StatusBadRequest, err
+	}
+
+	f := s.Cache
+	f.Tag = client.BaseURL()
+	key := fmt.Sprintf("serve-%s-%.4f,%.4f-%s", endpoint, city.Lat, city.Long, client.Units())
+
+	v, err := cache.Fetch(f, key, func() (T, error) {
+		return fn(client, city)
+	})
+
+	if err != nil {
+		return zero, http.StatusBadGateway, err
+	}
+
+	return v, http.StatusOK, nil
+}
+
+func fetchForecast(w *nws.WeatherClient, city nws.City) (forecastResult, error) {
+	office, err := w.Office(city)
+
+	if err != nil {
+		return forecastResult{}, err
+	}
+
+	fc, err := w.GetForecast(office, city)
+
+	if err != nil {
+		return forecastResult{}, err
+	}
+
+	return forecastResult{view.NewForecast(city, w.Units(), fc.Properties.Periods), office.Properties.Timezone}, nil
+}
+
+func fetchHourly(w *nws.WeatherClient, city nws.City) (view.Forecast, error) {
+	office, err := w.Office(city)
+
+	if err != nil {
+		return view.Forecast{}, err
+	}
+
+	fc, err := w.GetHourly(office, city)
+
+	if err != nil {
+		return view.Forecast{}, err
+	}
+
+	return view.NewForecast(city, w.Units(), fc.Properties.Periods), nil
+}
+
+func fetchAlerts(w *nws.WeatherClient, city nws.City) (view.Alerts, error) {
+	alerts, err := w.GetAlerts(city)
+
+	if err != nil {
+		return view.Alerts{}, err
+	}
+
+	return view.NewAlerts(city, alerts.Alerts()), nil
+}
+
+func fetchConditions(w *nws.WeatherClient, city nws.City) (view.Conditions, error) {
+	obs, err := w.GetConditions(city)
+
+	if err != nil {
+		return view.Conditions{}, err
+	}
+
+	return view.NewConditions(city, w.Units(), *obs), nil
+}
+
+func (s *Server) forecast(w http.ResponseWriter, r *http.Request) {
+	v, status, err := fetch(s, r, "forecast", fetchForecast)
+
+	if err != nil {
+		writeProblem(w, status, err)
+
+		return
+	}
+
+	writeJSON(w, status, v.Forecast)
+}
+
+func (s *Server) hourly(w http.ResponseWriter, r *http.Request) {
+	v, status, err := fetch(s, r, "hourly", fetchHourly)
+
+	if err != nil {
+		writeProblem(w, status, err)
+
+		return
+	}
+
+	writeJSON(w, status, v)
+}
+
+func (s *Server) alerts(w http.ResponseWriter, r *http.Request) {
+	v, status, err := fetch(s, r, "alerts", fetchAlerts)
+
+	if err != nil {
+		writeProblem(w, status, err)
+
+		return
+	}
+
+	writeJSON(w, status, v)
+}
+
+func (s *Server) conditions(w http.ResponseWriter, r *http.Request) {
+	v, status, err := fetch(s, r, "now", fetchConditions)
+
+	if err != nil {
+		writeProblem(w, status, err)
+
+		return
+	}
+
+	writeJSON(w, status, v)
+}
+
+func (s *Server) geocode(w http.ResponseWriter, r *http.Request) {
+	city, err := s.Locate(r)
+
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, view.NewLocation(city))
+}
+
+// func calendar serves the forecast and active alerts as an iCalendar file
+// that calendar apps can subscribe to. Alerts are left out when they can't be
+// fetched, and with alerts=false.
+func (s *Server) calendar(w http.ResponseWriter, r *http.Request) {
+	f, status, err := fetch(s, r, "forecast", fetchForecast)
+
+	if err != nil {
+		writeProblem(w, status, err)
+
+		return
+	}
+
+	active := []view.Alert{}
+
+	if r.URL.Query().Get("alerts") != "false" {
+		a, _, err := fetch(s, r, "alerts", fetchAlerts)
+
+		if err != nil {
+			s.Log.Warn(fmt.Sprintf("Could not fetch alerts: %s", err.Error()))
+		} else {
+			active = a.Alerts
+		}
+	}
+
+	loc := time.UTC
+
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		f.TimeZone = tz
+	}
+
+	if f.TimeZone != "" {
+		l, err := time.LoadLocation(f.TimeZone)
+
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Errorf("unknown time zone %q", f.TimeZone))
+
+			return
+		}
+
+		loc = l
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+
+	view.NewCalendar(f.Forecast, active, loc).Encode(w)
+}
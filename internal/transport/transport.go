@@ -0,0 +1,101 @@
+// Package transport provides the HTTP transport shared by the API clients. It
+// traces requests to the debug log with secrets redacted.
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/logger"
+)
+
+// func ObserveFunc is called after each request, e.g. for metrics. status is
+// 0 and err is set when no response was received.
+type ObserveFunc func(req *http.Request, status int, err error, d time.Duration)
+
+// struct Tracing is an http.RoundTripper that logs each request's method,
+// redacted URL, status and duration at the debug level.
+type Tracing struct {
+	// Transport used to make the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Logger for the traces.
+	Log *log.Logger
+
+	// Set with SetObserver, while requests may be in flight.
+	observe atomic.Pointer[ObserveFunc]
+}
+
+// Tracing transport constructor.
+func New(l *log.Logger) *Tracing {
+	return &Tracing{Base: http.DefaultTransport, Log: l}
+}
+
+// func Install traces every request made with http.DefaultClient (and the
+// http.Get/http.Post helpers).
+func Install(l *log.Logger) {
+	if _, ok := http.DefaultClient.Transport.(*Tracing); ok {
+		return
+	}
+
+	http.DefaultClient.Transport = New(l)
+}
+
+// func Observe reports every request made with http.DefaultClient to f, or
+// stops reporting them when f is nil. It must be called after Install.
+func Observe(f ObserveFunc) {
+	if t, ok := http.DefaultClient.Transport.(*Tracing); ok {
+		t.SetObserver(f)
+	}
+}
+
+// func SetObserver reports the requests made with the transport to f, or
+// stops reporting them when f is nil. It is safe to call while requests are
+// made.
+func (t *Tracing) SetObserver(f ObserveFunc) {
+	if f == nil {
+		t.observe.Store(nil)
+
+		return
+	}
+
+	t.observe.Store(&f)
+}
+
+func (t *Tracing) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	uri := logger.Redact(req.URL.String())
+
+	rsp, err := base.RoundTrip(req)
+
+	d := time.Since(start)
+	elapsed := d.Round(time.Millisecond)
+
+	if observe := t.observe.Load(); observe != nil {
+		status := 0
+
+		if rsp != nil {
+			status = rsp.StatusCode
+		}
+
+		(*observe)(req, status, err, d)
+	}
+
+	if err != nil {
+		t.Log.Debug(fmt.Sprintf("%s %s failed after %s: %s", req.Method, uri, elapsed, logger.Redact(err.Error())))
+
+		return rsp, err
+	}
+
+	t.Log.Debug(fmt.Sprintf("%s %s %d (%s)", req.Method, uri, rsp.StatusCode, elapsed))
+
+	return rsp, nil
+}
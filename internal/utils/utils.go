@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
 	"strings"
 )
@@ -53,24 +54,81 @@ func PrintJSON(data interface{}) {
 	fmt.Println(string(s))
 }
 
-// func ValidateIPAddress validates an IP address string.
+// Address ranges that cannot be geolocated because they are not routable on
+// the public internet.
+var reservedPrefixes = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("100.64.0.0/10"), "carrier-grade NAT (CGNAT)"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignment"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "documentation (TEST-NET-1)"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "benchmarking"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "documentation (TEST-NET-2)"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "documentation (TEST-NET-3)"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved"},
+	{netip.MustParsePrefix("2001:db8::/32"), "documentation"},
+}
+
+// func ParseIPAddress parses an IPv4 or IPv6 address string and checks that
+// it is a public, routable address that can be geolocated.
 //
-// It checks if the IP address string is in the correct format and has four
-// parts (e.g., 192.168.1.1). Each part is checked via validateIPParts.
-func ValidateIPAddress(ipaddr string) bool {
-	parts := strings.Split(ipaddr, ".")
+// Private, loopback, link-local, CGNAT, multicast and other reserved ranges
+// are rejected with an error describing why.
+func ParseIPAddress(ipaddr string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ipaddr))
 
-	return len(parts) == 4 && validateIPParts(parts)
-}
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%q is not a valid IPv4 or IPv6 address", ipaddr)
+	}
+
+	addr = addr.Unmap()
+
+	reason := ""
+
+	switch {
+	case addr.Zone() != "":
+		reason = "scoped (zoned)"
+	case addr.IsUnspecified():
+		reason = "unspecified"
+	case addr.IsLoopback():
+		reason = "loopback"
+	case addr.IsPrivate():
+		reason = "private"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		reason = "link-local"
+	case addr.IsMulticast():
+		reason = "multicast"
+	case addr.Is4() && addr.As4()[0] == 0:
+		reason = "reserved (\"this network\")"
+	}
 
-func validateIPParts(parts []string) bool {
-	for _, p := range parts[0:3] {
-		if len(p) < 1 || len(p) > 3 || p == "0" {
-			return false
+	for _, r := range reservedPrefixes {
+		if reason == "" && r.prefix.Contains(addr) {
+			reason = r.name
 		}
 	}
 
-	return true
+	if reason != "" {
+		article := "a"
+
+		if strings.ContainsRune("aeiou", rune(reason[0])) {
+			article = "an"
+		}
+
+		return netip.Addr{}, fmt.Errorf("%s is %s %s address and cannot be geolocated", addr, article, reason)
+	}
+
+	return addr, nil
+}
+
+// func ValidateIPAddress reports whether an IP address string is a valid,
+// public IPv4 or IPv6 address. See ParseIPAddress for the reason an address
+// is rejected.
+func ValidateIPAddress(ipaddr string) bool {
+	_, err := ParseIPAddress(ipaddr)
+
+	return err == nil
 }
 
 // PrintRawJSON takes a byte array and prints it as a raw JSON string. The
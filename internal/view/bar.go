@@ -0,0 +1,313 @@
+// Submodule bar formats the current conditions for status bars, in the
+// protocol of each bar: Waybar, i3bar, i3blocks, Polybar and tmux.
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Status bar formats accepted by WriteBar.
+const (
+	BarText     string = "text"
+	BarWaybar   string = "waybar"
+	BarI3bar    string = "i3bar"
+	BarI3blocks string = "i3blocks"
+	BarPolybar  string = "polybar"
+	BarTmux     string = "tmux"
+)
+
+var BarFormats = []string{BarText, BarWaybar, BarI3bar, BarI3blocks, BarPolybar, BarTmux}
+
+// Marks active alerts, and errors in place of the weather.
+const warningIcon string = "⚠"
+
+// Temperature classes, from the highest °F they apply to, with the color
+// used by bars that can't be styled with CSS.
+var barClasses = []struct {
+	name  string
+	upto  float64
+	color string
+}{
+	{"freezing", 32, "#5f87ff"},
+	{"cold", 50, "#5fafff"},
+	{"mild", 68, "#87d787"},
+	{"warm", 86, "#ffd75f"},
+	{"hot", 95, "#ffaf5f"},
+	{"scorching", 999, "#ff5f5f"},
+}
+
+// Color of active alerts and errors.
+const barAlertColor string = "#ff5f5f"
+
+// struct Bar is what a status bar shows: the current conditions and the
+// active alerts. Its fields use the json schemas of the now and alerts
+// commands so that it can be cached.
+type Bar struct {
+	Conditions Conditions `json:"conditions"`
+	Alerts     []Alert    `json:"alerts"`
+}
+
+// func Short returns the icon and temperature, e.g. "☀ 78°F".
+func (b Bar) Short() string {
+	temp := b.Conditions.Temp()
+
+	if temp == "" {
+		temp = "--"
+	}
+
+	return Icon(b.Conditions.Description) + " " + temp
+}
+
+// func Events returns the distinct events of the active alerts.
+func (b Bar) Events() string {
+	return Alerts{Alerts: b.Alerts}.Summary()
+}
+
+// func Full returns the short text followed by the sky and active alerts.
+func (b Bar) Full() string {
+	s := strings.TrimSpace(b.Short() + " " + b.Conditions.Description)
+
+	if len(b.Alerts) > 0 {
+		s += " " + warningIcon + " " + b.Events()
+	}
+
+	return s
+}
+
+// func Tooltip describes the conditions over a few lines.
+func (b Bar) Tooltip() string {
+	c := b.Conditions
+	lines := []string{c.Location.Name, strings.TrimSpace(c.Temp() + " " + c.Description)}
+	temp := Unit("temperature", c.Units)
+	speed := Unit("speed", c.Units)
+
+	details := []string{}
+
+	if c.FeelsLike != nil {
+		details = append(details, fmt.Sprintf("feels like %.0f%s", *c.FeelsLike, temp))
+	}
+
+	if c.Humidity != nil {
+		details = append(details, fmt.Sprintf("humidity %.0f%%", *c.Humidity))
+	}
+
+	if c.WindSpeed != nil {
+		details = append(details, fmt.Sprintf("wind %.0f %s", *c.WindSpeed, speed))
+	}
+
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, ", "))
+	}
+
+	for _, a := range b.Alerts {
+		lines = append(lines, warningIcon+" "+a.Headline)
+	}
+
+	if c.Station != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", c.Station, c.Observed))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// func Class returns "alert" when alerts are active, and otherwise the
+// temperature class: freezing, cold, mild, warm, hot or scorching.
+func (b Bar) Class() string {
+	if len(b.Alerts) > 0 {
+		return "alert"
+	}
+
+	if class, _ := b.temperatureClass(); class != "" {
+		return class
+	}
+
+	return "unknown"
+}
+
+// func Color returns the color of the temperature class, as #rrggbb.
+func (b Bar) Color() string {
+	_, color := b.temperatureClass()
+
+	return color
+}
+
+func (b Bar) temperatureClass() (string, string) {
+	c := b.Conditions
+
+	if c.Temperature == nil {
+		return "", ""
+	}
+
+	f := *c.Temperature
+
+	if c.Units == "si" {
+		f, _ = Convert("C", "F", f)
+	}
+
+	for _, class := range barClasses {
+		if f <= class.upto {
+			return class.name, class.color
+		}
+	}
+
+	last := barClasses[len(barClasses)-1]
+
+	return last.name, last.color
+}
+
+// struct waybarOutput is the JSON read by Waybar custom modules with
+// "return-type": "json".
+type waybarOutput struct {
+	Text    string `json:"text"`
+	Alt     string `json:"alt"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+// struct i3barBlock is a block of the i3bar protocol, which i3blocks also
+// reads on its own with format=json.
+type i3barBlock struct {
+	Name      string `json:"name"`
+	Instance  string `json:"instance,omitempty"`
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text"`
+	Color     string `json:"color,omitempty"`
+	Urgent    bool   `json:"urgent"`
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+
+	return err
+}
+
+// func WriteI3barHeader starts the i3bar protocol: the header, then the
+// opening of the endless array of status lines, with an empty first line so
+// that WriteBar can prefix each of its lines with a comma.
+func WriteI3barHeader(w io.Writer) error {
+	_, err := fmt.Fprint(w, "{\"version\":1}\n[\n[]\n")
+
+	return err
+}
+
+// func writeI3barLine writes a status line of the i3bar protocol, after
+// WriteI3barHeader.
+func writeI3barLine(w io.Writer, block i3barBlock) error {
+	data, err := json.Marshal([]i3barBlock{block})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, ",%s\n", data)
+
+	return err
+}
+
+func (b Bar) i3barBlock() i3barBlock {
+	alert := ""
+
+	if len(b.Alerts) > 0 {
+		alert = " " + warningIcon
+	}
+
+	return i3barBlock{
+		Name:      "geocast",
+		Instance:  b.Conditions.Station,
+		FullText:  b.Full(),
+		ShortText: b.Short() + alert,
+		Color:     b.Color(),
+		Urgent:    len(b.Alerts) > 0,
+	}
+}
+
+func i3barError(e error) i3barBlock {
+	return i3barBlock{Name: "geocast", FullText: warningIcon + " " + e.Error(), ShortText: warningIcon, Color: barAlertColor}
+}
+
+// func WriteBar writes the bar in a status bar format. The i3bar format is a
+// single status line, to be written after WriteI3barHeader.
+func WriteBar(w io.Writer, format string, b Bar) error {
+	alert := ""
+
+	if len(b.Alerts) > 0 {
+		alert = " " + warningIcon
+	}
+
+	switch format {
+	case "", BarText:
+		_, err := fmt.Fprintln(w, b.Short()+alert)
+
+		return err
+	case BarWaybar:
+		return writeJSONLine(w, waybarOutput{b.Short() + alert, b.Class(), b.Tooltip(), b.Class()})
+	case BarI3bar:
+		return writeI3barLine(w, b.i3barBlock())
+	case BarI3blocks:
+		return writeJSONLine(w, b.i3barBlock())
+	case BarPolybar:
+		s := b.Short()
+
+		if color := b.Color(); color != "" {
+			s = fmt.Sprintf("%%{F%s}%s%%{F-}", color, s)
+		}
+
+		if alert != "" {
+			s += fmt.Sprintf(" %%{F%s}%s%%{F-}", barAlertColor, warningIcon)
+		}
+
+		_, err := fmt.Fprintln(w, s)
+
+		return err
+	case BarTmux:
+		s := b.Short()
+
+		if color := b.Color(); color != "" {
+			s = fmt.Sprintf("#[fg=%s]%s#[default]", color, s)
+		}
+
+		if alert != "" {
+			s += fmt.Sprintf(" #[fg=%s,bold]%s#[default]", barAlertColor, warningIcon)
+		}
+
+		_, err := fmt.Fprintln(w, s)
+
+		return err
+	default:
+		return fmt.Errorf("unknown bar format %q (expected %s)", format, strings.Join(BarFormats, ", "))
+	}
+}
+
+// func WriteBarError shows an error in a status bar format, so that the bar
+// keeps a placeholder (with the error as its tooltip) instead of going blank.
+func WriteBarError(w io.Writer, format string, e error) error {
+	switch format {
+	case BarWaybar:
+		return writeJSONLine(w, waybarOutput{warningIcon, "error", e.Error(), "error"})
+	case BarI3bar:
+		return writeI3barLine(w, i3barError(e))
+	case BarI3blocks:
+		return writeJSONLine(w, i3barError(e))
+	case BarPolybar:
+		_, err := fmt.Fprintf(w, "%%{F%s}%s%%{F-}\n", barAlertColor, warningIcon)
+
+		return err
+	case BarTmux:
+		_, err := fmt.Fprintf(w, "#[fg=%s]%s#[default]\n", barAlertColor, warningIcon)
+
+		return err
+	default:
+		_, err := fmt.Fprintln(w, warningIcon)
+
+		return err
+	}
+}
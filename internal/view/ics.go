@@ -0,0 +1,107 @@
+// Submodule ics converts forecasts and alerts to iCalendar events for
+// geocast export ics.
+package view
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/weather/internal/ical"
+)
+
+// func NewCalendar builds a calendar with an event for each forecast period
+// and active alert, with times in loc (UTC when nil). Periods and alerts
+// with invalid times are skipped.
+func NewCalendar(f Forecast, alerts []Alert, loc *time.Location) ical.Calendar {
+	l := f.Location
+	cal := ical.Calendar{Name: "Weather for " + l.Name, Location: loc, Events: []ical.Event{}}
+
+	for _, p := range f.Periods {
+		start, err := time.Parse(time.RFC3339, p.Start)
+
+		if err != nil {
+			continue
+		}
+
+		end, _ := time.Parse(time.RFC3339, p.End)
+
+		details := []string{p.DetailedForecast}
+
+		if p.PrecipitationChance > 0 {
+			details = append(details, fmt.Sprintf("Chance of precipitation: %d%%", p.PrecipitationChance))
+		}
+
+		if p.WindSpeed != "" {
+			details = append(details, fmt.Sprintf("Wind: %s", p.Wind()))
+		}
+
+		cal.Events = append(cal.Events, ical.Event{
+			// Periods are identified by where and when they start, so that
+			// exporting again updates the same events.
+			UID:         fmt.Sprintf("forecast-%.4f,%.4f-%s@geocast", l.Latitude, l.Longitude, start.UTC().Format("20060102T1504Z")),
+			Summary:     strings.TrimSpace(p.ShortForecast + " " + p.Temp()),
+			Description: strings.Join(details, "\n\n"),
+			Location:    l.Name,
+			Lat:         l.Latitude,
+			Lon:         l.Longitude,
+			HasGeo:      true,
+			Start:       start,
+			End:         end,
+			Categories:  []string{"Weather"},
+			Transparent: true,
+		})
+	}
+
+	for _, a := range alerts {
+		start, err := time.Parse(time.RFC3339, a.Effective)
+
+		if err != nil {
+			continue
+		}
+
+		end, _ := time.Parse(time.RFC3339, a.Expires)
+
+		details := []string{}
+
+		for _, s := range []string{a.Headline, a.Description, a.Instruction} {
+			if s = strings.TrimSpace(s); s != "" {
+				details = append(details, s)
+			}
+		}
+
+		uid := a.ID
+
+		if uid == "" {
+			uid = fmt.Sprintf("alert-%s-%s@geocast", strings.ReplaceAll(a.Event, " ", "-"), start.UTC().Format("20060102T1504Z"))
+		}
+
+		area := a.Area
+
+		if area == "" {
+			area = l.Name
+		}
+
+		categories := []string{"Weather alert"}
+
+		if a.Severity != "" {
+			categories = append(categories, a.Severity)
+		}
+
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         uid,
+			Summary:     warningIcon + " " + a.Event,
+			Description: strings.Join(details, "\n\n"),
+			Location:    area,
+			Lat:         l.Latitude,
+			Lon:         l.Longitude,
+			HasGeo:      true,
+			Start:       start,
+			End:         end,
+			Categories:  categories,
+			Transparent: true,
+		})
+	}
+
+	return cal
+}
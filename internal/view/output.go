@@ -0,0 +1,234 @@
+// Submodule output renders command results in the format selected with
+// --output: styled text for people, or json, ndjson, yaml, csv, tsv and
+// markdown for scripts.
+package view
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by NewRenderer.
+const (
+	FormatText     string = "text"
+	FormatJSON     string = "json"
+	FormatNDJSON   string = "ndjson"
+	FormatYAML     string = "yaml"
+	FormatCSV      string = "csv"
+	FormatTSV      string = "tsv"
+	FormatMarkdown string = "markdown"
+)
+
+var Formats = []string{FormatText, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV, FormatTSV, FormatMarkdown}
+
+// struct Options configures the text format.
+type Options struct {
+	// Verbosity level of forecasts and alerts, from 0 to 3.
+	Verbosity int
+	// Pause between forecast periods.
+	Delay time.Duration
+}
+
+// interface Result is implemented by the results of commands. The json, ndjson
+// and yaml formats encode the result itself, csv, tsv and markdown write its
+// columns and rows and the text format calls Text.
+type Result interface {
+	Columns() []string
+	Rows() [][]string
+	Text(w io.Writer, o Options) error
+}
+
+// interface Itemizer is implemented by results that hold a list, which the
+// ndjson format writes one item per line.
+type Itemizer interface {
+	Items() []any
+}
+
+// interface Renderer writes results in an output format.
+type Renderer interface {
+	Render(r Result) error
+}
+
+// func IsText reports whether format is the styled text format, which is
+// meant for people rather than scripts.
+func IsText(format string) bool {
+	return format == "" || format == FormatText
+}
+
+// func NewRenderer returns the Renderer for an output format.
+func NewRenderer(format string, w io.Writer, o Options) (Renderer, error) {
+	switch format {
+	case "", FormatText:
+		return textRenderer{w, o}, nil
+	case FormatJSON:
+		return jsonRenderer{w}, nil
+	case FormatNDJSON:
+		return ndjsonRenderer{w}, nil
+	case FormatYAML:
+		return yamlRenderer{w}, nil
+	case FormatCSV:
+		return delimitedRenderer{w, ','}, nil
+	case FormatTSV:
+		return delimitedRenderer{w, '\t'}, nil
+	case FormatMarkdown:
+		return markdownRenderer{w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// func SetOutput disables colors when w is not a terminal (e.g. a pipe or a
+// file), or when NO_COLOR is set.
+func SetOutput(w io.Writer) {
+	lipgloss.SetColorProfile(termenv.NewOutput(w).EnvColorProfile())
+}
+
+type textRenderer struct {
+	w io.Writer
+	o Options
+}
+
+func (t textRenderer) Render(r Result) error {
+	return r.Text(t.w, t.o)
+}
+
+type jsonRenderer struct {
+	w io.Writer
+}
+
+func (j jsonRenderer) Render(r Result) error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+type ndjsonRenderer struct {
+	w io.Writer
+}
+
+func (n ndjsonRenderer) Render(r Result) error {
+	enc := json.NewEncoder(n.w)
+
+	items, ok := r.(Itemizer)
+
+	if !ok {
+		return enc.Encode(r)
+	}
+
+	for _, item := range items.Items() {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type yamlRenderer struct {
+	w io.Writer
+}
+
+// func Render encodes the result as JSON first, so that YAML uses the same
+// field names and order as the json format.
+func (y yamlRenderer) Render(r Result) error {
+	data, err := json.Marshal(r)
+
+	if err != nil {
+		return err
+	}
+
+	node := yaml.Node{}
+
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+
+	blockStyle(&node)
+
+	enc := yaml.NewEncoder(y.w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// func blockStyle drops the JSON (flow) style from a decoded document.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+type delimitedRenderer struct {
+	w     io.Writer
+	comma rune
+}
+
+func (d delimitedRenderer) Render(r Result) error {
+	cw := csv.NewWriter(d.w)
+	cw.Comma = d.comma
+
+	if err := cw.Write(r.Columns()); err != nil {
+		return err
+	}
+
+	if err := cw.WriteAll(r.Rows()); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+type markdownRenderer struct {
+	w io.Writer
+}
+
+func (m markdownRenderer) Render(r Result) error {
+	buf := bytes.Buffer{}
+	columns := r.Columns()
+
+	row := func(cells []string) {
+		escaped := []string{}
+
+		for _, c := range cells {
+			c = strings.ReplaceAll(c, "|", `\|`)
+			c = strings.ReplaceAll(strings.TrimSpace(c), "\n", "<br>")
+			escaped = append(escaped, c)
+		}
+
+		fmt.Fprintf(&buf, "| %s |\n", strings.Join(escaped, " | "))
+	}
+
+	row(columns)
+
+	separator := []string{}
+
+	for range columns {
+		separator = append(separator, "---")
+	}
+
+	row(separator)
+
+	for _, cells := range r.Rows() {
+		row(cells)
+	}
+
+	_, err := m.w.Write(buf.Bytes())
+
+	return err
+}
@@ -0,0 +1,493 @@
+// Submodule schema defines the results written by the json, ndjson, yaml,
+// csv, tsv and markdown output formats.
+//
+// Field names are part of geocast's interface: fields may be added, but are
+// not renamed or removed. Measurements are in the units named by the
+// result's "units" field (see Unit).
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/weather/internal/ipinfo"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+// func Unit returns the unit of a measurement ("temperature", "speed",
+// "pressure" or "distance") in US customary ("us") or SI ("si") units.
+func Unit(measurement, units string) string {
+	si := units == nws.UnitsSI
+
+	switch measurement {
+	case "temperature":
+		if si {
+			return "°C"
+		}
+
+		return "°F"
+	case "speed":
+		if si {
+			return "km/h"
+		}
+
+		return "mph"
+	case "pressure":
+		if si {
+			return "hPa"
+		}
+
+		return "inHg"
+	case "distance":
+		if si {
+			return "km"
+		}
+
+		return "mi"
+	}
+
+	return ""
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// func formatValue formats an optional measurement, empty when missing.
+func formatValue(v *float64) string {
+	if v == nil {
+		return ""
+	}
+
+	return formatFloat(*v)
+}
+
+// func round rounds an optional measurement to the given number of decimal
+// places.
+func round(v *float64, places int) *float64 {
+	if v == nil {
+		return nil
+	}
+
+	scale := math.Pow(10, float64(places))
+	r := math.Round(*v*scale) / scale
+
+	return &r
+}
+
+// struct Location is the result of geocast geocode.
+type Location struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// func NewLocation converts a city to a Location.
+func NewLocation(c nws.City) Location {
+	return Location{Name: c.Name, Latitude: c.Lat, Longitude: c.Long}
+}
+
+// City converts the location back to a nws.City.
+func (l Location) City() *nws.City {
+	return &nws.City{Name: l.Name, Lat: l.Latitude, Long: l.Longitude}
+}
+
+func (l Location) Columns() []string {
+	return []string{"name", "latitude", "longitude"}
+}
+
+func (l Location) Rows() [][]string {
+	return [][]string{{l.Name, formatFloat(l.Latitude), formatFloat(l.Longitude)}}
+}
+
+func (l Location) Text(w io.Writer, o Options) error {
+	writeCityLine(w, l.City())
+
+	return nil
+}
+
+// struct Period is a forecast period, e.g. "Tonight" or "Saturday".
+type Period struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	// RFC 3339 times in the location's time zone.
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Daytime bool   `json:"daytime"`
+	// Temperature in TemperatureUnit ("F" or "C").
+	Temperature     int    `json:"temperature"`
+	TemperatureUnit string `json:"temperature_unit"`
+	// Chance of precipitation as a percentage.
+	PrecipitationChance int    `json:"precipitation_chance"`
+	WindSpeed           string `json:"wind_speed"`
+	WindDirection       string `json:"wind_direction"`
+	ShortForecast       string `json:"short_forecast"`
+	DetailedForecast    string `json:"detailed_forecast"`
+	Icon                string `json:"icon"`
+}
+
+func newPeriod(p nws.PeriodAPIResponse) Period {
+	return Period{
+		Number:              p.Number,
+		Name:                p.Label,
+		Start:               p.StartTime,
+		End:                 p.EndTime,
+		Daytime:             p.IsDaytime,
+		Temperature:         p.Temperature,
+		TemperatureUnit:     p.TemperatureUnit,
+		PrecipitationChance: p.ProbabilityOfPrecipitation.Value,
+		WindSpeed:           p.WindSpeed,
+		WindDirection:       p.WindDirection,
+		ShortForecast:       p.ShortForecast,
+		DetailedForecast:    p.DetailedForecast,
+		Icon:                p.Icon,
+	}
+}
+
+// func api converts the period back to the weather.gov type used by the
+// text format.
+func (p Period) api() nws.PeriodAPIResponse {
+	return nws.PeriodAPIResponse{
+		Number:                     p.Number,
+		Label:                      p.Name,
+		StartTime:                  p.Start,
+		EndTime:                    p.End,
+		IsDaytime:                  p.Daytime,
+		Temperature:                p.Temperature,
+		TemperatureUnit:            p.TemperatureUnit,
+		ProbabilityOfPrecipitation: nws.ProbabilityOfPrecipitation{UnitCode: "wmoUnit:percent", Value: p.PrecipitationChance},
+		WindSpeed:                  p.WindSpeed,
+		WindDirection:              p.WindDirection,
+		Icon:                       p.Icon,
+		ShortForecast:              p.ShortForecast,
+		DetailedForecast:           p.DetailedForecast,
+	}
+}
+
+// struct Forecast is the result of geocast forecast.
+type Forecast struct {
+	Location Location `json:"location"`
+	Units    string   `json:"units"`
+	Periods  []Period `json:"periods"`
+}
+
+// func NewForecast builds a Forecast from weather.gov forecast periods.
+func NewForecast(c nws.City, units string, periods []nws.PeriodAPIResponse) Forecast {
+	f := Forecast{Location: NewLocation(c), Units: units, Periods: []Period{}}
+
+	for _, p := range periods {
+		f.Periods = append(f.Periods, newPeriod(p))
+	}
+
+	return f
+}
+
+func (f Forecast) Columns() []string {
+	return []string{
+		"number", "name", "start", "end", "daytime", "temperature", "temperature_unit",
+		"precipitation_chance", "wind_speed", "wind_direction", "short_forecast", "detailed_forecast",
+	}
+}
+
+func (f Forecast) Rows() [][]string {
+	rows := [][]string{}
+
+	for _, p := range f.Periods {
+		rows = append(rows, []string{
+			strconv.Itoa(p.Number), p.Name, p.Start, p.End, strconv.FormatBool(p.Daytime),
+			strconv.Itoa(p.Temperature), p.TemperatureUnit, strconv.Itoa(p.PrecipitationChance),
+			p.WindSpeed, p.WindDirection, p.ShortForecast, p.DetailedForecast,
+		})
+	}
+
+	return rows
+}
+
+// Items returns the periods, written one per line by the ndjson format.
+func (f Forecast) Items() []any {
+	items := []any{}
+
+	for _, p := range f.Periods {
+		items = append(items, p)
+	}
+
+	return items
+}
+
+func (f Forecast) Text(w io.Writer, o Options) error {
+	writeCityLine(w, f.Location.City())
+
+	for i, p := range f.Periods {
+		if i > 0 && o.Delay > 0 {
+			time.Sleep(o.Delay)
+		}
+
+		writeForecastLine(w, p.api(), o.Verbosity)
+	}
+
+	return nil
+}
+
+// struct Alert is an active weather alert.
+type Alert struct {
+	ID       string `json:"id"`
+	Event    string `json:"event"`
+	Headline string `json:"headline"`
+	// Extreme, Severe, Moderate, Minor or Unknown.
+	Severity string `json:"severity"`
+	// Observed, Likely, Possible, Unlikely or Unknown.
+	Certainty string `json:"certainty"`
+	// Immediate, Expected, Future, Past or Unknown.
+	Urgency string `json:"urgency"`
+	Area    string `json:"area"`
+	Sender  string `json:"sender"`
+	// RFC 3339 times.
+	Effective   string `json:"effective"`
+	Expires     string `json:"expires"`
+	Description string `json:"description"`
+	Instruction string `json:"instruction"`
+}
+
+// struct Alerts is the result of geocast alerts.
+type Alerts struct {
+	Location Location `json:"location"`
+	Alerts   []Alert  `json:"alerts"`
+}
+
+// func NewAlerts builds Alerts from weather.gov alerts.
+func NewAlerts(c nws.City, alerts []nws.AlertAPIResponse) Alerts {
+	a := Alerts{Location: NewLocation(c), Alerts: []Alert{}}
+
+	for _, alert := range alerts {
+		a.Alerts = append(a.Alerts, Alert{
+			ID:          alert.ID,
+			Event:       alert.Event,
+			Headline:    alert.Headline,
+			Severity:    alert.Severity,
+			Certainty:   alert.Certainty,
+			Urgency:     alert.Urgency,
+			Area:        alert.AreaDesc,
+			Sender:      alert.SenderName,
+			Effective:   alert.Effective,
+			Expires:     alert.Expires,
+			Description: alert.Description,
+			Instruction: alert.Instruction,
+		})
+	}
+
+	return a
+}
+
+func (a Alerts) Columns() []string {
+	return []string{
+		"id", "event", "headline", "severity", "certainty", "urgency", "area",
+		"sender", "effective", "expires", "description", "instruction",
+	}
+}
+
+func (a Alerts) Rows() [][]string {
+	rows := [][]string{}
+
+	for _, alert := range a.Alerts {
+		rows = append(rows, []string{
+			alert.ID, alert.Event, alert.Headline, alert.Severity, alert.Certainty, alert.Urgency, alert.Area,
+			alert.Sender, alert.Effective, alert.Expires, alert.Description, alert.Instruction,
+		})
+	}
+
+	return rows
+}
+
+// Items returns the alerts, written one per line by the ndjson format.
+func (a Alerts) Items() []any {
+	items := []any{}
+
+	for _, alert := range a.Alerts {
+		items = append(items, alert)
+	}
+
+	return items
+}
+
+func (a Alerts) Text(w io.Writer, o Options) error {
+	writeCityLine(w, a.Location.City())
+
+	if len(a.Alerts) == 0 {
+		fmt.Fprintln(w, "No active alerts.")
+
+		return nil
+	}
+
+	for _, alert := range a.Alerts {
+		writeAlertLine(w, alert, o.Verbosity)
+	}
+
+	return nil
+}
+
+// struct Conditions is the result of geocast conditions: the latest
+// observation from the nearest reporting station. Measurements are omitted
+// (null) when the station did not report them.
+type Conditions struct {
+	Location Location `json:"location"`
+	// Station identifier (e.g. KATT) and name.
+	Station     string `json:"station"`
+	StationName string `json:"station_name"`
+	// RFC 3339 time of the observation.
+	Observed    string `json:"observed"`
+	Description string `json:"description"`
+	Units       string `json:"units"`
+	// °F or °C.
+	Temperature *float64 `json:"temperature"`
+	FeelsLike   *float64 `json:"feels_like"`
+	Dewpoint    *float64 `json:"dewpoint"`
+	// Relative humidity as a percentage.
+	Humidity *float64 `json:"humidity"`
+	// mph or km/h.
+	WindSpeed *float64 `json:"wind_speed"`
+	WindGust  *float64 `json:"wind_gust"`
+	// Degrees clockwise from north.
+	WindDirection *float64 `json:"wind_direction"`
+	// inHg or hPa.
+	Pressure *float64 `json:"pressure"`
+	// mi or km.
+	Visibility *float64 `json:"visibility"`
+}
+
+// func NewConditions builds Conditions from a weather.gov observation,
+// converted to the given units.
+func NewConditions(c nws.City, units string, obs nws.ObservationAPIResponse) Conditions {
+	p := obs.Properties
+
+	feels := p.HeatIndex
+
+	if feels.Value == nil {
+		feels = p.WindChill
+	}
+
+	if feels.Value == nil {
+		feels = p.Temperature
+	}
+
+	return Conditions{
+		Location:      NewLocation(c),
+		Station:       obs.Station.StationIdentifier,
+		StationName:   obs.Station.Name,
+		Observed:      p.Timestamp,
+		Description:   p.TextDescription,
+		Units:         units,
+		Temperature:   round(p.Temperature.Convert(units), 1),
+		FeelsLike:     round(feels.Convert(units), 1),
+		Dewpoint:      round(p.Dewpoint.Convert(units), 1),
+		Humidity:      round(p.RelativeHumidity.Convert(units), 1),
+		WindSpeed:     round(p.WindSpeed.Convert(units), 1),
+		WindGust:      round(p.WindGust.Convert(units), 1),
+		WindDirection: round(p.WindDirection.Convert(units), 1),
+		Pressure:      round(p.BarometricPressure.Convert(units), 2),
+		Visibility:    round(p.Visibility.Convert(units), 1),
+	}
+}
+
+func (c Conditions) Columns() []string {
+	return []string{
+		"station", "observed", "description", "units", "temperature", "feels_like", "dewpoint",
+		"humidity", "wind_speed", "wind_gust", "wind_direction", "pressure", "visibility",
+	}
+}
+
+func (c Conditions) Rows() [][]string {
+	return [][]string{{
+		c.Station, c.Observed, c.Description, c.Units, formatValue(c.Temperature), formatValue(c.FeelsLike),
+		formatValue(c.Dewpoint), formatValue(c.Humidity), formatValue(c.WindSpeed), formatValue(c.WindGust),
+		formatValue(c.WindDirection), formatValue(c.Pressure), formatValue(c.Visibility),
+	}}
+}
+
+func (c Conditions) Text(w io.Writer, o Options) error {
+	writeCityLine(w, c.Location.City())
+	writeConditionsLines(w, c, o.Verbosity)
+
+	return nil
+}
+
+// struct List is a generic tabular result, such as the saved places or the
+// settings. The json, ndjson and yaml formats write Values.
+type List struct {
+	Headers []string
+	Data    [][]string
+	Values  []any
+}
+
+func (l List) MarshalJSON() ([]byte, error) {
+	if l.Values == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(l.Values)
+}
+
+func (l List) Columns() []string {
+	columns := []string{}
+
+	for _, h := range l.Headers {
+		columns = append(columns, strings.ReplaceAll(strings.ToLower(h), " ", "_"))
+	}
+
+	return columns
+}
+
+func (l List) Rows() [][]string {
+	return l.Data
+}
+
+func (l List) Items() []any {
+	return l.Values
+}
+
+func (l List) Text(w io.Writer, o Options) error {
+	_, err := fmt.Fprintln(w, Table(l.Headers, l.Data).Width(0))
+
+	return err
+}
+
+// func IPList builds a List of IP geolocation results.
+func IPList(rs []ipinfo.IPInfoResponse) List {
+	l := List{Headers: []string{"IP", "Location", "Coordinates", "Network", "Privacy"}}
+
+	for _, r := range rs {
+		place := []string{}
+
+		for _, part := range []string{r.City, r.Region, r.Country} {
+			if part != "" {
+				place = append(place, part)
+			}
+		}
+
+		network := r.Organization
+
+		if r.ASN != nil {
+			network = strings.TrimSpace(r.ASN.ASN + " " + r.ASN.Name)
+		}
+
+		if r.Company != nil && r.Company.Name != "" && !strings.Contains(network, r.Company.Name) {
+			network = fmt.Sprintf("%s (%s)", network, r.Company.Name)
+		}
+
+		l.Data = append(l.Data, []string{
+			r.IP,
+			strings.Join(place, ", "),
+			r.Location,
+			network,
+			strings.Join(r.Privacy.Flags(), ", "),
+		})
+
+		l.Values = append(l.Values, r)
+	}
+
+	return l
+}
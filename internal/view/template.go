@@ -0,0 +1,433 @@
+// Submodule template renders command results with user-defined Go
+// text/template templates, given with --template or --template-file.
+//
+// Templates are executed with the result of the command, i.e. the schemas in
+// schema.go, so {{.Location.Name}} and {{(index .Periods 0).Temperature}}
+// refer to the same fields as the json format. Results also have a few
+// methods for templates:
+//
+//   - City: the location as a nws.City ({{.City.Name}}, {{.City.Lat}})
+//   - Summary: a short description, e.g. "78°F Mostly Clear"
+//   - Temp and Sky: the current temperature and sky (forecasts and conditions)
+//
+// and forecast periods have Temp, Wind and Precipitation.
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+// Built-in templates, selected by name (e.g. --template tmux).
+var Templates = map[string]string{
+	"oneline": `{{.City.Name}}: {{.Summary}}`,
+	"tmux":    `{{icon .Sky}} {{.Temp}}`,
+	"slack":   `*{{.City.Name}}* {{icon .Sky}} {{.Summary}}`,
+	"motd": `Weather for {{.City.Name}}
+{{range .Periods}}{{pad 16 .Name}} {{lpad 6 .Temp}}  {{icon .ShortForecast .Daytime}} {{.ShortForecast}}
+{{end}}`,
+}
+
+// func TemplateNames returns the names of the built-in templates.
+func TemplateNames() []string {
+	names := []string{}
+
+	for name := range Templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Named colors accepted by the color helper, as ANSI color numbers.
+var colors = map[string]string{
+	"black":   "0",
+	"red":     "1",
+	"green":   "2",
+	"yellow":  "3",
+	"blue":    "4",
+	"magenta": "5",
+	"cyan":    "6",
+	"white":   "7",
+	"gray":    "8",
+	"grey":    "8",
+}
+
+// Icons for forecast descriptions, matched in order against the lowercased
+// description.
+var icons = []struct {
+	keywords []string
+	day      string
+	night    string
+}{
+	{[]string{"thunder", "t-storm"}, "⛈", "⛈"},
+	{[]string{"snow", "flurr", "blizzard", "sleet", "ice", "freezing"}, "❄", "❄"},
+	{[]string{"shower"}, "🌦", "🌧"},
+	{[]string{"rain", "drizzle"}, "🌧", "🌧"},
+	{[]string{"fog", "haze", "smoke", "dust", "mist"}, "🌫", "🌫"},
+	{[]string{"wind", "breez", "blustery"}, "💨", "💨"},
+	{[]string{"partly", "mostly sunny", "mostly clear", "few clouds"}, "⛅", "☁"},
+	{[]string{"cloud", "overcast"}, "☁", "☁"},
+	{[]string{"sunny", "clear", "fair"}, "☀", "🌙"},
+	{[]string{"hot"}, "🔥", "🔥"},
+	{[]string{"cold"}, "🥶", "🥶"},
+}
+
+// func Icon returns an emoji for a forecast description such as "Chance
+// Showers And Thunderstorms", using night icons when daytime is false.
+func Icon(description string, daytime ...bool) string {
+	d := strings.ToLower(description)
+	night := len(daytime) > 0 && !daytime[0]
+
+	for _, icon := range icons {
+		for _, k := range icon.keywords {
+			if strings.Contains(d, k) {
+				if night {
+					return icon.night
+				}
+
+				return icon.day
+			}
+		}
+	}
+
+	return "🌡"
+}
+
+// Units accepted by the convert helper, grouped by measurement, as a factor
+// and offset to the first unit of the group.
+var conversions = map[string]struct {
+	group  string
+	factor float64
+	offset float64
+}{
+	"C":    {"temperature", 1, 0},
+	"F":    {"temperature", 5.0 / 9, -32},
+	"K":    {"temperature", 1, -273.15},
+	"km/h": {"speed", 1, 0},
+	"mph":  {"speed", 1.609344, 0},
+	"m/s":  {"speed", 3.6, 0},
+	"kn":   {"speed", 1.852, 0},
+	"hPa":  {"pressure", 1, 0},
+	"inHg": {"pressure", 33.86389, 0},
+	"Pa":   {"pressure", 0.01, 0},
+	"km":   {"distance", 1, 0},
+	"mi":   {"distance", 1.609344, 0},
+	"m":    {"distance", 0.001, 0},
+}
+
+// func Convert converts a measurement between units, e.g. Convert("F", "C",
+// 78), or {{.Temperature | convert "F" "C"}} in a template. Temperatures (C,
+// F, K), speeds (km/h, mph, m/s, kn), pressures (hPa, inHg, Pa) and distances
+// (km, mi, m) are supported.
+func Convert(from, to string, value any) (float64, error) {
+	v, err := toFloat(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	f, ok := conversions[strings.TrimPrefix(from, "°")]
+	t, ok2 := conversions[strings.TrimPrefix(to, "°")]
+
+	if !ok || !ok2 || f.group != t.group {
+		return 0, fmt.Errorf("can't convert %s to %s", from, to)
+	}
+
+	base := (v + f.offset) * f.factor
+
+	return base/t.factor - t.offset, nil
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case *float64:
+		if v == nil {
+			return 0, fmt.Errorf("missing value")
+		}
+
+		return *v, nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+}
+
+// func Relative describes a time relative to now, e.g. "in 3 hours" or "20
+// minutes ago". It accepts an RFC 3339 string or a time.Time.
+func Relative(value any) (string, error) {
+	var t time.Time
+
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case string:
+		parsed, err := time.Parse(time.RFC3339, v)
+
+		if err != nil {
+			return "", err
+		}
+
+		t = parsed
+	default:
+		return "", fmt.Errorf("expected a time, got %T", value)
+	}
+
+	d := time.Until(t)
+	future := d > 0
+	d = d.Abs()
+
+	var n int
+	var unit string
+
+	switch {
+	case d < time.Minute:
+		return "now", nil
+	case d < time.Hour:
+		n, unit = int(d.Round(time.Minute)/time.Minute), "minute"
+	case d < 48*time.Hour:
+		n, unit = int(d.Round(time.Hour)/time.Hour), "hour"
+	default:
+		n, unit = int(d.Round(24*time.Hour)/(24*time.Hour)), "day"
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit), nil
+	}
+
+	return fmt.Sprintf("%d %s ago", n, unit), nil
+}
+
+// func pad pads s with spaces to width characters, on the right or, for
+// lpad, on the left.
+func pad(left bool) func(width int, s any) string {
+	return func(width int, s any) string {
+		str := fmt.Sprint(s)
+		n := width - utf8.RuneCountInString(str)
+
+		if n <= 0 {
+			return str
+		}
+
+		if left {
+			return strings.Repeat(" ", n) + str
+		}
+
+		return str + strings.Repeat(" ", n)
+	}
+}
+
+// func truncate shortens s to at most width characters, ending with "…".
+func truncate(width int, s any) string {
+	r := []rune(fmt.Sprint(s))
+
+	if len(r) <= width || width < 1 {
+		return string(r)
+	}
+
+	return string(r[:width-1]) + "…"
+}
+
+// func color renders s in a named (e.g. "red"), ANSI (e.g. "214") or hex
+// (e.g. "#ff8700") color. Colors are dropped when the output isn't a
+// terminal.
+func color(c string, s any) string {
+	if named, ok := colors[strings.ToLower(c)]; ok {
+		c = named
+	}
+
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(c)).Render(fmt.Sprint(s))
+}
+
+func bold(s any) string {
+	return lipgloss.NewStyle().Bold(true).Render(fmt.Sprint(s))
+}
+
+// func roundNumber rounds a number to the given number of decimal places,
+// e.g. {{.Pressure | round 2}}.
+func roundNumber(places int, v any) (float64, error) {
+	f, err := toFloat(v)
+
+	if err != nil {
+		return 0, err
+	}
+
+	scale := math.Pow(10, float64(places))
+
+	return math.Round(f*scale) / scale, nil
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+
+	return string(data), err
+}
+
+// func TemplateFuncs returns the helper functions available to templates.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"convert":  Convert,
+		"round":    roundNumber,
+		"icon":     Icon,
+		"relative": Relative,
+		"pad":      pad(false),
+		"lpad":     pad(true),
+		"trunc":    truncate,
+		"color":    color,
+		"bold":     bold,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"join":     strings.Join,
+		"json":     toJSON,
+	}
+}
+
+// func NewTemplate parses a Go template with the helper functions.
+func NewTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(TemplateFuncs()).Parse(text)
+}
+
+// func ParseTemplate parses a template given on the command line: the name
+// of a built-in template, or the text of a Go template.
+func ParseTemplate(text string) (*template.Template, error) {
+	if builtin, ok := Templates[text]; ok {
+		return NewTemplate(text, builtin)
+	}
+
+	if !strings.Contains(text, "{{") {
+		return nil, fmt.Errorf("unknown template %q, expected a Go template or one of %s", text, strings.Join(TemplateNames(), ", "))
+	}
+
+	return NewTemplate("template", text)
+}
+
+type templateRenderer struct {
+	w io.Writer
+	t *template.Template
+}
+
+// func NewTemplateRenderer returns a Renderer that executes t with each
+// result, followed by a newline unless the template ends with one.
+func NewTemplateRenderer(t *template.Template, w io.Writer) Renderer {
+	return templateRenderer{w, t}
+}
+
+func (t templateRenderer) Render(r Result) error {
+	var b strings.Builder
+
+	if err := t.t.Execute(&b, r); err != nil {
+		return err
+	}
+
+	out := b.String()
+
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+
+	_, err := io.WriteString(t.w, out)
+
+	return err
+}
+
+// Methods for templates.
+
+func (p Period) Temp() string {
+	return p.api().Temp()
+}
+
+func (p Period) Wind() string {
+	return p.api().Wind()
+}
+
+func (p Period) Precipitation() string {
+	return p.api().Precipitation()
+}
+
+func (l Location) Summary() string {
+	return fmt.Sprintf("%s, %s", formatFloat(l.Latitude), formatFloat(l.Longitude))
+}
+
+func (f Forecast) City() *nws.City {
+	return f.Location.City()
+}
+
+func (f Forecast) Temp() string {
+	if len(f.Periods) == 0 {
+		return ""
+	}
+
+	return f.Periods[0].Temp()
+}
+
+func (f Forecast) Sky() string {
+	if len(f.Periods) == 0 {
+		return ""
+	}
+
+	return f.Periods[0].ShortForecast
+}
+
+func (f Forecast) Summary() string {
+	return strings.TrimSpace(f.Temp() + " " + f.Sky())
+}
+
+func (c Conditions) City() *nws.City {
+	return c.Location.City()
+}
+
+func (c Conditions) Temp() string {
+	if c.Temperature == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%.0f%s", *c.Temperature, Unit("temperature", c.Units))
+}
+
+func (c Conditions) Sky() string {
+	return c.Description
+}
+
+func (c Conditions) Summary() string {
+	return strings.TrimSpace(c.Temp() + " " + c.Sky())
+}
+
+func (a Alerts) City() *nws.City {
+	return a.Location.City()
+}
+
+func (a Alerts) Summary() string {
+	events := []string{}
+
+	for _, alert := range a.Alerts {
+		if !slices.Contains(events, alert.Event) {
+			events = append(events, alert.Event)
+		}
+	}
+
+	if len(events) == 0 {
+		return "No active alerts"
+	}
+
+	return strings.Join(events, ", ")
+}
@@ -2,16 +2,36 @@ package view
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+	"github.com/desertthunder/weather/internal/ipinfo"
 	"github.com/desertthunder/weather/internal/nws"
+	"github.com/muesli/termenv"
 )
 
+// Color themes accepted by SetTheme.
+var Themes = []string{"default", "mono"}
+
+// func SetTheme selects the color theme: "default", or "mono" to print
+// without colors.
+func SetTheme(name string) error {
+	switch name {
+	case "", "default":
+		return nil
+	case "mono":
+		lipgloss.SetColorProfile(termenv.Ascii)
+
+		return nil
+	default:
+		return fmt.Errorf("unknown theme %q (expected %s)", name, strings.Join(Themes, " or "))
+	}
+}
+
 func Table(headers []string, data [][]string) *table.Table {
-	re := lipgloss.NewRenderer(os.Stdout)
 	baseStyle := lipgloss.NewStyle().Padding(0, 1)
 	headerStyle := baseStyle.Foreground(lipgloss.Color("#005fd7")).Bold(true)
 	oddStyle := baseStyle.Foreground(lipgloss.Color("252"))
@@ -19,7 +39,7 @@ func Table(headers []string, data [][]string) *table.Table {
 
 	t := table.New().
 		Border(lipgloss.RoundedBorder()).
-		BorderStyle(re.NewStyle().Foreground(lipgloss.Color("238"))).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("238"))).
 		Headers(headers...).
 		Width(48).
 		Rows(data...).
@@ -47,6 +67,9 @@ type styles struct {
 	Day           *lipgloss.Style
 	Night         *lipgloss.Style
 	City          *lipgloss.Style
+	Now           *lipgloss.Style
+	Alert         *lipgloss.Style
+	Warning       *lipgloss.Style
 }
 
 func Styles() *styles {
@@ -88,10 +111,30 @@ func Styles() *styles {
 		Padding(0, 1, 0, 1).
 		Background(lipgloss.Color("86")). // Red
 		Foreground(lipgloss.Color("0"))
-	return &styles{&today, &tonight, &overnight, &tomorrow, &tomorrowNight, &day, &night, &city}
+
+	now := lipgloss.NewStyle().
+		Padding(0, 1, 0, 1).
+		Background(lipgloss.Color("42")). // Green
+		Foreground(lipgloss.Color("0"))
+
+	alert := lipgloss.NewStyle().
+		Padding(0, 1, 0, 1).
+		Background(lipgloss.Color("196")). // Bright Red
+		Foreground(lipgloss.Color("15"))
+
+	warning := lipgloss.NewStyle().
+		Padding(0, 1, 0, 1).
+		Background(lipgloss.Color("214")). // Orange
+		Foreground(lipgloss.Color("0"))
+
+	return &styles{&today, &tonight, &overnight, &tomorrow, &tomorrowNight, &day, &night, &city, &now, &alert, &warning}
 }
 
 func ForecastLine(p nws.PeriodAPIResponse, v int) {
+	writeForecastLine(os.Stdout, p, v)
+}
+
+func writeForecastLine(w io.Writer, p nws.PeriodAPIResponse, v int) {
 	// We have essentially three day categories: today, tomorrow, and
 	// after tomorrow. We can use the start & end times to determine
 	styles := Styles()
@@ -120,23 +163,97 @@ func ForecastLine(p nws.PeriodAPIResponse, v int) {
 
 	switch v {
 	case 0, 1:
-		fmt.Printf("%s %s\n", tag, p.Temp())
+		fmt.Fprintf(w, "%s %s\n", tag, p.Temp())
 	case 2:
-		fmt.Printf("%s %s %s\n", tag, p.Temp(), p.ShortForecast)
+		fmt.Fprintf(w, "%s %s %s\n", tag, p.Temp(), p.ShortForecast)
 	case 3:
-		fmt.Printf("%s %s\n", tag, p.Temp())
+		fmt.Fprintf(w, "%s %s\n", tag, p.Temp())
 		values := strings.Split(p.DetailedForecast, ". ")
 
 		for _, v := range values {
-			fmt.Println(v)
+			fmt.Fprintln(w, v)
 		}
 	default:
-		fmt.Printf("%s %s\n", tag, p.Temp())
+		fmt.Fprintf(w, "%s %s\n", tag, p.Temp())
 	}
 }
 
 func CityLine(c *nws.City) {
+	writeCityLine(os.Stdout, c)
+}
+
+func writeCityLine(w io.Writer, c *nws.City) {
 	tag := Styles().City.Render("CITY")
 
-	fmt.Printf("%s %s\n", tag, c.Fmt())
+	fmt.Fprintf(w, "%s %s\n", tag, c.Fmt())
+}
+
+// func writeAlertLine prints an alert's event and headline, followed by its
+// description and instructions at verbosity 3.
+func writeAlertLine(w io.Writer, a Alert, v int) {
+	style := Styles().Warning
+
+	if a.Severity == "Extreme" || a.Severity == "Severe" {
+		style = Styles().Alert
+	}
+
+	fmt.Fprintf(w, "%s %s\n", style.Render(strings.ToUpper(a.Event)), a.Headline)
+
+	if v < 3 {
+		return
+	}
+
+	for _, text := range []string{a.Description, a.Instruction} {
+		if text != "" {
+			fmt.Fprintln(w, strings.TrimSpace(text))
+		}
+	}
+}
+
+// func writeConditionsLines prints the temperature and sky, followed by the
+// other measurements at verbosity 2 and above.
+func writeConditionsLines(w io.Writer, c Conditions, v int) {
+	tag := Styles().Now.Render("NOW")
+	temp := Unit("temperature", c.Units)
+
+	if c.Temperature == nil {
+		fmt.Fprintf(w, "%s %s\n", tag, c.Description)
+	} else {
+		fmt.Fprintf(w, "%s %.0f%s %s\n", tag, *c.Temperature, temp, c.Description)
+	}
+
+	if v < 2 {
+		return
+	}
+
+	lines := []struct {
+		label  string
+		value  *float64
+		format string
+	}{
+		{"Feels like", c.FeelsLike, "%.0f" + temp},
+		{"Dewpoint", c.Dewpoint, "%.0f" + temp},
+		{"Humidity", c.Humidity, "%.0f%%"},
+		{"Wind", c.WindSpeed, "%.0f " + Unit("speed", c.Units)},
+		{"Gusts", c.WindGust, "%.0f " + Unit("speed", c.Units)},
+		{"Pressure", c.Pressure, "%.2f " + Unit("pressure", c.Units)},
+		{"Visibility", c.Visibility, "%.1f " + Unit("distance", c.Units)},
+	}
+
+	for _, l := range lines {
+		if l.value != nil {
+			fmt.Fprintf(w, "%s: %s\n", l.label, fmt.Sprintf(l.format, *l.value))
+		}
+	}
+
+	if c.Station != "" {
+		fmt.Fprintf(w, "Observed at %s (%s) %s\n", c.Station, c.StationName, c.Observed)
+	}
+}
+
+// func IPTable renders IP geolocation results as a table.
+func IPTable(rs []ipinfo.IPInfoResponse) *table.Table {
+	l := IPList(rs)
+
+	return Table(l.Headers, l.Data).Width(0)
 }
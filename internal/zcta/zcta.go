@@ -0,0 +1,172 @@
+// Package zcta resolves US ZIP codes to the centroid of their ZIP Code
+// Tabulation Area (ZCTA).
+//
+// The data file (data/zcta.tsv.gz) is gzipped and tab separated, with the
+// columns:
+//
+//	zcta	latitude	longitude
+//
+// The bundled file is a small sample of about 50 ZIP codes in the larger US
+// cities; other ZIP codes aren't found. `make zcta` replaces it with the
+// Census Bureau's 2020 ZCTA gazetteer file (every ZCTA), in the same format.
+package zcta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/desertthunder/weather/internal/gazetteer"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+//go:embed data/zcta.tsv.gz
+var bundled []byte
+
+var (
+	once     sync.Once
+	instance *Table
+	loadErr  error
+)
+
+// ErrInvalid is returned for strings that are not a 5 digit ZIP or ZIP+4.
+var ErrInvalid = errors.New("invalid ZIP code (expected 5 digits, e.g. 78701)")
+
+// struct Centroid is the interior point of a single ZCTA.
+type Centroid struct {
+	ZIP string
+	Lat float64
+	Lon float64
+}
+
+// City converts the centroid to a City object, named after the nearest
+// place in the offline gazetteer when one is available.
+func (c Centroid) City() nws.City {
+	name := c.ZIP
+
+	if g, err := gazetteer.Default(); err == nil {
+		if p, _, err := g.Nearest(c.Lat, c.Lon); err == nil {
+			name = fmt.Sprintf("%s, %s", c.ZIP, p.Label())
+		}
+	}
+
+	return nws.City{Name: name, Lat: c.Lat, Long: c.Lon}
+}
+
+// struct Table is an in-memory ZCTA centroid table.
+type Table struct {
+	centroids map[string]Centroid
+}
+
+// func Default returns the table built from the bundled data file.
+func Default() (*Table, error) {
+	once.Do(func() {
+		var r *gzip.Reader
+
+		r, loadErr = gzip.NewReader(bytes.NewReader(bundled))
+
+		if loadErr != nil {
+			return
+		}
+
+		defer r.Close()
+
+		instance, loadErr = New(r)
+	})
+
+	return instance, loadErr
+}
+
+// func New reads tab separated centroid records from r.
+func New(r io.Reader) (*Table, error) {
+	t := &Table{centroids: map[string]Centroid{}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("zcta line %d: expected 3 fields", line)
+		}
+
+		lat, err := strconv.ParseFloat(fields[1], 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("zcta line %d: %w", line, err)
+		}
+
+		lon, err := strconv.ParseFloat(fields[2], 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("zcta line %d: %w", line, err)
+		}
+
+		t.centroids[fields[0]] = Centroid{ZIP: fields[0], Lat: lat, Lon: lon}
+	}
+
+	return t, scanner.Err()
+}
+
+// Len returns the number of ZCTAs in the table.
+func (t *Table) Len() int {
+	return len(t.centroids)
+}
+
+// func Lookup returns the centroid for a ZIP code (ZIP+4 is accepted).
+func (t *Table) Lookup(zip string) (Centroid, bool) {
+	code, err := Normalize(zip)
+
+	if err != nil {
+		return Centroid{}, false
+	}
+
+	c, ok := t.centroids[code]
+
+	return c, ok
+}
+
+// func Normalize validates a ZIP or ZIP+4 code and returns the 5 digit ZIP.
+func Normalize(zip string) (string, error) {
+	zip = strings.TrimSpace(zip)
+
+	if len(zip) == 10 && zip[5] == '-' && digits(zip[6:]) {
+		zip = zip[:5]
+	}
+
+	if len(zip) != 5 || !digits(zip) {
+		return "", ErrInvalid
+	}
+
+	return zip, nil
+}
+
+// func IsZIP reports whether s looks like a ZIP or ZIP+4 code.
+func IsZIP(s string) bool {
+	_, err := Normalize(s)
+
+	return err == nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
@@ -0,0 +1,305 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/cmd/cli"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// struct i3barBlock is the part of an i3bar block checked by the tests.
+type i3barBlock struct {
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text"`
+	Color     string `json:"color"`
+	Urgent    bool   `json:"urgent"`
+}
+
+// func parseI3bar checks the i3bar protocol header and returns the status
+// lines written so far, closing the endless array to decode them.
+func parseI3bar(t *testing.T, out string) [][]i3barBlock {
+	t.Helper()
+
+	header, body, _ := strings.Cut(out, "\n")
+
+	if header != `{"version":1}` {
+		t.Fatalf("Expected the i3bar header, got %q", out)
+	}
+
+	lines := [][]i3barBlock{}
+
+	if err := json.Unmarshal([]byte(body+"]"), &lines); err != nil {
+		t.Fatalf("Expected a JSON array of status lines, got %q: %s", body, err.Error())
+	}
+
+	return lines
+}
+
+func testBar(t *testing.T) view.Bar {
+	client := weatherClient(t)
+	obs, err := client.GetConditions(nws.Austin())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	alerts, err := client.GetAlerts(nws.Austin())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return view.Bar{
+		Conditions: view.NewConditions(nws.Austin(), nws.UnitsUS, *obs),
+		Alerts:     view.NewAlerts(nws.Austin(), alerts.Alerts()).Alerts,
+	}
+}
+
+func TestBarFormats(t *testing.T) {
+	b := testBar(t)
+	calm := b
+	calm.Alerts = nil
+
+	tests := []struct {
+		format string
+		bar    view.Bar
+		want   string
+	}{
+		{view.BarText, b, "⛅ 95°F ⚠\n"},
+		{view.BarText, calm, "⛅ 95°F\n"},
+		{view.BarPolybar, b, "%{F#ffaf5f}⛅ 95°F%{F-} %{F#ff5f5f}⚠%{F-}\n"},
+		{view.BarTmux, b, "#[fg=#ffaf5f]⛅ 95°F#[default] #[fg=#ff5f5f,bold]⚠#[default]\n"},
+		{view.BarTmux, calm, "#[fg=#ffaf5f]⛅ 95°F#[default]\n"},
+	}
+
+	for _, tt := range tests {
+		buf := bytes.Buffer{}
+
+		if err := view.WriteBar(&buf, tt.format, tt.bar); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if buf.String() != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.want, buf.String())
+		}
+	}
+
+	t.Run("Waybar", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		view.WriteBar(&buf, view.BarWaybar, b)
+
+		out := map[string]string{}
+
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Expected JSON, got %q", buf.String())
+		}
+
+		if out["text"] != "⛅ 95°F ⚠" || out["class"] != "alert" {
+			t.Errorf("Unexpected output %v", out)
+		}
+
+		if !strings.Contains(out["tooltip"], "Austin\n95°F Partly Cloudy\n") || !strings.Contains(out["tooltip"], "Heat Advisory") {
+			t.Errorf("Unexpected tooltip %q", out["tooltip"])
+		}
+
+		buf.Reset()
+		view.WriteBar(&buf, view.BarWaybar, calm)
+		json.Unmarshal(buf.Bytes(), &out)
+
+		if out["class"] != "hot" {
+			t.Errorf("Expected the temperature class, got %q", out["class"])
+		}
+	})
+
+	t.Run("i3blocks", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		view.WriteBar(&buf, view.BarI3blocks, b)
+
+		out := i3barBlock{}
+
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Expected JSON, got %q", buf.String())
+		}
+
+		if out.FullText != "⛅ 95°F Partly Cloudy ⚠ Heat Advisory" || out.ShortText != "⛅ 95°F ⚠" || out.Color != "#ffaf5f" || !out.Urgent {
+			t.Errorf("Unexpected block %+v", out)
+		}
+	})
+
+	t.Run("i3bar", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		view.WriteI3barHeader(&buf)
+		view.WriteBar(&buf, view.BarI3bar, b)
+		view.WriteBarError(&buf, view.BarI3bar, errors.New("offline"))
+
+		lines := parseI3bar(t, buf.String())
+
+		if len(lines) != 3 || len(lines[1]) != 1 || len(lines[2]) != 1 {
+			t.Fatalf("Expected an empty line and two status lines, got %+v", lines)
+		}
+
+		if lines[1][0].FullText != "⛅ 95°F Partly Cloudy ⚠ Heat Advisory" || !lines[1][0].Urgent {
+			t.Errorf("Unexpected block %+v", lines[1][0])
+		}
+
+		if lines[2][0].FullText != "⚠ offline" || lines[2][0].Color != "#ff5f5f" {
+			t.Errorf("Unexpected error block %+v", lines[2][0])
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if err := view.WriteBar(&bytes.Buffer{}, "dwm", b); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
+
+func TestBarCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("FORECAST_TTL", "")
+
+	// Counts the /points requests that start each fetch of the weather.
+	var fetches atomic.Int32
+
+	upstream, _ := url.Parse(nwsServer(t).URL)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/points/") {
+			fetches.Add(1)
+		}
+
+		proxy.ServeHTTP(w, r)
+	}))
+
+	t.Setenv("NWS_URL", server.URL)
+
+	waybar := func(t *testing.T, args ...string) map[string]string {
+		out, err := runGeocast(t, append([]string{"--geocoder", "offline", "bar", "--format", "waybar", "--zip", "78701"}, args...)...)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		result := map[string]string{}
+
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &result); err != nil {
+			t.Fatalf("Expected JSON, got %q", out)
+		}
+
+		return result
+	}
+
+	t.Run("Fetches once", func(t *testing.T) {
+		for range 3 {
+			if out := waybar(t); out["text"] != "⛅ 95°F ⚠" {
+				t.Errorf("Unexpected output %v", out)
+			}
+		}
+
+		if n := fetches.Load(); n != 1 {
+			t.Errorf("Expected 1 fetch, got %d", n)
+		}
+
+		waybar(t, "--refresh")
+
+		if n := fetches.Load(); n != 2 {
+			t.Errorf("Expected --refresh to fetch again, got %d fetches", n)
+		}
+	})
+
+	t.Run("Geocode cache", func(t *testing.T) {
+		store := cache.New(os.Getenv("CACHE_DIR"))
+		austin := nws.Austin()
+
+		// Atlantis isn't in the gazetteer, so the bar only finds it in the
+		// cache, keyed by the location as given.
+		if err := store.Put("geocode-city-atlantis", "offline", &austin); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		out, err := runGeocast(t, "--geocoder", "offline", "bar", "--format", "text", "--city", "Atlantis")
+
+		if err != nil || !strings.Contains(out, "95°F") {
+			t.Errorf("Expected the cached city, got %q (%v)", out, err)
+		}
+
+		runGeocast(t, "--geocoder", "offline", "bar", "--city", "Austin")
+
+		city := nws.City{}
+
+		if _, err := store.Get("geocode-city-austin", &city); err != nil || city.Name == "" {
+			t.Errorf("Expected Austin to be cached, got %+v (%v)", city, err)
+		}
+	})
+
+	t.Run("i3bar", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		app := cli.Application()
+		app.Writer = &buf
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+
+		go func() {
+			errs <- app.RunContext(ctx, []string{"geocast", "--geocoder", "offline", "bar", "--format", "i3bar", "--interval", "10ms", "--zip", "78701"})
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+
+		if err := <-errs; err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		lines := parseI3bar(t, buf.String())
+
+		if len(lines) < 3 {
+			t.Fatalf("Expected several status lines, got %+v", lines)
+		}
+
+		if block := lines[len(lines)-1]; len(block) != 1 || block[0].ShortText != "⛅ 95°F ⚠" {
+			t.Errorf("Unexpected status line %+v", block)
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		server.Close()
+		t.Setenv("FORECAST_TTL", "1ns")
+
+		if out := waybar(t); out["text"] != "⛅ 95°F ⚠" {
+			t.Errorf("Expected the expired weather when weather.gov is down, got %v", out)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Setenv("CACHE_DIR", t.TempDir())
+
+		if out := waybar(t); out["class"] != "error" || out["text"] != "⚠" {
+			t.Errorf("Expected an error placeholder, got %v", out)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, err := runGeocast(t, "bar", "--format", "dwm"); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
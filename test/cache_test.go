@@ -0,0 +1,197 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/ipinfo"
+	"github.com/desertthunder/weather/internal/network"
+)
+
+// countingGeolocator returns a fixed response and counts its lookups.
+type countingGeolocator struct {
+	calls int
+	err   error
+}
+
+func (g *countingGeolocator) Geolocate(ipaddr *string) (ipinfo.IPInfoResponse, error) {
+	g.calls++
+
+	if g.err != nil {
+		return ipinfo.IPInfoResponse{}, g.err
+	}
+
+	return ipinfo.IPInfoResponse{IP: "8.8.8.8", City: "Austin", Location: "30.2672,-97.7431"}, nil
+}
+
+func TestStore(t *testing.T) {
+	store := cache.New(t.TempDir())
+
+	t.Run("Miss", func(t *testing.T) {
+		v := map[string]string{}
+
+		if _, err := store.Get("missing", &v); !errors.Is(err, cache.ErrMiss) {
+			t.Errorf("Expected ErrMiss, got %v", err)
+		}
+	})
+
+	t.Run("Put and Get", func(t *testing.T) {
+		if err := store.Put("a/b", "tag", map[string]string{"k": "v"}); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		v := map[string]string{}
+		meta, err := store.Get("a/b", &v)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if v["k"] != "v" || meta.Tag != "tag" {
+			t.Errorf("Unexpected value %v (%+v)", v, meta)
+		}
+
+		if !meta.Fresh(time.Minute, "tag") || meta.Fresh(time.Minute, "other") || meta.Fresh(0, "tag") {
+			t.Errorf("Unexpected freshness for %+v", meta)
+		}
+
+		if strings.Contains(store.Path("a/b"), "a/b") {
+			t.Errorf("Expected the key to be sanitized, got %s", store.Path("a/b"))
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		os.WriteFile(store.Path("corrupt"), []byte("{"), 0o600)
+
+		v := map[string]string{}
+
+		if _, err := store.Get("corrupt", &v); !errors.Is(err, cache.ErrMiss) {
+			t.Errorf("Expected ErrMiss, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := store.Delete("a/b"); err != nil {
+			t.Errorf("Expected no error, got %s", err.Error())
+		}
+
+		if err := store.Delete("a/b"); err != nil {
+			t.Errorf("Expected deleting a missing key to succeed, got %s", err.Error())
+		}
+	})
+}
+
+func TestCachedGeolocator(t *testing.T) {
+	fingerprint := "office"
+	inner := &countingGeolocator{}
+
+	c := ipinfo.NewCachedGeolocator(inner, cache.New(t.TempDir()), "location", time.Hour)
+	c.Fingerprint = func() (string, error) { return fingerprint, nil }
+
+	locate := func() {
+		t.Helper()
+
+		if _, err := c.Geolocate(nil); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+	}
+
+	locate()
+	locate()
+
+	if inner.calls != 1 {
+		t.Errorf("Expected the second lookup to be cached, got %d calls", inner.calls)
+	}
+
+	fingerprint = "home"
+	locate()
+
+	if inner.calls != 2 {
+		t.Errorf("Expected a network change to invalidate the cache, got %d calls", inner.calls)
+	}
+
+	c.Refresh = true
+	locate()
+
+	if inner.calls != 3 {
+		t.Errorf("Expected --refresh to skip the cache, got %d calls", inner.calls)
+	}
+
+	c.Refresh = false
+	c.TTL = 0
+	locate()
+
+	if inner.calls != 4 {
+		t.Errorf("Expected an expired entry to be refreshed, got %d calls", inner.calls)
+	}
+
+	ip := "8.8.8.8"
+	c.TTL = time.Hour
+	c.Geolocate(&ip)
+	c.Geolocate(&ip)
+
+	if inner.calls != 6 {
+		t.Errorf("Expected explicit IP lookups to bypass the cache, got %d calls", inner.calls)
+	}
+
+	inner.err = errors.New("offline")
+	fingerprint = "cafe"
+
+	if _, err := c.Geolocate(nil); err == nil {
+		t.Errorf("Expected the provider error to be returned")
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	t.Run("ParseRoutes", func(t *testing.T) {
+		table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+wlan0	00000000	FE01000A	0003	0	0	600	00000000	0	0	0
+`
+
+		routes, err := network.ParseRoutes(strings.NewReader(table))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if len(routes) != 2 {
+			t.Fatalf("Expected 2 default routes, got %v", routes)
+		}
+
+		if routes[0].Interface != "eth0" || routes[0].Gateway.String() != "192.168.1.1" {
+			t.Errorf("Unexpected route %s", routes[0])
+		}
+
+		if routes[1].Gateway.String() != "10.0.1.254" {
+			t.Errorf("Unexpected route %s", routes[1])
+		}
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		if network.Hash([]string{"a", "b"}) != network.Hash([]string{"b", "a"}) {
+			t.Errorf("Expected the hash to be order independent")
+		}
+
+		if network.Hash([]string{"a"}) == network.Hash([]string{"b"}) {
+			t.Errorf("Expected different inputs to hash differently")
+		}
+	})
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		a, err := network.Fingerprint()
+
+		if err != nil {
+			t.Skipf("Interfaces unavailable: %s", err.Error())
+		}
+
+		if b, _ := network.Fingerprint(); a != b {
+			t.Errorf("Expected a stable fingerprint, got %s and %s", a, b)
+		}
+	})
+}
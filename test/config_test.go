@@ -0,0 +1,265 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/cmd/cli"
+)
+
+const configTOML = `
+location = "Seattle, WA"
+geocoder = "offline"
+
+[profiles.work]
+location = "Boston, MA"
+
+[profiles.zip]
+location = "78701"
+`
+
+// runGeocast runs the application with the given arguments and returns its
+// output.
+func runGeocast(t *testing.T, args ...string) (string, error) {
+	var err error
+
+	out := CaptureOutput(func() {
+		err = cli.Application().Run(append([]string{"geocast"}, args...))
+	})
+
+	return out, err
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_PROFILE", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+
+	t.Run("Path", func(t *testing.T) {
+		want := filepath.Join(dir, "geocast", "config.toml")
+
+		if got := cli.ConfigPath(); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+
+		t.Setenv("GEOCAST_CONFIG", "/etc/geocast.toml")
+
+		if got := cli.ConfigPath(); got != "/etc/geocast.toml" {
+			t.Errorf("Expected GEOCAST_CONFIG to override the path, got %s", got)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "--city", "Austin", "geocode")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if strings.Contains(out, "config") || !strings.Contains(out, "Austin") {
+			t.Errorf("Expected no config errors, got %s", out)
+		}
+	})
+
+	os.MkdirAll(filepath.Join(dir, "geocast"), 0o755)
+	os.WriteFile(filepath.Join(dir, "geocast", "config.toml"), []byte(configTOML), 0o644)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"File", nil, []string{"geocode"}, "Seattle"},
+		{"Profile", nil, []string{"--profile", "work", "geocode"}, "Boston"},
+		{"Profile from env", map[string]string{"GEOCAST_PROFILE": "zip"}, []string{"geocode"}, "78701"},
+		{"Env over file", map[string]string{"GEOCAST_LOCATION": "Cleveland, OH"}, []string{"--profile", "work", "geocode"}, "Cleveland"},
+		{"Flag over env", map[string]string{"GEOCAST_LOCATION": "Cleveland, OH"}, []string{"--city", "Hartford", "geocode"}, "Hartford"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			out, err := runGeocast(t, tt.args...)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("Expected %s in output %s", tt.want, out)
+			}
+		})
+	}
+
+	t.Run("Unknown profile", func(t *testing.T) {
+		if _, err := runGeocast(t, "--profile", "home", "geocode"); err == nil {
+			t.Errorf("Expected an error for an undefined profile")
+		}
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		os.WriteFile(filepath.Join(dir, "geocast", "config.toml"), []byte("location = "), 0o644)
+
+		out, err := runGeocast(t, "--geocoder", "offline", "--city", "Austin", "geocode")
+
+		if err != nil {
+			t.Fatalf("Expected the invalid file to be ignored, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "ignoring invalid config file") {
+			t.Errorf("Expected a warning, got %s", out)
+		}
+	})
+}
+
+func TestConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geocast", "config.toml")
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_PROFILE", "")
+	t.Setenv("GEOCAST_UNITS", "")
+	t.Setenv("GEOCAST_THEME", "")
+
+	t.Run("Path", func(t *testing.T) {
+		out, _ := runGeocast(t, "config", "path")
+
+		if strings.TrimSpace(out) != path {
+			t.Errorf("Expected %s, got %s", path, out)
+		}
+	})
+
+	t.Run("Set and get", func(t *testing.T) {
+		if _, err := runGeocast(t, "config", "set", "units", "si"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if _, err := runGeocast(t, "config", "set", "--profile", "work", "location", "Boston, MA"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		data, _ := os.ReadFile(path)
+
+		if !strings.Contains(string(data), "units = 'si'") || !strings.Contains(string(data), "[profiles.work]") {
+			t.Errorf("Expected the settings to be saved, got %s", data)
+		}
+
+		if out, _ := runGeocast(t, "config", "get", "units"); strings.TrimSpace(out) != "si" {
+			t.Errorf("Expected si, got %s", out)
+		}
+
+		if out, _ := runGeocast(t, "--profile", "work", "config", "get", "location"); strings.TrimSpace(out) != "Boston, MA" {
+			t.Errorf("Expected the profile's location, got %s", out)
+		}
+	})
+
+	t.Run("Secrets", func(t *testing.T) {
+		runGeocast(t, "config", "set", "ipinfo_token", "s3cr3t-token")
+
+		if out, _ := runGeocast(t, "config", "get", "ipinfo_token"); strings.Contains(out, "s3cr3t-token") {
+			t.Errorf("Expected the token to be hidden, got %s", out)
+		}
+
+		if out, _ := runGeocast(t, "config", "get", "--reveal", "ipinfo_token"); strings.TrimSpace(out) != "s3cr3t-token" {
+			t.Errorf("Expected --reveal to show the token, got %s", out)
+		}
+	})
+
+	t.Run("Sources", func(t *testing.T) {
+		t.Setenv("GEOCAST_THEME", "mono")
+
+		out, err := runGeocast(t, "--verbosity", "3", "config", "list")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		for _, want := range []string{"geocoder", "units", "theme", "verbosity"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in %s", want, out)
+			}
+		}
+
+		sources := map[string]string{}
+
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(strings.Trim(line, "│ "))
+
+			if len(fields) >= 3 {
+				sources[fields[0]] = fields[len(fields)-1]
+			}
+		}
+
+		want := map[string]string{"geocoder": "default", "units": "file", "theme": "env", "verbosity": "flag"}
+
+		for key, source := range want {
+			if sources[key] != source {
+				t.Errorf("Expected %s to come from %s, got %s", key, source, sources[key])
+			}
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"units", "metric"},
+			{"location", "91.5,10"},
+			{"ipinfo_url", "ipinfo.io"},
+			{"verbosity", "9"},
+			{"ip_provider", "ipinfo,maxmind"},
+		} {
+			if _, err := runGeocast(t, append([]string{"config", "set"}, args...)...); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		}
+
+		_, err := runGeocast(t, "config", "set", "unts", "si")
+
+		if err == nil || !strings.Contains(err.Error(), "did you mean units") {
+			t.Errorf("Expected a suggestion, got %v", err)
+		}
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		if _, err := runGeocast(t, "config", "unset", "units"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if out, _ := runGeocast(t, "config", "get", "units"); strings.TrimSpace(out) != "us" {
+			t.Errorf("Expected the default, got %s", out)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		if out, err := runGeocast(t, "config", "validate"); err != nil {
+			t.Fatalf("Expected a valid configuration, got %s", out)
+		}
+
+		t.Setenv("GEOCAST_UNITS", "metric")
+
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		f.WriteString("colour = 'red'\n")
+		f.Close()
+
+		out, err := runGeocast(t, "config", "validate")
+
+		if err == nil {
+			t.Fatalf("Expected an error")
+		}
+
+		for _, want := range []string{"units (from env)", `unknown setting "colour"`, `profile work`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in %s", want, out)
+			}
+		}
+	})
+}
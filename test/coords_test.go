@@ -0,0 +1,85 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/coords"
+)
+
+type coordsTest struct {
+	name  string
+	input string
+	lat   float64
+	lon   float64
+	// Allowed error in degrees.
+	tolerance float64
+}
+
+func TestCoords(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		tests := []coordsTest{
+			{"Decimal", "30.2672,-97.7431", 30.2672, -97.7431, 1e-9},
+			{"Decimal with spaces", " 30.2672 -97.7431 ", 30.2672, -97.7431, 1e-9},
+			{"Decimal with suffixes", "30.2672N 97.7431W", 30.2672, -97.7431, 1e-9},
+			{"Decimal with prefixes", "S 33.8688, E 151.2093", -33.8688, 151.2093, 1e-9},
+			{"Decimal longitude first", "97.7431W, 30.2672N", 30.2672, -97.7431, 1e-9},
+			{"Decimal with degree signs", "30.2672° N, 97.7431° W", 30.2672, -97.7431, 1e-9},
+			{"DMS", `30°16'2"N 97°44'35"W`, 30.26722, -97.74306, 1e-4},
+			{"DMS with spaces", "30 16 2 N 97 44 35 W", 30.26722, -97.74306, 1e-4},
+			{"DMS without hemispheres", "30 16 2 -97 44 35", 30.26722, -97.74306, 1e-4},
+			{"Degrees and decimal minutes", "30 16.033 N, 97 44.586 W", 30.26722, -97.7431, 1e-4},
+			{"Plus code", "849VCWC8+R9", 37.4220625, -122.0840625, 1e-7},
+			{"Padded plus code", "84000000+", 40, -130, 1e-9},
+			{"Geohash", "u4pruydqqvj", 57.64911, 10.40744, 1e-4},
+			{"Geohash with prefix", "geohash:9v6k", 30.322266, -97.910156, 1e-5},
+			{"MGRS", "18SUJ2348706483", 38.8895, -77.0353, 1e-3},
+			{"MGRS with spaces", "18S UJ 23487 06483", 38.8895, -77.0353, 1e-3},
+			{"UTM", "18S 323487 4306483", 38.8895, -77.0353, 1e-3},
+			{"UTM southern hemisphere", "56H 334369 6250948", -33.8688, 151.2093, 1e-3},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p, err := coords.Parse(tt.input)
+
+				if err != nil {
+					t.Fatalf("Parse(%q) got error %s", tt.input, err.Error())
+				}
+
+				if math.Abs(p.Lat-tt.lat) > tt.tolerance || math.Abs(p.Lon-tt.lon) > tt.tolerance {
+					t.Errorf("Parse(%q) = (%f, %f), want (%f, %f)", tt.input, p.Lat, p.Lon, tt.lat, tt.lon)
+				}
+			})
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []string{
+			"",
+			"Austin",
+			"78701",
+			"91,0",
+			"0,181",
+			"30 61 0 N 97 0 0 W",
+			"30N 40N",
+			"-30S 97W",
+			"1.5 2 3 4",
+			"CWC8+R9",
+			"849VCWC8+R",
+			"61XUJ2348706483",
+			"18SUJ234870648",
+			"14RPW2116049893",
+			"K2",
+			"b52",
+			"dc3",
+			"9v6k",
+		}
+
+		for _, input := range tests {
+			if p, err := coords.Parse(input); err == nil {
+				t.Errorf("Parse(%q) = %s, want error", input, p)
+			}
+		}
+	})
+}
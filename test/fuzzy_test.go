@@ -0,0 +1,87 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/fuzzy"
+	"github.com/desertthunder/weather/internal/gazetteer"
+	"github.com/desertthunder/weather/internal/nws"
+)
+
+func TestFuzzy(t *testing.T) {
+	t.Run("EditDistance", func(t *testing.T) {
+		tests := []struct {
+			a, b string
+			want int
+		}{
+			{"", "", 0},
+			{"austin", "", 6},
+			{"clevland", "cleveland", 1},
+			{"pittsburg", "pittsburgh", 1},
+			{"bostno", "boston", 1}, // transposition
+			{"kitten", "sitting", 3},
+			{"ca", "abc", 3}, // optimal string alignment
+		}
+
+		for _, tt := range tests {
+			if got := fuzzy.EditDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("Similarity", func(t *testing.T) {
+		if s := fuzzy.Similarity("Seattle", "seattle"); s != 1 {
+			t.Errorf("Expected identical strings to score 1, got %f", s)
+		}
+
+		close := fuzzy.Similarity("Clevland", "Cleveland")
+		far := fuzzy.Similarity("Clevland", "Hartford")
+
+		if close <= far {
+			t.Errorf("Expected Cleveland (%f) to score above Hartford (%f)", close, far)
+		}
+	})
+
+	t.Run("Suggest", func(t *testing.T) {
+		tests := map[string]string{
+			"Clevland":   "Cleveland",
+			"Pittsburg":  "Pittsburgh",
+			"Seatle":     "Seattle",
+			"Los Angels": "Los Angeles",
+		}
+
+		for q, want := range tests {
+			got := fuzzy.Suggest(q, nws.CityNames(), 1)
+
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("Suggest(%q) = %v, want [%s]", q, got, want)
+			}
+		}
+
+		if got := fuzzy.Suggest("Zzyzx", nws.CityNames(), 3); len(got) != 0 {
+			t.Errorf("Expected no suggestions, got %v", got)
+		}
+	})
+
+	t.Run("Gazetteer", func(t *testing.T) {
+		g, err := gazetteer.New(strings.NewReader(gazetteerPlaces))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		got := g.Suggest("Portlnd", 0)
+
+		if len(got) != 2 || got[0].Admin1 != "OR" {
+			t.Errorf("Expected both Portlands (OR first), got %v", got)
+		}
+
+		got = g.Suggest("Portlnd, ME", 0)
+
+		if len(got) != 1 || got[0].Admin1 != "ME" {
+			t.Errorf("Expected qualifiers to filter suggestions, got %v", got)
+		}
+	})
+}
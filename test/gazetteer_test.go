@@ -0,0 +1,130 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/gazetteer"
+)
+
+const gazetteerPlaces = `Portland	OR	US	45.5152	-122.6784	654741
+Portland	ME	US	43.6591	-70.2568	66215
+Austin	TX	US	30.2672	-97.7431	978908
+Aurora	CO	US	39.7294	-104.8319	379289
+Augusta	GA	US	33.4735	-82.0105	197166
+St. Louis	MO	US	38.6270	-90.1994	300576
+`
+
+func TestGazetteer(t *testing.T) {
+	g, err := gazetteer.New(strings.NewReader(gazetteerPlaces))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		d, err := gazetteer.Default()
+
+		if err != nil {
+			t.Fatalf("Expected bundled gazetteer to load, got %s", err.Error())
+		}
+
+		if d.Len() < 100 {
+			t.Errorf("Expected at least 100 bundled places, got %d", d.Len())
+		}
+
+		for _, name := range []string{"Seattle", "Austin", "Cleveland", "Hartford", "Boston", "Los Angeles", "Pittsburgh"} {
+			if len(d.Lookup(name)) == 0 {
+				t.Errorf("Expected %s to be in the bundled gazetteer", name)
+			}
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		results := g.Lookup("portland")
+
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(results))
+		}
+
+		if results[0].Admin1 != "OR" {
+			t.Errorf("Expected the most populous Portland first, got %s", results[0].Label())
+		}
+
+		for _, q := range []string{"Portland, ME", "Portland, Maine", "portland, me, us"} {
+			results = g.Lookup(q)
+
+			if len(results) != 1 || results[0].Admin1 != "ME" {
+				t.Errorf("Expected %q to match only Portland, ME, got %v", q, results)
+			}
+		}
+
+		if len(g.Lookup("st louis")) != 1 {
+			t.Errorf("Expected punctuation to be ignored")
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		results := g.Prefix("Au", 0)
+
+		if len(results) != 3 {
+			t.Fatalf("Expected 3 results, got %d", len(results))
+		}
+
+		if results[0].Name != "Austin" {
+			t.Errorf("Expected Austin first, got %s", results[0].Name)
+		}
+
+		if len(g.Prefix("Au", 1)) != 1 {
+			t.Errorf("Expected limit to be applied")
+		}
+	})
+
+	t.Run("Nearest", func(t *testing.T) {
+		place, dist, err := g.Nearest(30.5083, -97.6789)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if place.Name != "Austin" {
+			t.Errorf("Expected Austin, got %s", place.Name)
+		}
+
+		if dist < 20 || dist > 35 {
+			t.Errorf("Expected distance of ~28km, got %f", dist)
+		}
+
+		place, _, _ = g.Nearest(44.0, -69.0)
+
+		if place.Label() != "Portland, ME, US" {
+			t.Errorf("Expected Portland, ME, got %s", place.Label())
+		}
+	})
+
+	t.Run("Geocode", func(t *testing.T) {
+		city, err := g.GeocodeByCity("Austin")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if city.Lat != 30.2672 || city.Long != -97.7431 {
+			t.Errorf("Unexpected point (%f, %f)", city.Lat, city.Long)
+		}
+
+		if _, err = g.GeocodeByCity("Atlantis"); err == nil {
+			t.Errorf("Expected an error for an unknown city")
+		}
+
+		city, err = g.GeocodeByPoint(30.5, -97.7)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(city.Name, "Austin") || city.Lat != 30.5 {
+			t.Errorf("Expected a point near Austin, got %s", city.Fmt())
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/gpsd"
+)
+
+// fakeGPSD starts a server that replies to a WATCH command with the given
+// reports and returns its address.
+func fakeGPSD(t *testing.T, reports ...string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				conn.Write([]byte(`{"class":"VERSION","release":"3.25","proto_major":3,"proto_minor":15}` + "\n"))
+
+				line, err := bufio.NewReader(conn).ReadString('\n')
+
+				if err != nil || !strings.HasPrefix(line, "?WATCH=") {
+					return
+				}
+
+				conn.Write([]byte(`{"class":"DEVICES","devices":[{"class":"DEVICE","path":"/dev/ttyACM0"}]}` + "\n"))
+
+				for _, r := range reports {
+					conn.Write([]byte(r + "\n"))
+				}
+
+				// Keep the connection open until the client gives up.
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				conn.Read(make([]byte, 1))
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestGPSD(t *testing.T) {
+	client := func(addr string) *gpsd.Client {
+		c := gpsd.NewClient()
+		c.Address = addr
+		c.Timeout = 500 * time.Millisecond
+
+		return c
+	}
+
+	t.Run("Fix", func(t *testing.T) {
+		addr := fakeGPSD(t,
+			`{"class":"TPV","device":"/dev/ttyACM0","mode":1}`,
+			`not json`,
+			`{"class":"SKY","satellites":[]}`,
+			`{"class":"TPV","device":"/dev/ttyACM0","mode":2,"lat":30.2672,"lon":-97.7431,"epx":450.5,"epy":600.1}`,
+			`{"class":"TPV","device":"/dev/ttyACM0","mode":3,"lat":30.2711,"lon":-97.7437,"alt":150.2,"epx":8.1,"epy":9.4,"eph":12.3}`,
+		)
+
+		tpv, err := client(addr).Fix()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if tpv.Lat != 30.2711 || tpv.Lon != -97.7437 || tpv.Mode != gpsd.Mode3D {
+			t.Errorf("Expected the accurate 3D fix, got %+v", tpv)
+		}
+
+		if tpv.Accuracy() != 12.3 {
+			t.Errorf("Expected accuracy 12.3, got %f", tpv.Accuracy())
+		}
+	})
+
+	t.Run("Any accuracy", func(t *testing.T) {
+		addr := fakeGPSD(t, `{"class":"TPV","mode":2,"lat":1.5,"lon":2.5,"epx":450.5,"epy":600.1}`)
+
+		c := client(addr)
+		c.Accuracy = 0
+
+		tpv, err := c.Fix()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if tpv.Accuracy() != 600.1 {
+			t.Errorf("Expected the larger of epx and epy, got %f", tpv.Accuracy())
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		addr := fakeGPSD(t, `{"class":"TPV","mode":2,"lat":1.5,"lon":2.5,"eph":5000}`)
+
+		_, err := client(addr).Fix()
+
+		if err == nil || !strings.Contains(err.Error(), "no GPS fix within") || !strings.Contains(err.Error(), "5000 m") {
+			t.Errorf("Expected a timeout error, got %v", err)
+		}
+	})
+
+	t.Run("Error report", func(t *testing.T) {
+		addr := fakeGPSD(t, `{"class":"ERROR","message":"unrecognized request"}`)
+
+		if _, err := client(addr).Fix(); err == nil || !strings.Contains(err.Error(), "unrecognized request") {
+			t.Errorf("Expected a gpsd error, got %v", err)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		if _, err := client(addr).Fix(); err == nil {
+			t.Errorf("Expected a connection error")
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/ical"
+)
+
+func encodeCalendar(t *testing.T, cal ical.Calendar) string {
+	buf := bytes.Buffer{}
+
+	if err := cal.Encode(&buf); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+// unfold joins folded iCalendar lines back together.
+func unfold(s string) string {
+	return strings.ReplaceAll(s, "\r\n ", "")
+}
+
+func TestICal(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+
+	if err != nil {
+		t.Skip("No time zone data")
+	}
+
+	stamp := time.Date(2024, 10, 30, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 11, 2, 18, 0, 0, 0, chicago)
+	long := strings.Repeat("Sunny, with a high near 75°F; south wind 5 mph. ", 5)
+
+	cal := ical.Calendar{
+		Name:     "Weather for Austin, TX",
+		Location: chicago,
+		Stamp:    stamp,
+		Events: []ical.Event{
+			{UID: "a@geocast", Summary: "Sunny 75°F", Description: long, Location: "Austin, TX", Lat: 30.2672, Lon: -97.7431, HasGeo: true, Start: start, End: start.Add(12 * time.Hour), Transparent: true},
+			{UID: "b@geocast", Summary: "Clear", Start: start.Add(36 * time.Hour), End: start.Add(48 * time.Hour)},
+		},
+	}
+
+	out := encodeCalendar(t, cal)
+
+	t.Run("Lines", func(t *testing.T) {
+		if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+			t.Errorf("Unexpected calendar %q", out)
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+			if len(line) > 75 {
+				t.Errorf("Line longer than 75 octets: %q", line)
+			}
+		}
+
+		if !strings.Contains(unfold(out), "DESCRIPTION:"+strings.ReplaceAll(strings.ReplaceAll(long, ",", `\,`), ";", `\;`)+"\r\n") {
+			t.Errorf("Expected the escaped description, got %q", unfold(out))
+		}
+
+		if escaped := ical.Escape("a,b;c\\d\ne"); escaped != `a\,b\;c\\d\ne` {
+			t.Errorf("Unexpected escaping %q", escaped)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		for _, want := range []string{
+			"UID:a@geocast\r\nDTSTAMP:20241030T120000Z\r\nDTSTART;TZID=America/Chicago:20241102T180000\r\nDTEND;TZID=America/Chicago:20241103T050000\r\n",
+			"SUMMARY:Sunny 75°F\r\n",
+			"LOCATION:Austin\\, TX\r\n",
+			"GEO:30.267200;-97.743100\r\n",
+			"TRANSP:TRANSPARENT\r\n",
+			"X-WR-CALNAME:Weather for Austin\\, TX\r\n",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in %q", want, out)
+			}
+		}
+	})
+
+	t.Run("Time zone", func(t *testing.T) {
+		// Daylight saving time started in March and ends on November 3.
+		want := "BEGIN:VTIMEZONE\r\nTZID:America/Chicago\r\n" +
+			"BEGIN:DAYLIGHT\r\nDTSTART:20240310T020000\r\nTZOFFSETFROM:-0600\r\nTZOFFSETTO:-0500\r\nTZNAME:CDT\r\nEND:DAYLIGHT\r\n" +
+			"BEGIN:STANDARD\r\nDTSTART:20241103T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0600\r\nTZNAME:CST\r\nEND:STANDARD\r\n" +
+			"END:VTIMEZONE\r\n"
+
+		if !strings.Contains(out, want) {
+			t.Errorf("Unexpected time zone in %q", out)
+		}
+
+		phoenix, _ := time.LoadLocation("America/Phoenix")
+		cal.Location = phoenix
+
+		if !strings.Contains(encodeCalendar(t, cal), "BEGIN:STANDARD\r\nDTSTART:19700101T000000\r\nTZOFFSETFROM:-0700\r\nTZOFFSETTO:-0700\r\nTZNAME:MST\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n") {
+			t.Errorf("Expected a single observance for a zone without daylight saving time")
+		}
+
+		cal.Location = nil
+		utc := encodeCalendar(t, cal)
+
+		if strings.Contains(utc, "VTIMEZONE") || !strings.Contains(utc, "DTSTART:20241102T230000Z\r\n") {
+			t.Errorf("Expected UTC times, got %q", utc)
+		}
+	})
+}
+
+func TestExportICS(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	t.Run("Stdout", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "export", "ics", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		out = unfold(out)
+
+		for _, want := range []string{
+			"TZID:America/Chicago\r\n",
+			"DTSTART;TZID=America/Chicago:20240802T180000\r\nDTEND;TZID=America/Chicago:20240803T060000\r\nSUMMARY:Mostly Clear 78°F\r\n",
+			"SUMMARY:Sunny 101°F\r\nDESCRIPTION:Sunny\\, with a high near 101. Hot | humid.\\n\\nWind: 5 to 10 mph S\r\n",
+			"GEO:30.271300;-97.742600\r\n",
+			"UID:urn:oid:2.49.0.1.840.0.1\r\n",
+			"SUMMARY:⚠ Heat Advisory\r\n",
+			"LOCATION:Travis\\, TX\r\n",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in %q", want, out)
+			}
+		}
+
+		if n := strings.Count(out, "BEGIN:VEVENT"); n != 3 {
+			t.Errorf("Expected 3 events, got %d", n)
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(dir, "calendars", "austin.ics")
+		out, err := runGeocast(t, "--geocoder", "offline", "export", "ics", "--file", path, "--alerts=false", "--tz", "UTC", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "Saved 2 events to "+path) {
+			t.Errorf("Unexpected output %q", out)
+		}
+
+		data, _ := os.ReadFile(path)
+
+		if !strings.Contains(string(data), "DTSTART:20240802T230000Z\r\n") || strings.Contains(string(data), "Heat Advisory") {
+			t.Errorf("Unexpected calendar %q", data)
+		}
+	})
+
+	t.Run("Invalid time zone", func(t *testing.T) {
+		_, err := runGeocast(t, "--geocoder", "offline", "export", "ics", "--tz", "Mars/Olympus", "78701")
+
+		if err == nil || !strings.Contains(err.Error(), "unknown time zone") {
+			t.Errorf("Expected an error, got %v", err)
+		}
+	})
+}
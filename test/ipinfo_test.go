@@ -1,8 +1,12 @@
 package test
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/desertthunder/weather/internal/ipinfo"
@@ -48,6 +52,20 @@ func TestIPInfoClient(t *testing.T) {
 				ipaddr:  "8.8.8.8",
 				wantErr: true, // We expect an error because the token is empty
 			},
+			{
+				name:     "Valid IPv6",
+				token:    "valid_token",
+				ipaddr:   "2001:4860:4860::8888",
+				mockResp: `{"city": "Mountain View", "region": "California", "country": "US", "loc": "37.4056,-122.0775"}`,
+				wantErr:  false,
+			},
+			{
+				name:     "Private IP",
+				token:    "valid_token",
+				ipaddr:   "192.168.1.1",
+				mockResp: `{"city": "Austin", "region": "Texas", "country": "US", "loc": "30.2672,-97.7431"}`,
+				wantErr:  true,
+			},
 			{
 				name:     "Invalid IP",
 				token:    "valid_token",
@@ -86,6 +104,29 @@ func TestIPInfoClient(t *testing.T) {
 		}
 	})
 
+	t.Run("Authorization", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer valid_token" || r.URL.Query().Has("token") {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			w.Write([]byte(`{"ip": "8.8.8.8", "loc": "37.4056,-122.0775"}`))
+		}))
+
+		defer server.Close()
+
+		client := ipinfo.NewIPInfoClient("valid_token")
+		client.SetURL(server.URL)
+
+		ip := "8.8.8.8"
+
+		if _, err := client.Geolocate(&ip); err != nil {
+			t.Errorf("Expected the token in the Authorization header, got %s", err.Error())
+		}
+	})
+
 	t.Run("BuildCity", func(t *testing.T) {
 		r := ipinfo.IPInfoResponse{
 			City:     "Austin",
@@ -94,7 +135,11 @@ func TestIPInfoClient(t *testing.T) {
 			Location: "30.2672,-97.7431",
 		}
 
-		city := r.BuildCity()
+		city, err := r.BuildCity()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
 
 		if city.Name != "Austin" {
 			t.Errorf("Expected city name to be Austin, got %s", city.Name)
@@ -114,7 +159,11 @@ func TestIPInfoClient(t *testing.T) {
 			Location: "30.2672,-97.7431",
 		}
 
-		lat, lon := r.Point()
+		lat, lon, err := r.Point()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
 
 		if lat != 30.2672 {
 			t.Errorf("Expected latitude to be 30.2672, got %f", lat)
@@ -123,6 +172,18 @@ func TestIPInfoClient(t *testing.T) {
 		if lon != -97.7431 {
 			t.Errorf("Expected longitude to be -97.7431, got %f", lon)
 		}
+
+		for _, loc := range []string{"", "30.2672", "30.2672,", "north,-97.7431", "30.2672,-97.7431,0", "95,-97.7431"} {
+			r := ipinfo.IPInfoResponse{Location: loc}
+
+			if _, _, err := r.Point(); err == nil {
+				t.Errorf("Expected an error for location %q", loc)
+			}
+
+			if _, err := r.BuildCity(); err == nil {
+				t.Errorf("Expected BuildCity to fail for location %q", loc)
+			}
+		}
 	})
 
 	t.Run("Validate", func(t *testing.T) {
@@ -136,10 +197,242 @@ func TestIPInfoClient(t *testing.T) {
 			t.Errorf("Expected error to be nil, got %s", err.Error())
 		}
 
-		err = r.Validate([]byte(`{"bogon": "true"}`))
+		err = r.Validate([]byte(`{"ip": "10.0.0.1", "bogon": true}`))
+
+		if !errors.Is(err, ipinfo.ErrBogon) {
+			t.Errorf("Expected ErrBogon, got %v", err)
+		}
+
+		// Mentioning "bogon" in a field value is not a bogon response.
+		err = (&ipinfo.IPInfoResponse{}).Validate([]byte(`{"ip": "8.8.8.8", "org": "AS1 Bogon Networks", "loc": "1,2"}`))
+
+		if err != nil {
+			t.Errorf("Expected error to be nil, got %s", err.Error())
+		}
+
+		err = (&ipinfo.IPInfoResponse{}).Validate([]byte(`{"ip": "8.8.8.8", "city": "Austin"}`))
 
 		if err == nil {
-			t.Errorf("Expected error to be non-nil, got %s", err)
+			t.Errorf("Expected an error for a response without a location")
+		}
+
+		err = (&ipinfo.IPInfoResponse{}).Validate([]byte(`{"status": 429, "error": {"title": "Rate limit exceeded", "message": "Upgrade to increase your usage limits."}}`))
+
+		if !errors.Is(err, ipinfo.ErrQuotaExceeded) {
+			t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+		}
+	})
+
+	t.Run("HTTP errors", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			status int
+			body   string
+			want   error
+		}{
+			{"Invalid token", http.StatusUnauthorized, `{"status": 401, "error": {"title": "Unknown token", "message": "Please ensure you've entered your token correctly."}}`, ipinfo.ErrInvalidToken},
+			{"Forbidden", http.StatusForbidden, `{"error": "Forbidden"}`, ipinfo.ErrInvalidToken},
+			{"Quota exceeded", http.StatusTooManyRequests, `{"status": 429, "error": {"title": "Rate limit exceeded", "message": "Upgrade to increase your usage limits."}}`, ipinfo.ErrQuotaExceeded},
+			{"Server error", http.StatusBadGateway, `<html>Bad Gateway</html>`, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+				}))
+
+				defer server.Close()
+
+				client := ipinfo.NewIPInfoClient("valid_token")
+				client.SetURL(server.URL)
+
+				ip := "8.8.8.8"
+				_, err := client.Geolocate(&ip)
+
+				var apiErr *ipinfo.APIError
+
+				if !errors.As(err, &apiErr) || apiErr.Status != tt.status {
+					t.Fatalf("Expected an APIError with status %d, got %v", tt.status, err)
+				}
+
+				if tt.want != nil && !errors.Is(err, tt.want) {
+					t.Errorf("Expected %v, got %v", tt.want, err)
+				}
+
+				if _, err := client.GeolocateBatch([]string{ip}); !errors.As(err, &apiErr) {
+					t.Errorf("Expected an APIError from the batch endpoint, got %v", err)
+				}
+			})
+		}
+	})
+}
+
+func TestIPInfoBatch(t *testing.T) {
+	body := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/batch" || r.Header.Get("Authorization") != "Bearer valid_token" || r.URL.Query().Has("token") {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+
+		w.Write([]byte(`{
+			"8.8.8.8": {
+				"ip": "8.8.8.8", "city": "Mountain View", "country": "US", "loc": "37.4056,-122.0775",
+				"asn": {"asn": "AS15169", "name": "Google LLC", "domain": "google.com", "route": "8.8.8.0/24", "type": "hosting"},
+				"company": {"name": "Google LLC", "domain": "google.com", "type": "hosting"},
+				"privacy": {"vpn": false, "proxy": false, "tor": false, "relay": false, "hosting": true, "service": ""},
+				"abuse": {"email": "network-abuse@google.com", "network": "8.8.8.0/24"}
+			},
+			"2606:4700:4700::1111": {"ip": "2606:4700:4700::1111", "city": "San Francisco", "country": "US", "loc": "37.7621,-122.3971"}
+		}`))
+	}))
+
+	defer server.Close()
+
+	client := ipinfo.NewIPInfoClient("valid_token")
+	client.SetURL(server.URL)
+
+	t.Run("GeolocateBatch", func(t *testing.T) {
+		results, err := client.GeolocateBatch([]string{"8.8.8.8", "2606:4700:4700:0:0:0:0:1111"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if body != `["8.8.8.8","2606:4700:4700::1111"]` {
+			t.Errorf("Unexpected request body %s", body)
+		}
+
+		google := results["8.8.8.8"]
+
+		if google.ASN == nil || google.ASN.ASN != "AS15169" || google.Company == nil || google.Abuse == nil {
+			t.Errorf("Expected the structured fields to be parsed, got %+v", google)
+		}
+
+		if flags := google.Privacy.Flags(); len(flags) != 1 || flags[0] != "hosting" {
+			t.Errorf("Expected privacy flags [hosting], got %v", flags)
+		}
+
+		if results["2606:4700:4700::1111"].City != "San Francisco" {
+			t.Errorf("Expected the IPv6 result keyed by its normalized address, got %+v", results)
+		}
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		if _, err := client.GeolocateBatch([]string{"8.8.8.8", "10.0.0.1"}); err == nil {
+			t.Errorf("Expected an error for a private IP")
+		}
+
+		if _, err := ipinfo.NewIPInfoClient("").GeolocateBatch([]string{"8.8.8.8"}); err == nil {
+			t.Errorf("Expected an error for a missing token")
+		}
+	})
+
+	t.Run("Missing result", func(t *testing.T) {
+		results, err := client.GeolocateBatch([]string{"8.8.8.8", "1.1.1.1"})
+
+		if err == nil || !strings.Contains(err.Error(), "1.1.1.1") {
+			t.Errorf("Expected an error when the response is missing an address, got %v", err)
+		}
+
+		if _, ok := results["8.8.8.8"]; !ok || len(results) != 1 {
+			t.Errorf("Expected the other addresses to be kept, got %+v", results)
+		}
+	})
+
+	t.Run("Chain retries the missing addresses", func(t *testing.T) {
+		fallback := &countingGeolocator{}
+		chain := ipinfo.NewChain(
+			ipinfo.Provider{Name: "ipinfo", Geolocator: client},
+			ipinfo.Provider{Name: "fallback", Geolocator: fallback},
+		)
+
+		results, err := ipinfo.Batch(chain, []string{"8.8.8.8", "1.1.1.1"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if results["8.8.8.8"].City != "Mountain View" || results["1.1.1.1"].City != "Austin" || fallback.calls != 1 {
+			t.Errorf("Expected only 1.1.1.1 to be sent to the fallback, got %+v after %d calls", results, fallback.calls)
+		}
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		chain := ipinfo.NewChain(ipinfo.Provider{Name: "ipinfo", Geolocator: client})
+
+		results, err := ipinfo.Batch(chain, []string{"8.8.8.8"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if results["8.8.8.8"].City != "Mountain View" {
+			t.Errorf("Unexpected results %+v", results)
+		}
+	})
+}
+
+func TestIPLookupCommand(t *testing.T) {
+	batches, singles := atomic.Int64{}, atomic.Int64{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/batch" {
+			singles.Add(1)
+			w.Write([]byte(`{"ip": "8.8.8.8", "city": "Mountain View", "country": "US", "loc": "37.4056,-122.0775"}`))
+
+			return
+		}
+
+		batches.Add(1)
+		w.Write([]byte(`{
+			"8.8.8.8": {"ip": "8.8.8.8", "city": "Mountain View", "country": "US", "loc": "37.4056,-122.0775"},
+			"1.1.1.1": {"ip": "1.1.1.1", "city": "Brisbane", "country": "AU", "loc": "-27.4820,153.0136"}
+		}`))
+	}))
+
+	defer server.Close()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_IP_PROVIDER", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("LOCATION_TTL", "1h")
+	t.Setenv("IPINFO_TOKEN", "valid_token")
+	t.Setenv("IPINFO_URL", server.URL)
+
+	out, err := runGeocast(t, "ip", "lookup", "8.8.8.8", "1.1.1.1")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	if !strings.Contains(out, "Mountain View") || !strings.Contains(out, "Brisbane") {
+		t.Errorf("Expected both addresses in %s", out)
+	}
+
+	if batches.Load() != 1 || singles.Load() != 0 {
+		t.Errorf("Expected a single /batch request, got %d batch and %d single requests", batches.Load(), singles.Load())
+	}
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Setenv("GEOCAST_IP_PROVIDER", "ipinfo")
+
+		out, err := runGeocast(t, "ip", "lookup", "8.8.8.8", "9.9.9.9")
+
+		if err == nil || !strings.Contains(err.Error(), "9.9.9.9") {
+			t.Errorf("Expected an error for 9.9.9.9, got %v", err)
+		}
+
+		if !strings.Contains(out, "Mountain View") {
+			t.Errorf("Expected the other address to be shown, got %s", out)
 		}
 	})
 }
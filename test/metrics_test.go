@@ -0,0 +1,263 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/cmd/cli"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/metrics"
+	"github.com/desertthunder/weather/internal/transport"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+func writeMetrics(t *testing.T, r *metrics.Registry) string {
+	buf := bytes.Buffer{}
+
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	requests := r.Counter("test_requests_total", "Requests.", "host", "code")
+	temperature := r.Gauge("test_temperature_celsius", "Temperature\nin °C.", "place")
+	duration := r.Histogram("test_duration_seconds", "Durations.", []float64{1, 0.1}, "host")
+	r.Gauge("test_empty", "Never set.")
+	r.GaugeFunc("test_ratio", "A ratio.", func() float64 { return 0.25 })
+
+	requests.Inc("b.example", "200")
+	requests.Add(2, "a.example", "200")
+	temperature.Set(21.5, "home")
+	temperature.Set(-3, `say "hi"\`)
+	temperature.Set(30, "gone")
+	temperature.Delete("gone")
+	duration.Observe(0.05, "a.example")
+	duration.Observe(0.5, "a.example")
+	duration.Observe(5, "a.example")
+
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{host="a.example",le="0.1"} 1
+test_duration_seconds_bucket{host="a.example",le="1"} 2
+test_duration_seconds_bucket{host="a.example",le="+Inf"} 3
+test_duration_seconds_sum{host="a.example"} 5.55
+test_duration_seconds_count{host="a.example"} 3
+# HELP test_ratio A ratio.
+# TYPE test_ratio gauge
+test_ratio 0.25
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{host="a.example",code="200"} 2
+test_requests_total{host="b.example",code="200"} 1
+# HELP test_temperature_celsius Temperature\nin °C.
+# TYPE test_temperature_celsius gauge
+test_temperature_celsius{place="home"} 21.5
+test_temperature_celsius{place="say \"hi\"\\"} -3
+`
+
+	if got := writeMetrics(t, r); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	t.Run("Handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+			t.Errorf("Unexpected content type %s", ct)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected registering a name twice to panic")
+			}
+		}()
+
+		r.Counter("test_requests_total", "Again.")
+	})
+}
+
+func TestMetricsWeather(t *testing.T) {
+	r := metrics.NewRegistry()
+	w := metrics.NewWeather(r)
+	v := func(f float64) *float64 { return &f }
+
+	w.SetUp("home", true)
+	w.SetConditions("home", view.Conditions{
+		Observed:      "2024-08-02T17:51:00+00:00",
+		Temperature:   v(35),
+		Humidity:      v(42.3),
+		WindSpeed:     v(36),
+		WindDirection: v(180),
+		Pressure:      v(1015.9),
+	})
+	w.SetForecast("home", view.Period{Temperature: 50, TemperatureUnit: "F", PrecipitationChance: 20})
+
+	out := writeMetrics(t, r)
+
+	for _, line := range []string{
+		`geocast_weather_up{place="home"} 1`,
+		`geocast_observation_timestamp_seconds{place="home"} 1.72262106e+09`,
+		`geocast_temperature_celsius{place="home"} 35`,
+		`geocast_relative_humidity_percent{place="home"} 42.3`,
+		`geocast_wind_speed_meters_per_second{place="home"} 10`,
+		`geocast_wind_direction_degrees{place="home"} 180`,
+		`geocast_pressure_pascals{place="home"} 10159`,
+		`geocast_forecast_precipitation_probability_percent{place="home"} 20`,
+		`geocast_forecast_temperature_celsius{place="home"} 10`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %s in\n%s", line, out)
+		}
+	}
+
+	if strings.Contains(out, "geocast_wind_gust") || strings.Contains(out, "geocast_dewpoint") {
+		t.Errorf("Expected values that weren't reported to be left out, got\n%s", out)
+	}
+
+	w.Delete("home")
+
+	if out := writeMetrics(t, r); out != "" {
+		t.Errorf("Expected no metrics after deleting the place, got\n%s", out)
+	}
+}
+
+func TestMetricsClient(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := metrics.NewClient(r)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	tracing := transport.New(logger.Init())
+	tracing.SetObserver(c.ObserveRequest)
+	client := http.Client{Transport: tracing}
+
+	for _, path := range []string{"/", "/", "/down"} {
+		res, err := client.Get(srv.URL + path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		res.Body.Close()
+	}
+
+	if _, err := client.Get("http://127.0.0.1:1/"); err == nil {
+		t.Fatal("Expected a connection error")
+	}
+
+	f := cache.Fetcher{Store: cache.New(t.TempDir()), TTL: time.Minute, Log: logger.Init(), Observe: c.ObserveCache}
+
+	for range 3 {
+		cache.Fetch(f, "key", func() (int, error) { return 1, nil })
+	}
+
+	f.TTL = time.Nanosecond
+	cache.Fetch(f, "key", func() (int, error) { return 0, errors.New("down") })
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	out := writeMetrics(t, r)
+
+	for _, line := range []string{
+		`geocast_http_requests_total{host="` + host + `",code="200"} 2`,
+		`geocast_http_requests_total{host="` + host + `",code="503"} 1`,
+		`geocast_http_requests_total{host="127.0.0.1:1",code="error"} 1`,
+		`geocast_http_errors_total{host="` + host + `"} 1`,
+		`geocast_http_errors_total{host="127.0.0.1:1"} 1`,
+		`geocast_http_request_duration_seconds_count{host="` + host + `"} 3`,
+		`geocast_cache_requests_total{result="hit"} 2`,
+		`geocast_cache_requests_total{result="miss"} 1`,
+		`geocast_cache_requests_total{result="stale"} 1`,
+		`geocast_cache_hit_ratio 0.5`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %s in\n%s", line, out)
+		}
+	}
+}
+
+func TestExporterCommand(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	os.MkdirAll(filepath.Join(dir, "geocast"), 0o755)
+	os.WriteFile(filepath.Join(dir, "geocast", "places.toml"), []byte("[[places]]\nname = \"home\"\nlat = 30.2672\nlon = -97.7431\n"), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- cli.Application().RunContext(ctx, []string{"geocast", "exporter", "--addr", addr})
+	}()
+
+	want := `geocast_temperature_celsius{place="home"} 35`
+	body := ""
+
+	for range 50 {
+		if res, err := http.Get("http://" + addr + "/metrics"); err == nil {
+			data, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			if body = string(data); strings.Contains(body, want) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for _, line := range []string{want, `geocast_weather_up{place="home"} 1`, `geocast_forecast_precipitation_probability_percent{place="home"} 20`, `code="200"}`} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %s in\n%s", line, body)
+		}
+	}
+
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Expected a graceful shutdown, got %s", err.Error())
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("Expected the exporter to stop")
+	}
+}
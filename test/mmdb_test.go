@@ -0,0 +1,214 @@
+package test
+
+import (
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/ipinfo"
+)
+
+// mmdbEncode encodes a value in the MaxMind DB data section format. Only the
+// types needed for the test databases are supported.
+func mmdbEncode(v any) []byte {
+	ctrl := func(typ, size int) []byte {
+		if typ <= 7 {
+			return []byte{byte(typ<<5 | size)}
+		}
+
+		return []byte{byte(size), byte(typ - 7)}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(ctrl(2, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(ctrl(3, 8), math.Float64bits(v))
+	case uint16:
+		return binary.BigEndian.AppendUint16(ctrl(5, 2), v)
+	case uint32:
+		return binary.BigEndian.AppendUint32(ctrl(6, 4), v)
+	case uint64:
+		return binary.BigEndian.AppendUint64(ctrl(9, 8), v)
+	case []any:
+		out := ctrl(11, len(v))
+
+		for _, item := range v {
+			out = append(out, mmdbEncode(item)...)
+		}
+
+		return out
+	case map[string]any:
+		keys := []string{}
+
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		out := ctrl(7, len(v))
+
+		for _, k := range keys {
+			out = append(out, mmdbEncode(k)...)
+			out = append(out, mmdbEncode(v[k])...)
+		}
+
+		return out
+	}
+
+	panic("unsupported mmdb type")
+}
+
+// writeMMDB writes an IPv4 MaxMind DB with 24 bit records mapping each
+// network to a record and returns its path.
+func writeMMDB(t *testing.T, records map[string]map[string]any) string {
+	type node struct {
+		// Child node index, or -1.
+		child [2]int
+		// Data offset, or -1.
+		data [2]int
+	}
+
+	nodes := []node{{child: [2]int{-1, -1}, data: [2]int{-1, -1}}}
+	data := []byte{}
+
+	for cidr, record := range records {
+		prefix := netip.MustParsePrefix(cidr)
+		ip := prefix.Addr().As4()
+		offset := len(data)
+		data = append(data, mmdbEncode(record)...)
+
+		curr := 0
+
+		for i := 0; i < prefix.Bits(); i++ {
+			bit := int(ip[i/8]>>(7-i%8)) & 1
+
+			if i == prefix.Bits()-1 {
+				nodes[curr].data[bit] = offset
+				break
+			}
+
+			if nodes[curr].child[bit] < 0 {
+				nodes = append(nodes, node{child: [2]int{-1, -1}, data: [2]int{-1, -1}})
+				nodes[curr].child[bit] = len(nodes) - 1
+			}
+
+			curr = nodes[curr].child[bit]
+		}
+	}
+
+	count := len(nodes)
+	out := []byte{}
+
+	for _, n := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			value := count
+
+			if n.child[bit] >= 0 {
+				value = n.child[bit]
+			} else if n.data[bit] >= 0 {
+				value = count + 16 + n.data[bit]
+			}
+
+			out = append(out, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+
+	out = append(out, make([]byte, 16)...)
+	out = append(out, data...)
+	out = append(out, "\xab\xcd\xefMaxMind.com"...)
+	out = append(out, mmdbEncode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1722556800),
+		"database_type":               "GeoLite2-City",
+		"description":                 map[string]any{"en": "geocast test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+	})...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatalf("Failed to write mmdb file: %s", err.Error())
+	}
+
+	return path
+}
+
+func TestMMDBClient(t *testing.T) {
+	path := writeMMDB(t, map[string]map[string]any{
+		"8.8.8.0/24": {
+			"city":         map[string]any{"names": map[string]any{"en": "Mountain View"}},
+			"country":      map[string]any{"iso_code": "US"},
+			"subdivisions": []any{map[string]any{"iso_code": "CA", "names": map[string]any{"en": "California"}}},
+			"location": map[string]any{
+				"latitude":  37.4056,
+				"longitude": -122.0775,
+				"time_zone": "America/Los_Angeles",
+			},
+			"postal": map[string]any{"code": "94043"},
+		},
+		"24.0.0.0/8": {
+			"country": map[string]any{"iso_code": "US"},
+		},
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		if _, err := ipinfo.NewMMDBClient(""); err == nil {
+			t.Errorf("Expected an error for an empty path")
+		}
+
+		if _, err := ipinfo.NewMMDBClient(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+			t.Errorf("Expected an error for a missing file")
+		}
+	})
+
+	client, err := ipinfo.NewMMDBClient(path)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	defer client.Close()
+
+	var _ ipinfo.Geolocator = client
+
+	t.Run("Geolocate", func(t *testing.T) {
+		ip := "8.8.8.8"
+		r, err := client.Geolocate(&ip)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if r.City != "Mountain View" || r.Region != "California" || r.Country != "US" || r.Postal != "94043" {
+			t.Errorf("Unexpected response %+v", r)
+		}
+
+		city, err := r.BuildCity()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if city.Lat != 37.4056 || city.Long != -122.0775 {
+			t.Errorf("Unexpected point (%f, %f)", city.Lat, city.Long)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		for _, ip := range []string{"1.1.1.1", "24.1.2.3", "10.0.0.1"} {
+			if _, err := client.Geolocate(&ip); err == nil {
+				t.Errorf("Expected an error for %s", ip)
+			}
+		}
+	})
+}
@@ -0,0 +1,545 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/cmd/cli"
+	"github.com/desertthunder/weather/internal/hass"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/mqtt"
+)
+
+// struct brokerMessage is a message received by the fake broker.
+type brokerMessage struct {
+	Topic   string
+	Payload string
+	QoS     byte
+	Retain  bool
+}
+
+// struct fakeBroker is an in-process MQTT 3.1.1 broker that records the
+// connections and messages it receives.
+type fakeBroker struct {
+	ln net.Listener
+	// CONNACK return code, 0 to accept connections.
+	code byte
+
+	mu       sync.Mutex
+	connects []brokerConnect
+	messages []brokerMessage
+	conns    []net.Conn
+	// Number of clean disconnections.
+	disconnects int
+}
+
+// struct brokerConnect is a received CONNECT packet.
+type brokerConnect struct {
+	ClientID, Username, Password, WillTopic, WillPayload string
+	WillRetain                                           bool
+	KeepAlive                                            uint16
+}
+
+func newFakeBroker(t *testing.T, ln net.Listener) *fakeBroker {
+	b := &fakeBroker{ln: ln}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+
+			if err != nil {
+				return
+			}
+
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+
+			go b.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		ln.Close()
+		b.dropAll()
+	})
+
+	return b
+}
+
+func fakeTCPBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return newFakeBroker(t, ln)
+}
+
+func (b *fakeBroker) url(scheme string) string {
+	return scheme + "://" + b.ln.Addr().String()
+}
+
+// func dropAll closes the connections, as a broker restart would.
+func (b *fakeBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, c := range b.conns {
+		c.Close()
+	}
+
+	b.conns = nil
+}
+
+// func retained returns the last message received on each topic.
+func (b *fakeBroker) retained() map[string]brokerMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := map[string]brokerMessage{}
+
+	for _, msg := range b.messages {
+		m[msg.Topic] = msg
+	}
+
+	return m
+}
+
+func readString(body []byte) (string, []byte) {
+	n := binary.BigEndian.Uint16(body)
+
+	return string(body[2 : 2+n]), body[2+n:]
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	for {
+		header, err := r.ReadByte()
+
+		if err != nil {
+			return
+		}
+
+		n, mult := 0, 1
+
+		for {
+			c, err := r.ReadByte()
+
+			if err != nil {
+				return
+			}
+
+			n += int(c&0x7f) * mult
+			mult *= 128
+
+			if c&0x80 == 0 {
+				break
+			}
+		}
+
+		body := make([]byte, n)
+
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1:
+			c := brokerConnect{}
+			_, rest := readString(body)
+			flags := rest[1]
+			c.KeepAlive = binary.BigEndian.Uint16(rest[2:])
+			c.ClientID, rest = readString(rest[4:])
+
+			if flags&0x04 != 0 {
+				c.WillRetain = flags&0x20 != 0
+				c.WillTopic, rest = readString(rest)
+				c.WillPayload, rest = readString(rest)
+			}
+
+			if flags&0x80 != 0 {
+				c.Username, rest = readString(rest)
+			}
+
+			if flags&0x40 != 0 {
+				c.Password, _ = readString(rest)
+			}
+
+			b.mu.Lock()
+			b.connects = append(b.connects, c)
+			b.mu.Unlock()
+
+			conn.Write([]byte{0x20, 2, 0, b.code})
+
+			if b.code != 0 {
+				return
+			}
+		case 3:
+			m := brokerMessage{QoS: (header >> 1) & 3, Retain: header&1 != 0}
+			rest := body
+			m.Topic, rest = readString(rest)
+
+			if m.QoS > 0 {
+				conn.Write([]byte{0x40, 2, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+
+			m.Payload = string(rest)
+
+			b.mu.Lock()
+			b.messages = append(b.messages, m)
+			b.mu.Unlock()
+		case 12:
+			conn.Write([]byte{0xd0, 0})
+		case 14:
+			b.mu.Lock()
+			b.disconnects++
+			b.mu.Unlock()
+
+			return
+		}
+	}
+}
+
+func TestMQTTClient(t *testing.T) {
+	b := fakeTCPBroker(t)
+	will := &mqtt.Message{Topic: "geocast/test/availability", Payload: []byte("offline"), Retain: true}
+	c := mqtt.New(mqtt.Options{Broker: b.url("tcp"), ClientID: "geocast-test", Username: "user", Password: "secret", QoS: 1, Will: will}, logger.Init())
+
+	if err := c.Publish(mqtt.Message{Topic: "a", Payload: []byte("1")}); err == nil {
+		t.Error("Expected an error before connecting")
+	}
+
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	t.Run("Connect", func(t *testing.T) {
+		want := brokerConnect{ClientID: "geocast-test", Username: "user", Password: "secret", WillTopic: "geocast/test/availability", WillPayload: "offline", WillRetain: true, KeepAlive: 60}
+
+		if len(b.connects) != 1 || b.connects[0] != want {
+			t.Errorf("Expected %+v, got %+v", want, b.connects)
+		}
+	})
+
+	t.Run("Publish", func(t *testing.T) {
+		long := strings.Repeat("x", 300)
+
+		if err := c.Publish(mqtt.Message{Topic: "geocast/test/conditions", Payload: []byte(long), Retain: true}); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		got := b.retained()["geocast/test/conditions"]
+
+		if got.Payload != long || !got.Retain || got.QoS != 1 {
+			t.Errorf("Unexpected message %+v", got)
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		b.dropAll()
+
+		deadline := time.Now().Add(2 * time.Second)
+
+		for c.Connected() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if c.Connected() {
+			t.Fatal("Expected the lost connection to be noticed")
+		}
+
+		if err := c.Publish(mqtt.Message{Topic: "a", Payload: []byte("1")}); err == nil {
+			t.Error("Expected an error on a lost connection")
+		}
+
+		if err := c.Connect(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if err := c.Publish(mqtt.Message{Topic: "a", Payload: []byte("2")}); err != nil {
+			t.Errorf("Expected no error after reconnecting, got %s", err.Error())
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := c.Close(); err != nil {
+			t.Errorf("Expected no error, got %s", err.Error())
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if b.disconnects != 1 {
+			t.Errorf("Expected a clean disconnection, got %d", b.disconnects)
+		}
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		refusing := fakeTCPBroker(t)
+		refusing.code = 5
+
+		c := mqtt.New(mqtt.Options{Broker: refusing.url("tcp"), ClientID: "geocast-test"}, logger.Init())
+
+		if err := c.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), "not authorized") {
+			t.Errorf("Expected a not authorized error, got %v", err)
+		}
+	})
+
+	t.Run("Brokers", func(t *testing.T) {
+		for _, broker := range []string{"http://localhost", "localhost:1883", "tcp://"} {
+			c := mqtt.New(mqtt.Options{Broker: broker}, logger.Init())
+
+			if err := c.Connect(context.Background()); err == nil {
+				t.Errorf("Expected an error for %s", broker)
+			}
+		}
+	})
+}
+
+func TestMQTTTLS(t *testing.T) {
+	// Borrow the test certificate of httptest, valid for 127.0.0.1.
+	srv := httptest.NewTLSServer(nil)
+	srv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	b := newFakeBroker(t, ln)
+
+	t.Run("Untrusted", func(t *testing.T) {
+		c := mqtt.New(mqtt.Options{Broker: b.url("ssl"), ClientID: "geocast-test"}, logger.Init())
+
+		if err := c.Connect(context.Background()); err == nil {
+			t.Error("Expected an untrusted certificate to be rejected")
+		}
+	})
+
+	t.Run("CA", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+
+		c := mqtt.New(mqtt.Options{Broker: b.url("ssl"), ClientID: "geocast-test", TLS: &tls.Config{RootCAs: pool}, QoS: 1}, logger.Init())
+
+		if err := c.Connect(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		defer c.Close()
+
+		if err := c.Publish(mqtt.Message{Topic: "geocast/test/conditions", Payload: []byte("{}")}); err != nil {
+			t.Errorf("Expected no error, got %s", err.Error())
+		}
+
+		if _, ok := b.retained()["geocast/test/conditions"]; !ok {
+			t.Error("Expected the message over TLS")
+		}
+	})
+}
+
+func TestHomeAssistantDiscovery(t *testing.T) {
+	topics := hass.Topics{Conditions: "geocast/home/conditions", Forecast: "geocast/home/forecast", Alerts: "geocast/home/alerts", Availability: "geocast/home/availability"}
+	messages, err := hass.Discovery("homeassistant", "Austin, TX", topics, "si")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	configs := map[string]hass.Config{}
+
+	for _, m := range messages {
+		c := hass.Config{}
+
+		if err := json.Unmarshal(m.Payload, &c); err != nil {
+			t.Fatalf("Expected JSON, got %s", m.Payload)
+		}
+
+		configs[m.Topic] = c
+	}
+
+	temp, ok := configs["homeassistant/sensor/geocast_austin_tx/temperature/config"]
+
+	if !ok {
+		t.Fatalf("Expected a temperature sensor, got %v", configs)
+	}
+
+	if temp.StateTopic != topics.Conditions || temp.Unit != "°C" || temp.DeviceClass != "temperature" || temp.UniqueID != "geocast_austin_tx_temperature" {
+		t.Errorf("Unexpected temperature sensor %+v", temp)
+	}
+
+	if temp.AvailabilityTopic != topics.Availability || temp.Device.Identifiers[0] != "geocast_austin_tx" {
+		t.Errorf("Unexpected availability or device %+v", temp)
+	}
+
+	if alert, ok := configs["homeassistant/binary_sensor/geocast_austin_tx/alert/config"]; !ok || alert.StateTopic != topics.Alerts {
+		t.Errorf("Expected an alert binary sensor, got %+v", alert)
+	}
+
+	if id := hass.ID("78701, Austin, TX, US"); id != "78701_austin_tx_us" {
+		t.Errorf("Unexpected ID %s", id)
+	}
+}
+
+func TestPublishCommand(t *testing.T) {
+	b := fakeTCPBroker(t)
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+	t.Setenv("MQTT_USERNAME", "geocast")
+	t.Setenv("MQTT_PASSWORD", "secret")
+
+	t.Run("Once", func(t *testing.T) {
+		out, err := runGeocast(t, "publish", "mqtt", "--broker", b.url("tcp"), "--topic", "office/weather", "--once", "--zip", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "Published the weather") {
+			t.Errorf("Unexpected output %s", out)
+		}
+
+		retained := b.retained()
+
+		for _, topic := range []string{"office/weather/conditions", "office/weather/forecast", "office/weather/alerts", "office/weather/availability"} {
+			if m, ok := retained[topic]; !ok || !m.Retain {
+				t.Errorf("Expected a retained message on %s, got %+v", topic, m)
+			}
+		}
+
+		if retained["office/weather/availability"].Payload != hass.Online {
+			t.Errorf("Expected the availability to be online, got %+v", retained["office/weather/availability"])
+		}
+
+		if !strings.Contains(retained["office/weather/conditions"].Payload, `"temperature":95`) {
+			t.Errorf("Unexpected conditions %s", retained["office/weather/conditions"].Payload)
+		}
+
+		if !strings.Contains(retained["office/weather/alerts"].Payload, "Heat Advisory") {
+			t.Errorf("Unexpected alerts %s", retained["office/weather/alerts"].Payload)
+		}
+
+		discovery := 0
+
+		for topic := range retained {
+			if strings.HasPrefix(topic, "homeassistant/") && strings.HasSuffix(topic, "/config") {
+				discovery++
+			}
+		}
+
+		if discovery == 0 {
+			t.Error("Expected Home Assistant discovery messages")
+		}
+
+		if c := b.connects[len(b.connects)-1]; c.Username != "geocast" || c.Password != "secret" || c.ClientID != "geocast-78701_austin_tx_us" {
+			t.Errorf("Unexpected connection %+v", c)
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		b := fakeTCPBroker(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+
+		go func() {
+			errs <- cli.Application().RunContext(ctx, []string{"geocast", "publish", "mqtt", "--broker", b.url("tcp"), "--topic", "office/weather", "--discovery=false", "--interval", "50ms", "--zip", "78701"})
+		}()
+
+		deadline := time.Now().Add(5 * time.Second)
+
+		for time.Now().Before(deadline) {
+			b.mu.Lock()
+			n := len(b.messages)
+			b.mu.Unlock()
+
+			if n >= 7 {
+				break
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		// A broker restart is followed by a reconnection.
+		b.dropAll()
+
+		for time.Now().Before(deadline) {
+			b.mu.Lock()
+			n := len(b.connects)
+			b.mu.Unlock()
+
+			if n >= 2 {
+				break
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		cancel()
+
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Errorf("Expected no error, got %s", err.Error())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the publisher to stop")
+		}
+
+		if len(b.connects) < 2 {
+			t.Errorf("Expected a reconnection, got %d connections", len(b.connects))
+		}
+
+		if m := b.retained()["office/weather/availability"]; m.Payload != hass.Offline {
+			t.Errorf("Expected the availability to be offline after stopping, got %+v", m)
+		}
+
+		for topic := range b.retained() {
+			if strings.HasPrefix(topic, "homeassistant/") {
+				t.Errorf("Expected no discovery messages, got %s", topic)
+			}
+		}
+	})
+
+	t.Run("TLSFlags", func(t *testing.T) {
+		ca := filepath.Join(dir, "ca.pem")
+		os.WriteFile(ca, []byte("not a certificate"), 0o644)
+
+		if _, err := runGeocast(t, "publish", "mqtt", "--broker", "ssl://127.0.0.1:1", "--ca-file", ca, "--once", "--zip", "78701"); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+			t.Errorf("Expected an invalid CA file error, got %v", err)
+		}
+
+		if _, err := runGeocast(t, "publish", "mqtt", "--cert-file", ca, "--once", "--zip", "78701"); err == nil {
+			t.Error("Expected an error for --cert-file without --key-file")
+		}
+
+		if _, err := runGeocast(t, "publish", "mqtt", "--qos", "2", "--once", "--zip", "78701"); err == nil {
+			t.Error("Expected an error for QoS 2")
+		}
+	})
+}
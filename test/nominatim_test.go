@@ -1,6 +1,7 @@
 package test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -141,4 +142,29 @@ func TestNominatimClient(t *testing.T) {
 			}
 		})
 	})
+	t.Run("NoResults", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.RawQuery, "Atlantis") {
+				w.Write([]byte(`[]`))
+
+				return
+			}
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		defer server.Close()
+
+		client := osm.Client()
+		client.SetURL(server.URL)
+
+		if _, err := client.GeocodeByCity("Atlantis"); !errors.Is(err, osm.ErrNoResults) {
+			t.Errorf("Expected ErrNoResults, got %v", err)
+		}
+
+		// Unavailable is not the same as not found.
+		if _, err := client.GeocodeByCity("Seattle"); err == nil || errors.Is(err, osm.ErrNoResults) {
+			t.Errorf("Expected a request error, got %v", err)
+		}
+	})
 }
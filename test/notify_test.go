@@ -0,0 +1,406 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/notify"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+// struct webhookRequest is a request received by the fake webhook.
+type webhookRequest struct {
+	Path    string
+	Header  http.Header
+	Payload string
+}
+
+// func webhookServer records the requests it receives, failing while fail is
+// set.
+func webhookServer(t *testing.T) (*httptest.Server, func() []webhookRequest, func(bool)) {
+	mu := sync.Mutex{}
+	requests := []webhookRequest{}
+	failing := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		requests = append(requests, webhookRequest{Path: r.URL.Path, Header: r.Header, Payload: string(data)})
+	}))
+
+	t.Cleanup(srv.Close)
+
+	received := func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]webhookRequest{}, requests...)
+	}
+
+	fail := func(f bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		failing = f
+	}
+
+	return srv, received, fail
+}
+
+func TestNotifyAlerts(t *testing.T) {
+	city := nws.Austin()
+	now := time.Date(2024, 8, 2, 18, 0, 0, 0, time.UTC)
+	watch := nws.AlertAPIResponse{ID: "urn:1", Event: "Tornado Watch", Severity: "Severe", Headline: "Tornado Watch until 9PM", Expires: "2024-08-02T21:00:00Z"}
+
+	events, seen := notify.Alerts("home", city, []nws.AlertAPIResponse{watch}, nil, now)
+
+	if len(events) != 1 || events[0].Kind != notify.KindAlert || events[0].Key != "urn:1" || events[0].Message != watch.Headline {
+		t.Fatalf("Expected a new alert, got %+v", events)
+	}
+
+	t.Run("Upgrade", func(t *testing.T) {
+		warning := nws.AlertAPIResponse{ID: "urn:2", Event: "Tornado Warning", Severity: "Extreme", MessageType: "Update", Expires: "2024-08-02T21:00:00Z", References: []nws.AlertReference{{Identifier: "urn:1"}}}
+		events, active := notify.Alerts("home", city, []nws.AlertAPIResponse{warning}, seen, now)
+
+		if len(events) != 1 || events[0].Kind != notify.KindUpdate || !events[0].Upgraded || !strings.HasPrefix(events[0].Title, "Upgraded: Tornado Warning") {
+			t.Errorf("Expected an upgrade and no ended watch, got %+v", events)
+		}
+
+		if _, ok := active["urn:2"]; !ok || len(active) != 1 {
+			t.Errorf("Expected the warning to replace the watch, got %v", active)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		cancel := nws.AlertAPIResponse{ID: "urn:3", Event: "Tornado Watch", MessageType: "Cancel", References: []nws.AlertReference{{Identifier: "urn:1"}}}
+		events, active := notify.Alerts("home", city, []nws.AlertAPIResponse{cancel}, seen, now)
+
+		if len(events) != 1 || events[0].Kind != notify.KindCancel || events[0].Key != "urn:3" {
+			t.Errorf("Expected a cancellation, got %+v", events)
+		}
+
+		if len(active) != 0 {
+			t.Errorf("Expected no active alerts, got %v", active)
+		}
+	})
+
+	t.Run("Ended", func(t *testing.T) {
+		events, _ := notify.Alerts("home", city, nil, seen, now)
+
+		if len(events) != 1 || events[0].Kind != notify.KindCancel || events[0].Key != "ended:urn:1" {
+			t.Errorf("Expected the watch to have ended, got %+v", events)
+		}
+
+		if events, _ := notify.Alerts("home", city, nil, seen, now.Add(4*time.Hour)); len(events) != 0 {
+			t.Errorf("Expected no events for an expired alert, got %+v", events)
+		}
+	})
+}
+
+func TestNotifyThresholds(t *testing.T) {
+	f := nws.ForecastAPIResponse{}
+
+	if err := json.Unmarshal([]byte(forecastJSON), &f); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	forecast := view.NewForecast(nws.Austin(), nws.UnitsUS, f.Properties.Periods)
+	now := time.Date(2024, 8, 2, 18, 0, 0, 0, time.UTC)
+	thresholds := []notify.Threshold{}
+
+	for _, s := range []string{"temperature>=100", "Precipitation > 10", "wind>8", "temperature<50"} {
+		th, err := notify.ParseThreshold(s)
+
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %s", s, err.Error())
+		}
+
+		thresholds = append(thresholds, th)
+	}
+
+	for _, s := range []string{"humidity>50", "temperature=>100", "wind>fast"} {
+		if _, err := notify.ParseThreshold(s); err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+
+	events := notify.Thresholds("home", forecast, thresholds, now, now.Add(48*time.Hour))
+	keys := []string{}
+
+	for _, e := range events {
+		keys = append(keys, e.Key)
+	}
+
+	want := []string{
+		"threshold:home:precipitation>10:2024-08-02T18:00:00-05:00",
+		"threshold:home:temperature>=100:2024-08-03T06:00:00-05:00",
+		"threshold:home:wind>8:2024-08-03T06:00:00-05:00",
+	}
+
+	if strings.Join(keys, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, keys)
+	}
+
+	if events[1].Title != "Saturday: temperature>=100 for Austin" || events[1].Period == nil {
+		t.Errorf("Unexpected event %+v", events[1])
+	}
+
+	// Tonight starts at 23:00 UTC, Saturday after the horizon.
+	if events := notify.Thresholds("home", forecast, thresholds, now, now.Add(6*time.Hour)); len(events) != 1 {
+		t.Errorf("Expected only Tonight within the horizon, got %+v", events)
+	}
+}
+
+func TestNotifyWebhooks(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://hooks.slack.com/services/T0/B0/x":  notify.FormatSlack,
+		"https://discord.com/api/webhooks/1/x":      notify.FormatDiscord,
+		"https://ntfy.sh/geocast":                   notify.FormatNtfy,
+		"https://example.com/hook":                  notify.FormatJSON,
+		"ntfy=https://ntfy.example.com/geocast":     notify.FormatNtfy,
+		"slack=https://chat.example.com/hook?x=a=b": notify.FormatSlack,
+	} {
+		w, err := notify.ParseWebhook(in)
+
+		if err != nil || w.Format != want {
+			t.Errorf("Expected %s for %s, got %+v (%v)", want, in, w, err)
+		}
+	}
+
+	for _, in := range []string{"example.com/hook", "teams=https://example.com", "ftp://example.com"} {
+		if _, err := notify.ParseWebhook(in); err == nil {
+			t.Errorf("Expected an error for %s", in)
+		}
+	}
+
+	if w, _ := notify.ParseWebhook("https://hooks.slack.com/services/T0/B0/secret"); strings.Contains(w.String(), "secret") || strings.Contains(w.ID(), "secret") {
+		t.Errorf("Expected the webhook URL to be hidden, got %s and %s", w.String(), w.ID())
+	}
+
+	srv, received, fail := webhookServer(t)
+	e := notify.Event{Key: "urn:1", Kind: notify.KindAlert, Place: "home", Title: "Tornado Warning for Austin, TX", Message: "Take cover.", Severity: "Extreme"}
+
+	t.Run("Formats", func(t *testing.T) {
+		for _, format := range notify.Formats {
+			w := notify.Webhook{Format: format, URL: srv.URL + "/" + format}
+
+			if err := w.Send(context.Background(), http.DefaultClient, e); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+		}
+
+		requests := received()
+
+		if len(requests) != 4 {
+			t.Fatalf("Expected 4 requests, got %d", len(requests))
+		}
+
+		payloads := map[string]string{}
+
+		for _, r := range requests {
+			payloads[r.Path] = r.Payload
+		}
+
+		if !strings.Contains(payloads["/json"], `"kind":"alert"`) || !strings.Contains(payloads["/json"], `"severity":"Extreme"`) {
+			t.Errorf("Unexpected JSON payload %s", payloads["/json"])
+		}
+
+		for path, want := range map[string]string{
+			"/slack":   `{"text":"*Tornado Warning for Austin, TX*\nTake cover."}`,
+			"/discord": `{"content":"**Tornado Warning for Austin, TX**\nTake cover."}`,
+			"/ntfy":    "Take cover.",
+		} {
+			if payloads[path] != want {
+				t.Errorf("Expected %s on %s, got %s", want, path, payloads[path])
+			}
+		}
+
+		if ntfy := requests[3]; ntfy.Header.Get("Priority") != "5" || ntfy.Header.Get("Title") != e.Title || ntfy.Header.Get("Tags") != "warning" {
+			t.Errorf("Unexpected ntfy headers %v", ntfy.Header)
+		}
+	})
+
+	t.Run("State", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notify.json")
+		state, err := notify.Open(path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		hook := notify.Webhook{Format: notify.FormatJSON, URL: srv.URL + "/state"}
+		now := time.Now()
+		before := len(received())
+
+		fail(true)
+
+		if sent, err := state.Deliver(context.Background(), http.DefaultClient, []notify.Webhook{hook}, []notify.Event{e}, now); sent != 0 || err == nil || !strings.Contains(err.Error(), "503") {
+			t.Errorf("Expected a failed delivery, got %d, %v", sent, err)
+		}
+
+		fail(false)
+
+		if sent, err := state.Deliver(context.Background(), http.DefaultClient, []notify.Webhook{hook}, []notify.Event{e, e}, now); sent != 1 || err != nil {
+			t.Errorf("Expected the event to be sent once, got %d, %v", sent, err)
+		}
+
+		if err := state.Save(now); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		state, _ = notify.Open(path)
+
+		if sent, _ := state.Deliver(context.Background(), http.DefaultClient, []notify.Webhook{hook}, []notify.Event{e}, now); sent != 0 {
+			t.Errorf("Expected the saved state to deduplicate the event, got %d", sent)
+		}
+
+		if n := len(received()) - before; n != 1 {
+			t.Errorf("Expected a single request, got %d", n)
+		}
+
+		// Events are forgotten after the retention period.
+		state.Save(now.Add(notify.Retention + time.Hour))
+
+		if state.IsSent(hook, e.Key) {
+			t.Error("Expected the event to be forgotten")
+		}
+
+		old := e
+		old.Time = now.Format(time.RFC3339)
+		state.Pending = []notify.Event{old}
+		state.Save(now.Add(notify.Retention + time.Hour))
+
+		if len(state.Pending) != 0 {
+			t.Errorf("Expected the pending event to be forgotten, got %+v", state.Pending)
+		}
+	})
+}
+
+func TestNotifyCommand(t *testing.T) {
+	dir := t.TempDir()
+	srv, received, fail := webhookServer(t)
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+	t.Setenv("NOTIFY_WEBHOOKS", srv.URL+"/hook")
+
+	os.MkdirAll(filepath.Join(dir, "geocast"), 0o755)
+	os.WriteFile(filepath.Join(dir, "geocast", "places.toml"), []byte("[[places]]\nname = \"home\"\nlat = 30.2672\nlon = -97.7431\n"), 0o644)
+
+	t.Run("Once", func(t *testing.T) {
+		// The fixtures are in the past, so every period is within the horizon.
+		out, err := runGeocast(t, "notify", "--once", "--threshold", "temperature>=100")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "Notifications sent: 2") {
+			t.Errorf("Unexpected output %s", out)
+		}
+
+		requests := received()
+
+		if len(requests) != 2 || !strings.Contains(requests[0].Payload, `"title":"Heat Advisory for home"`) || !strings.Contains(requests[1].Payload, `"kind":"threshold"`) {
+			t.Errorf("Unexpected requests %+v", requests)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "state", "geocast", notify.File)); err != nil {
+			t.Errorf("Expected the state to be saved, got %s", err.Error())
+		}
+	})
+
+	t.Run("Deduplicated", func(t *testing.T) {
+		out, err := runGeocast(t, "notify", "--once", "--threshold", "temperature>=100")
+
+		if err != nil || !strings.Contains(out, "Notifications sent: 0") {
+			t.Errorf("Expected nothing to be sent again, got %s (%v)", out, err)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		fail(true)
+		defer fail(false)
+
+		if _, err := runGeocast(t, "notify", "--once", "--threshold", "wind>8"); err == nil {
+			t.Error("Expected the failed delivery to be reported")
+		}
+
+		fail(false)
+
+		out, err := runGeocast(t, "notify", "--once", "--threshold", "wind>8")
+
+		if err != nil || !strings.Contains(out, "Notifications sent: 1") {
+			t.Errorf("Expected the failed notification to be sent, got %s (%v)", out, err)
+		}
+	})
+
+	t.Run("Pending across runs", func(t *testing.T) {
+		path := filepath.Join(dir, "state", "geocast", notify.File)
+		state, err := notify.Open(path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		// An alert that was active in the last poll and has ended since.
+		state.Alerts["home"]["urn:test:ended"] = view.Alert{ID: "urn:test:ended", Event: "Flood Watch", Expires: time.Now().Add(time.Hour).Format(time.RFC3339)}
+		state.Save(time.Now())
+
+		fail(true)
+
+		if _, err := runGeocast(t, "notify", "--once"); err == nil {
+			t.Error("Expected the failed delivery to be reported")
+		}
+
+		fail(false)
+
+		before := len(received())
+		out, err := runGeocast(t, "notify", "--once")
+
+		if err != nil || !strings.Contains(out, "Notifications sent: 1") {
+			t.Fatalf("Expected the pending notification to be sent by the next run, got %s (%v)", out, err)
+		}
+
+		if requests := received()[before:]; len(requests) != 1 || !strings.Contains(requests[0].Payload, `"key":"ended:urn:test:ended"`) {
+			t.Errorf("Unexpected requests %+v", requests)
+		}
+
+		if state, _ := notify.Open(path); len(state.Pending) != 0 {
+			t.Errorf("Expected no pending events, got %+v", state.Pending)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := runGeocast(t, "notify", "--once", "--threshold", "humidity>50"); err == nil {
+			t.Error("Expected an error for an invalid threshold")
+		}
+
+		t.Setenv("NOTIFY_WEBHOOKS", "")
+
+		if _, err := runGeocast(t, "notify", "--once"); err == nil || !strings.Contains(err.Error(), "no webhooks") {
+			t.Errorf("Expected an error without webhooks, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,405 @@
+package test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+const forecastJSON = `{"properties": {"periods": [
+	{"number": 1, "name": "Tonight", "startTime": "2024-08-02T18:00:00-05:00", "endTime": "2024-08-03T06:00:00-05:00", "isDaytime": false, "temperature": 78, "temperatureUnit": "F", "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": 20}, "windSpeed": "5 mph", "windDirection": "S", "shortForecast": "Mostly Clear", "detailedForecast": "Mostly clear, with a low around 78. South wind around 5 mph."},
+	{"number": 2, "name": "Saturday", "startTime": "2024-08-03T06:00:00-05:00", "endTime": "2024-08-03T18:00:00-05:00", "isDaytime": true, "temperature": 101, "temperatureUnit": "F", "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": null}, "windSpeed": "5 to 10 mph", "windDirection": "S", "shortForecast": "Sunny", "detailedForecast": "Sunny, with a high near 101. Hot | humid."}
+]}}`
+
+const alertsJSON = `{"features": [{"properties": {
+	"id": "urn:oid:2.49.0.1.840.0.1", "areaDesc": "Travis, TX", "effective": "2024-08-02T12:00:00-05:00", "expires": "2024-08-02T20:00:00-05:00",
+	"severity": "Moderate", "certainty": "Likely", "urgency": "Expected", "event": "Heat Advisory", "senderName": "NWS Austin/San Antonio TX",
+	"headline": "Heat Advisory issued August 2 at 12:00PM CDT", "description": "Heat index values up to 111.", "instruction": "Drink plenty of fluids."
+}}]}`
+
+const observationJSON = `{"properties": {
+	"timestamp": "2024-08-02T17:51:00+00:00", "textDescription": "Partly Cloudy",
+	"temperature": {"unitCode": "wmoUnit:degC", "value": 35},
+	"dewpoint": {"unitCode": "wmoUnit:degC", "value": 20},
+	"windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": 180},
+	"windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": 16.09344},
+	"windGust": {"unitCode": "wmoUnit:km_h-1", "value": null},
+	"barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101590},
+	"visibility": {"unitCode": "wmoUnit:m", "value": 16090},
+	"relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 42.3},
+	"heatIndex": {"unitCode": "wmoUnit:degC", "value": 38.5},
+	"windChill": {"unitCode": "wmoUnit:degC", "value": null}
+}}`
+
+// nwsServer fakes the weather.gov endpoints used by geocast. The first
+// station hasn't reported a temperature, so conditions come from the second.
+func nwsServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != nws.UserAgent {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/points/"):
+			fmt.Fprintf(w, `{"properties": {"forecast": "%[1]s/gridpoints/EWX/156,91/forecast", "forecastHourly": "%[1]s/gridpoints/EWX/156,91/forecast/hourly", "observationStations": "%[1]s/gridpoints/EWX/156,91/stations", "timeZone": "America/Chicago"}}`, server.URL)
+		case r.URL.Path == "/gridpoints/EWX/156,91/forecast", r.URL.Path == "/gridpoints/EWX/156,91/forecast/hourly":
+			w.Write([]byte(forecastJSON))
+		case r.URL.Path == "/gridpoints/EWX/156,91/stations":
+			w.Write([]byte(`{"features": [
+				{"id": "https://api.weather.gov/stations/KAUS", "properties": {"stationIdentifier": "KAUS", "name": "Austin-Bergstrom"}},
+				{"id": "https://api.weather.gov/stations/KATT", "properties": {"stationIdentifier": "KATT", "name": "Austin City, Austin Camp Mabry"}}
+			]}`))
+		case r.URL.Path == "/stations/KAUS/observations/latest":
+			w.Write([]byte(`{"properties": {"textDescription": "", "temperature": {"unitCode": "wmoUnit:degC", "value": null}}}`))
+		case r.URL.Path == "/stations/KATT/observations/latest":
+			w.Write([]byte(observationJSON))
+		case r.URL.Path == "/alerts/active" && r.URL.Query().Get("point") != "":
+			w.Write([]byte(alertsJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title": "Not Found", "status": 404, "detail": "Unknown path"}`))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func weatherClient(t *testing.T) *nws.WeatherClient {
+	client := nws.NewWeatherClient()
+	client.SetURL(nwsServer(t).URL)
+	client.SetLogger(logger.Init())
+
+	return client
+}
+
+func TestWeatherEndpoints(t *testing.T) {
+	client := weatherClient(t)
+	city := nws.Austin()
+
+	t.Run("Alerts", func(t *testing.T) {
+		alerts, err := client.GetAlerts(city)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if a := alerts.Alerts(); len(a) != 1 || a[0].Event != "Heat Advisory" {
+			t.Errorf("Unexpected alerts %+v", a)
+		}
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		obs, err := client.GetConditions(city)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if obs.Station.StationIdentifier != "KATT" || obs.Properties.TextDescription != "Partly Cloudy" {
+			t.Errorf("Expected the first station with a temperature, got %+v", obs.Station)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		client := nws.NewWeatherClient()
+		client.SetURL(nwsServer(t).URL + "/missing")
+		client.SetLogger(logger.Init())
+
+		if _, err := client.GetWeather(city); err == nil || !strings.Contains(err.Error(), "Unknown path") {
+			t.Errorf("Expected the problem detail in the error, got %v", err)
+		}
+	})
+
+	t.Run("Convert", func(t *testing.T) {
+		c := 35.0
+		q := nws.Quantity{UnitCode: "wmoUnit:degC", Value: &c}
+
+		if v := q.Convert(nws.UnitsUS); *v != 95 {
+			t.Errorf("Expected 95°F, got %f", *v)
+		}
+
+		if v := q.Convert(nws.UnitsSI); *v != 35 {
+			t.Errorf("Expected 35°C, got %f", *v)
+		}
+
+		if v := (nws.Quantity{UnitCode: "wmoUnit:degC"}).Convert(nws.UnitsUS); v != nil {
+			t.Errorf("Expected a missing value to stay missing, got %f", *v)
+		}
+	})
+}
+
+func testForecast(t *testing.T) view.Forecast {
+	fc := nws.ForecastAPIResponse{}
+
+	if err := json.Unmarshal([]byte(forecastJSON), &fc); err != nil {
+		t.Fatal(err)
+	}
+
+	return view.NewForecast(nws.Austin(), nws.UnitsUS, fc.Properties.Periods)
+}
+
+func renderAs(t *testing.T, format string, r view.Result) string {
+	buf := bytes.Buffer{}
+
+	rd, err := view.NewRenderer(format, &buf, view.Options{Verbosity: 2})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	if err := rd.Render(r); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+func TestRenderers(t *testing.T) {
+	f := testForecast(t)
+
+	t.Run("JSON", func(t *testing.T) {
+		out := renderAs(t, view.FormatJSON, f)
+		got := map[string]any{}
+
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("Expected valid JSON, got %s", out)
+		}
+
+		location := got["location"].(map[string]any)
+		periods := got["periods"].([]any)
+		first := periods[0].(map[string]any)
+
+		if location["name"] != "Austin" || got["units"] != "us" || len(periods) != 2 {
+			t.Errorf("Unexpected forecast %s", out)
+		}
+
+		if first["temperature"] != 78.0 || first["temperature_unit"] != "F" || first["precipitation_chance"] != 20.0 {
+			t.Errorf("Unexpected period %v", first)
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(renderAs(t, view.FormatNDJSON, f)), "\n")
+
+		if len(lines) != 2 || !strings.HasPrefix(lines[1], `{"number":2,"name":"Saturday"`) {
+			t.Errorf("Expected one period per line, got %v", lines)
+		}
+
+		single := strings.TrimSpace(renderAs(t, view.FormatNDJSON, f.Location))
+
+		if single != `{"name":"Austin","latitude":30.2672,"longitude":-97.7431}` {
+			t.Errorf("Expected a single line, got %s", single)
+		}
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		out := renderAs(t, view.FormatYAML, f)
+
+		for _, want := range []string{"location:\n  name: Austin\n", "units: us\n", "  - number: 1\n    name: Tonight\n", "    temperature_unit: F\n"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in %s", want, out)
+			}
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		records, err := csv.NewReader(strings.NewReader(renderAs(t, view.FormatCSV, f))).ReadAll()
+
+		if err != nil {
+			t.Fatalf("Expected valid CSV, got %s", err.Error())
+		}
+
+		if len(records) != 3 || records[0][1] != "name" || records[2][1] != "Saturday" || records[2][5] != "101" {
+			t.Errorf("Unexpected records %v", records)
+		}
+	})
+
+	t.Run("TSV", func(t *testing.T) {
+		out := renderAs(t, view.FormatTSV, f.Location)
+
+		if out != "name\tlatitude\tlongitude\nAustin\t30.2672\t-97.7431\n" {
+			t.Errorf("Unexpected TSV %q", out)
+		}
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		out := renderAs(t, view.FormatMarkdown, f)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+
+		if len(lines) != 4 || !strings.HasPrefix(lines[1], "| --- |") {
+			t.Errorf("Expected a header, separator and two rows, got %s", out)
+		}
+
+		if !strings.Contains(out, `Hot \| humid.`) {
+			t.Errorf("Expected pipes to be escaped, got %s", out)
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		view.SetOutput(&buf)
+
+		rd, _ := view.NewRenderer(view.FormatText, &buf, view.Options{Verbosity: 2})
+		rd.Render(f)
+
+		out := buf.String()
+
+		if strings.Contains(out, "\x1b[") {
+			t.Errorf("Expected no ANSI escapes when not writing to a terminal, got %q", out)
+		}
+
+		for _, want := range []string{"CITY", "Austin", "TONIGHT", "78°F Mostly Clear", "SATURDAY", "101°F Sunny"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in %s", want, out)
+			}
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, err := view.NewRenderer("xml", &bytes.Buffer{}, view.Options{}); err == nil {
+			t.Errorf("Expected an error for an unknown format")
+		}
+	})
+}
+
+func TestResults(t *testing.T) {
+	client := weatherClient(t)
+	city := nws.Austin()
+
+	t.Run("Alerts", func(t *testing.T) {
+		alerts, _ := client.GetAlerts(city)
+		a := view.NewAlerts(city, alerts.Alerts())
+
+		if out := renderAs(t, view.FormatText, a); !strings.Contains(out, "HEAT ADVISORY") {
+			t.Errorf("Expected the event in %s", out)
+		}
+
+		out := renderAs(t, view.FormatNDJSON, a)
+
+		if !strings.Contains(out, `"event":"Heat Advisory"`) || !strings.Contains(out, `"area":"Travis, TX"`) {
+			t.Errorf("Unexpected alerts %s", out)
+		}
+
+		if out := renderAs(t, view.FormatText, view.NewAlerts(city, nil)); !strings.Contains(out, "No active alerts.") {
+			t.Errorf("Expected no alerts, got %s", out)
+		}
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		obs, _ := client.GetConditions(city)
+
+		us := view.NewConditions(city, nws.UnitsUS, *obs)
+		si := view.NewConditions(city, nws.UnitsSI, *obs)
+
+		if *us.Temperature != 95 || *us.FeelsLike != 101.3 || *us.WindSpeed != 10 || *us.Pressure != 30 || *us.Visibility != 10 {
+			t.Errorf("Unexpected US conditions %s", renderAs(t, view.FormatJSON, us))
+		}
+
+		if *si.Temperature != 35 || *si.WindSpeed != 16.1 || *si.Pressure != 1015.9 || si.WindGust != nil {
+			t.Errorf("Unexpected SI conditions %s", renderAs(t, view.FormatJSON, si))
+		}
+
+		out := renderAs(t, view.FormatText, us)
+
+		for _, want := range []string{"NOW", "95°F Partly Cloudy", "Humidity: 42%", "Wind: 10 mph", "KATT"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in %s", want, out)
+			}
+		}
+
+		if out := renderAs(t, view.FormatJSON, si); !strings.Contains(out, `"wind_gust": null`) {
+			t.Errorf("Expected missing measurements to be null, got %s", out)
+		}
+	})
+}
+
+func TestOutputFlag(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("GEOCAST_OUTPUT", "")
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	t.Run("Forecast", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "--output", "json", "--pt", "30.2672,-97.7431", "--extended")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		f := view.Forecast{}
+
+		if err := json.Unmarshal([]byte(out), &f); err != nil {
+			t.Fatalf("Expected only JSON on stdout, got %s", out)
+		}
+
+		if len(f.Periods) != 2 || !strings.HasPrefix(f.Location.Name, "Austin") {
+			t.Errorf("Unexpected forecast %+v", f)
+		}
+	})
+
+	t.Run("Forecast command", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "forecast", "-o", "ndjson", "--zip", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"name":"Tonight"`) {
+			t.Errorf("Expected the first period only, got %s", out)
+		}
+	})
+
+	t.Run("Geocode", func(t *testing.T) {
+		out, _ := runGeocast(t, "--geocoder", "offline", "-o", "csv", "--zip", "78701", "geocode")
+
+		if !strings.HasPrefix(out, "name,latitude,longitude\n") {
+			t.Errorf("Unexpected CSV %s", out)
+		}
+	})
+
+	t.Run("Alerts", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "alerts", "-o", "yaml", "--zip", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "event: Heat Advisory") {
+			t.Errorf("Unexpected YAML %s", out)
+		}
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "now", "-o", "markdown", "--units", "si", "--zip", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "| KATT |") || !strings.Contains(out, "| si | 35 |") {
+			t.Errorf("Unexpected markdown %s", out)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, err := runGeocast(t, "--geocoder", "offline", "-o", "xml", "--zip", "78701", "geocode"); err == nil {
+			t.Errorf("Expected an error for an unknown format")
+		}
+	})
+}
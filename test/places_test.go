@@ -0,0 +1,210 @@
+package test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/places"
+)
+
+func TestPlacesStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocast", places.File)
+
+	s, err := places.Open(path)
+
+	if err != nil || len(s.Places) != 0 {
+		t.Fatalf("Expected a missing file to be an empty store, got %v %v", s, err)
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		if err := s.Add(places.Place{Name: "@home", Label: "Austin, TX", Lat: 30.2672, Lon: -97.7431}); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if err := s.Add(places.Place{Name: "office", Lat: 42.3601, Lon: -71.0589}); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if err := s.Add(places.Place{Name: "Home"}); err == nil {
+			t.Errorf("Expected an error for a duplicate name")
+		}
+
+		for _, name := range []string{"", "1st", "my home", "home!"} {
+			if err := s.Add(places.Place{Name: name}); err == nil {
+				t.Errorf("Expected an error for the name %q", name)
+			}
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		p, err := s.Get("@HOME")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if city := p.City(); city.Name != "Austin, TX" || city.Lat != 30.2672 || city.Long != -97.7431 {
+			t.Errorf("Unexpected city %+v", city)
+		}
+
+		if city, _ := s.Get("office"); city.City().Name != "office" {
+			t.Errorf("Expected a place without a label to be named after itself, got %+v", city.City())
+		}
+
+		if _, err := s.Get("cabin"); !errors.Is(err, places.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		if _, ok := s.DefaultPlace(); ok {
+			t.Errorf("Expected no default place")
+		}
+
+		if err := s.SetDefault("cabin"); err == nil {
+			t.Errorf("Expected an error for an unknown place")
+		}
+
+		if err := s.SetDefault("office"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if err := s.Rename("office", "work"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if p, ok := s.DefaultPlace(); !ok || p.Name != "work" {
+			t.Errorf("Expected the renamed place to stay the default, got %+v", p)
+		}
+
+		if err := s.Rename("work", "home"); err == nil {
+			t.Errorf("Expected an error when renaming to a saved name")
+		}
+	})
+
+	t.Run("Save", func(t *testing.T) {
+		if err := s.Save(); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		loaded, err := places.Open(path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if len(loaded.Places) != 2 || loaded.Default != "work" || loaded.Places[0].Lat != 30.2672 {
+			t.Errorf("Expected the store to round trip, got %+v", loaded)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := s.Remove("work"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if s.Default != "" || len(s.Places) != 1 {
+			t.Errorf("Expected the default to be cleared, got %+v", s)
+		}
+
+		if err := s.Remove("work"); !errors.Is(err, places.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestPlacesCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_PROFILE", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+
+	t.Run("Add", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "places", "add", "home", "30.2672,-97.7431")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(out, "Saved @home: Austin") {
+			t.Errorf("Expected the point to be named, got %s", out)
+		}
+
+		if _, err := runGeocast(t, "--geocoder", "offline", "places", "add", "--default", "office", "Boston"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if _, err := runGeocast(t, "--geocoder", "offline", "places", "add", "home", "Boston"); err == nil {
+			t.Errorf("Expected an error for a duplicate name")
+		}
+
+		if _, err := runGeocast(t, "--geocoder", "offline", "places", "add", "cabin", "Boston", "--default"); err == nil || !strings.Contains(err.Error(), "flags must come before") {
+			t.Errorf("Expected an error for a flag after the location, got %v", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		out, _ := runGeocast(t, "places", "list")
+
+		for _, want := range []string{"@home", "Austin", "@office", "Boston", "*"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in %s", want, out)
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"Argument", []string{"geocode", "@home"}, "Austin"},
+		{"Flag", []string{"--place", "home", "geocode"}, "Austin"},
+		{"Default", []string{"geocode"}, "Boston"},
+		{"Flag over default", []string{"--zip", "44113", "geocode"}, "Cleveland"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runGeocast(t, append([]string{"--geocoder", "offline"}, tt.args...)...)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("Expected %s in %s", tt.want, out)
+			}
+		})
+	}
+
+	t.Run("Unknown place", func(t *testing.T) {
+		_, err := runGeocast(t, "--geocoder", "offline", "geocode", "@cabin")
+
+		if err == nil || !strings.Contains(err.Error(), `no saved place named "cabin"`) {
+			t.Errorf("Expected an error, got %v", err)
+		}
+	})
+
+	t.Run("Rename and remove", func(t *testing.T) {
+		if _, err := runGeocast(t, "places", "rename", "office", "work"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if out, _ := runGeocast(t, "places", "default"); strings.TrimSpace(out) != "@work" {
+			t.Errorf("Expected @work to be the default, got %s", out)
+		}
+
+		if _, err := runGeocast(t, "places", "remove", "work"); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if out, _ := runGeocast(t, "places", "default"); strings.TrimSpace(out) != "" {
+			t.Errorf("Expected no default, got %s", out)
+		}
+	})
+}
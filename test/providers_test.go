@@ -0,0 +1,178 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/weather/internal/ipinfo"
+)
+
+// providerServer serves a fixed body and records the last requested path.
+func providerServer(t *testing.T, body string, path *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestIPAPIClient(t *testing.T) {
+	t.Run("Geolocate", func(t *testing.T) {
+		path := ""
+		server := providerServer(t, `{"status":"success","query":"8.8.8.8","city":"Ashburn","regionName":"Virginia","countryCode":"US","lat":39.03,"lon":-77.5,"zip":"20149","timezone":"America/New_York","as":"AS15169 Google LLC"}`, &path)
+
+		client := ipinfo.NewIPAPIClient()
+		client.SetURL(server.URL)
+
+		ip := "8.8.8.8"
+		r, err := client.Geolocate(&ip)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if path != "/json/8.8.8.8" {
+			t.Errorf("Expected path /json/8.8.8.8, got %s", path)
+		}
+
+		if r.City != "Ashburn" || r.Region != "Virginia" || r.Country != "US" || r.Location != "39.0300,-77.5000" {
+			t.Errorf("Unexpected response %+v", r)
+		}
+
+		if r.Organization != "AS15169 Google LLC" || r.Timezone != "America/New_York" {
+			t.Errorf("Unexpected response %+v", r)
+		}
+	})
+
+	t.Run("Current device", func(t *testing.T) {
+		path := ""
+		server := providerServer(t, `{"status":"success","query":"8.8.8.8","lat":1,"lon":2}`, &path)
+
+		client := ipinfo.NewIPAPIClient()
+		client.SetURL(server.URL + "/")
+
+		if _, err := client.Geolocate(nil); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if path != "/json/" {
+			t.Errorf("Expected path /json/, got %s", path)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		path := ""
+		server := providerServer(t, `{"status":"fail","message":"reserved range","query":"8.8.8.8"}`, &path)
+
+		client := ipinfo.NewIPAPIClient()
+		client.SetURL(server.URL)
+
+		ip := "8.8.8.8"
+		_, err := client.Geolocate(&ip)
+
+		if err == nil || !strings.Contains(err.Error(), "reserved range") {
+			t.Errorf("Expected a reserved range error, got %v", err)
+		}
+
+		ip = "10.0.0.1"
+
+		if _, err := client.Geolocate(&ip); err == nil {
+			t.Errorf("Expected an error for a private IP")
+		}
+	})
+}
+
+func TestIPWhoisClient(t *testing.T) {
+	t.Run("Geolocate", func(t *testing.T) {
+		path := ""
+		server := providerServer(t, `{"ip":"2001:4860:4860::8888","success":true,"city":"Mountain View","region":"California","country_code":"US","latitude":37.386,"longitude":-122.0838,"postal":"94039","connection":{"asn":15169,"org":"Google LLC"},"timezone":{"id":"America/Los_Angeles"}}`, &path)
+
+		client := ipinfo.NewIPWhoisClient()
+		client.SetURL(server.URL)
+
+		ip := "2001:4860:4860::8888"
+		r, err := client.Geolocate(&ip)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if path != "/2001:4860:4860::8888" {
+			t.Errorf("Expected path /2001:4860:4860::8888, got %s", path)
+		}
+
+		if r.City != "Mountain View" || r.Country != "US" || r.Location != "37.3860,-122.0838" || r.Organization != "AS15169 Google LLC" {
+			t.Errorf("Unexpected response %+v", r)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		path := ""
+		server := providerServer(t, `{"success":false,"message":"Invalid IP address"}`, &path)
+
+		client := ipinfo.NewIPWhoisClient()
+		client.SetURL(server.URL)
+
+		if _, err := client.Geolocate(nil); err == nil || !strings.Contains(err.Error(), "Invalid IP address") {
+			t.Errorf("Expected an invalid IP error, got %v", err)
+		}
+	})
+}
+
+func TestChain(t *testing.T) {
+	path := ""
+	failing := providerServer(t, `{"status":"fail","message":"quota exceeded"}`, &path)
+	working := providerServer(t, `{"ip":"8.8.8.8","success":true,"city":"Austin","latitude":30.2672,"longitude":-97.7431}`, &path)
+
+	ipapi := ipinfo.NewIPAPIClient()
+	ipapi.SetURL(failing.URL)
+
+	ipwhois := ipinfo.NewIPWhoisClient()
+	ipwhois.SetURL(working.URL)
+
+	t.Run("Fallback", func(t *testing.T) {
+		chain := ipinfo.NewChain(
+			ipinfo.Provider{Name: "ipapi", Geolocator: ipapi},
+			ipinfo.Provider{Name: "ipwhois", Geolocator: ipwhois},
+		)
+
+		r, err := chain.Geolocate(nil)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if r.City != "Austin" {
+			t.Errorf("Expected the second provider's result, got %+v", r)
+		}
+	})
+
+	t.Run("All fail", func(t *testing.T) {
+		chain := ipinfo.NewChain(
+			ipinfo.Provider{Name: "ipapi", Geolocator: ipapi},
+			ipinfo.Provider{Name: "ipinfo", Geolocator: ipinfo.NewIPInfoClient("")},
+		)
+
+		_, err := chain.Geolocate(nil)
+
+		if err == nil {
+			t.Fatalf("Expected an error")
+		}
+
+		if !strings.Contains(err.Error(), "ipapi: ") || !strings.Contains(err.Error(), "ipinfo: ") {
+			t.Errorf("Expected errors from both providers, got %s", err.Error())
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := ipinfo.NewChain().Geolocate(nil); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/proxy"
+)
+
+// upstreamServer is a weather.gov that counts requests per path and only
+// answers requests with geocast's User-Agent.
+func upstreamServer(t *testing.T, requests map[string]*atomic.Int64) *httptest.Server {
+	var upstream *httptest.Server
+
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n, ok := requests[r.URL.Path]; ok {
+			n.Add(1)
+		}
+
+		if r.Header.Get("User-Agent") != nws.UserAgent {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+
+		switch r.URL.Path {
+		case "/points/30.2672,-97.7431":
+			w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=3600")
+			fmt.Fprintf(w, `{"properties": {"forecast": "%s/gridpoints/EWX/156,91/forecast"}}`, upstream.URL)
+		case "/expires":
+			w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			w.Write([]byte(`{}`))
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write([]byte(`{}`))
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(upstream.Close)
+
+	return upstream
+}
+
+func proxyGet(t *testing.T, url string) (*http.Response, string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	return res, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	requests := map[string]*atomic.Int64{}
+
+	for _, path := range []string{"/points/30.2672,-97.7431", "/expires", "/no-store", "/slow"} {
+		requests[path] = &atomic.Int64{}
+	}
+
+	upstream := upstreamServer(t, requests)
+	p := proxy.New(upstream.URL, cache.New(t.TempDir()), logger.Init())
+	srv := httptest.NewServer(p.Handler())
+
+	t.Cleanup(srv.Close)
+
+	t.Run("Cache", func(t *testing.T) {
+		res, body := proxyGet(t, srv.URL+"/points/30.2672,-97.7431")
+
+		if res.StatusCode != http.StatusOK || res.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected a miss, got %d %s: %s", res.StatusCode, res.Header.Get("X-Cache"), body)
+		}
+
+		res, _ = proxyGet(t, srv.URL+"/points/30.2672,-97.7431")
+
+		if res.Header.Get("X-Cache") != "HIT" || requests["/points/30.2672,-97.7431"].Load() != 1 {
+			t.Errorf("Expected a hit, got %s after %d requests", res.Header.Get("X-Cache"), requests["/points/30.2672,-97.7431"].Load())
+		}
+
+		if res.Header.Get("Content-Type") != "application/geo+json" {
+			t.Errorf("Expected the upstream content type, got %s", res.Header.Get("Content-Type"))
+		}
+
+		// A second proxy with the same cache directory.
+		shared := httptest.NewServer(proxy.New(upstream.URL, p.Store, logger.Init()).Handler())
+		defer shared.Close()
+
+		if res, _ := proxyGet(t, shared.URL+"/points/30.2672,-97.7431"); res.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected a hit from the shared cache, got %s", res.Header.Get("X-Cache"))
+		}
+	})
+
+	t.Run("CacheHeaders", func(t *testing.T) {
+		proxyGet(t, srv.URL+"/expires")
+		proxyGet(t, srv.URL+"/expires")
+
+		if n := requests["/expires"].Load(); n != 1 {
+			t.Errorf("Expected Expires to be honored, got %d requests", n)
+		}
+
+		proxyGet(t, srv.URL+"/no-store")
+		proxyGet(t, srv.URL+"/no-store")
+
+		if n := requests["/no-store"].Load(); n != 2 {
+			t.Errorf("Expected no-store to be honored, got %d requests", n)
+		}
+	})
+
+	t.Run("Rewrite", func(t *testing.T) {
+		_, body := proxyGet(t, srv.URL+"/points/30.2672,-97.7431")
+
+		if !strings.Contains(body, srv.URL+"/gridpoints/") || strings.Contains(body, upstream.URL) {
+			t.Errorf("Expected upstream URLs to point to the proxy, got %s", body)
+		}
+	})
+
+	t.Run("Coalesce", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+
+		for range 10 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if res, _ := proxyGet(t, srv.URL+"/slow"); res.StatusCode != http.StatusOK {
+					t.Errorf("Expected status 200, got %d", res.StatusCode)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if n := requests["/slow"].Load(); n != 1 {
+			t.Errorf("Expected concurrent requests to share 1 upstream request, got %d", n)
+		}
+	})
+
+	t.Run("Methods", func(t *testing.T) {
+		res, err := http.Post(srv.URL+"/points/30.2672,-97.7431", "application/json", nil)
+
+		if err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status 405, got %v %v", res, err)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		_, body := proxyGet(t, srv.URL+proxy.StatsPath)
+
+		stats := proxy.Stats{}
+
+		if err := json.Unmarshal([]byte(body), &stats); err != nil {
+			t.Fatalf("Expected JSON stats, got %s", body)
+		}
+
+		if stats.Upstream != upstream.URL || stats.Hits < 3 || stats.Coalesced != 9 || stats.Requests != stats.Hits+stats.Misses+stats.Coalesced {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		down := atomic.Bool{}
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			w.Header().Set("Cache-Control", "max-age=1")
+			w.Write([]byte(`{"ok": true}`))
+		}))
+
+		defer flaky.Close()
+
+		srv := httptest.NewServer(proxy.New(flaky.URL, cache.New(t.TempDir()), logger.Init()).Handler())
+
+		defer srv.Close()
+
+		proxyGet(t, srv.URL+"/alerts/active")
+
+		down.Store(true)
+		time.Sleep(1100 * time.Millisecond)
+
+		res, body := proxyGet(t, srv.URL+"/alerts/active")
+
+		if res.StatusCode != http.StatusOK || res.Header.Get("X-Cache") != "STALE" || body != `{"ok": true}` {
+			t.Errorf("Expected the expired response, got %d %s: %s", res.StatusCode, res.Header.Get("X-Cache"), body)
+		}
+
+		if res, _ := proxyGet(t, srv.URL+"/alerts/types"); res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected the upstream error without a cached response, got %d", res.StatusCode)
+		}
+	})
+}
+
+func TestProxyPrune(t *testing.T) {
+	requests := map[string]*atomic.Int64{}
+	upstream := upstreamServer(t, requests)
+	p := proxy.New(upstream.URL, cache.New(t.TempDir()), logger.Init())
+	srv := httptest.NewServer(p.Handler())
+
+	t.Cleanup(srv.Close)
+
+	proxyGet(t, srv.URL+"/points/30.2672,-97.7431")
+	proxyGet(t, srv.URL+"/expires")
+
+	// Unrelated files in the directory are left alone.
+	os.WriteFile(filepath.Join(p.Store.Dir, "other.json"), []byte(`{}`), 0o600)
+
+	if n, err := p.Prune(time.Now()); err != nil || n != 0 {
+		t.Errorf("Expected fresh responses to be kept, got %d, %v", n, err)
+	}
+
+	if n, err := p.Prune(time.Now().Add(time.Hour + p.MaxStale)); err != nil || n != 2 {
+		t.Errorf("Expected the expired responses to be removed, got %d, %v", n, err)
+	}
+
+	files, _ := os.ReadDir(p.Store.Dir)
+
+	if len(files) != 1 || files[0].Name() != "other.json" {
+		t.Errorf("Expected only other.json to be left, got %v", files)
+	}
+
+	if res, _ := proxyGet(t, srv.URL+"/points/30.2672,-97.7431"); res.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("Expected a miss after pruning, got %s", res.Header.Get("X-Cache"))
+	}
+}
+
+func TestProxyWeatherClient(t *testing.T) {
+	upstream := nwsServer(t)
+	p := proxy.New(upstream.URL, cache.New(t.TempDir()), logger.Init())
+	srv := httptest.NewServer(p.Handler())
+
+	t.Cleanup(srv.Close)
+
+	client := nws.NewWeatherClient()
+	client.SetURL(srv.URL)
+	client.SetLogger(logger.Init())
+
+	if _, err := client.GetWeather(nws.Austin()); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	if stats := p.Stats(); stats.Misses != 2 {
+		t.Errorf("Expected the point and its forecast to go through the proxy, got %+v", stats)
+	}
+
+	if _, err := client.GetConditions(nws.Austin()); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+}
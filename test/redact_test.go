@@ -0,0 +1,86 @@
+package test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/transport"
+)
+
+func TestRedact(t *testing.T) {
+	logger.AddSecret("s3cr3t-ipinfo-token", "abc")
+
+	t.Run("Registered secrets", func(t *testing.T) {
+		got := logger.Redact("token is s3cr3t-ipinfo-token.")
+
+		if got != "token is [REDACTED]." {
+			t.Errorf("Unexpected output %s", got)
+		}
+
+		// Values too short to be secrets are not registered.
+		if got := logger.Redact("abc"); got != "abc" {
+			t.Errorf("Expected short values to be left alone, got %s", got)
+		}
+	})
+
+	t.Run("Patterns", func(t *testing.T) {
+		tests := map[string]string{
+			"https://ipinfo.io/8.8.8.8?token=unregistered1": "https://ipinfo.io/8.8.8.8?token=[REDACTED]",
+			"https://example.com/?q=austin&api_key=k123456": "https://example.com/?q=austin&api_key=[REDACTED]",
+			"Authorization: Bearer unregistered2":           "Authorization: Bearer [REDACTED]",
+			`{"token": "unregistered3"}`:                    `{"token": "[REDACTED]"}`,
+			"monkey=banana":                                 "monkey=banana",
+		}
+
+		for input, want := range tests {
+			if got := logger.Redact(input); got != want {
+				t.Errorf("Redact(%q) = %q, want %q", input, got, want)
+			}
+		}
+	})
+
+	t.Run("Writer", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		l := log.New(logger.Writer(&buf))
+
+		l.Error("request failed for s3cr3t-ipinfo-token")
+
+		if strings.Contains(buf.String(), "s3cr3t-ipinfo-token") || !strings.Contains(buf.String(), logger.Redacted) {
+			t.Errorf("Expected the secret to be redacted, got %s", buf.String())
+		}
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		defer server.Close()
+
+		buf := bytes.Buffer{}
+		l := log.New(&buf)
+		l.SetLevel(log.DebugLevel)
+
+		client := http.Client{Transport: transport.New(l)}
+		rsp, err := client.Get(server.URL + "/json?token=s3cr3t-ipinfo-token")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		rsp.Body.Close()
+
+		if strings.Contains(buf.String(), "s3cr3t-ipinfo-token") {
+			t.Errorf("Expected the token to be redacted, got %s", buf.String())
+		}
+
+		if !strings.Contains(buf.String(), "GET") || !strings.Contains(buf.String(), "418") {
+			t.Errorf("Expected the request to be traced, got %s", buf.String())
+		}
+	})
+}
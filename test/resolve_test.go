@@ -0,0 +1,132 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLocationArguments(t *testing.T) {
+	path := ""
+	ipapi := providerServer(t, `{"status":"success","query":"8.8.8.8","city":"Ashburn","regionName":"Virginia","countryCode":"US","lat":39.03,"lon":-77.5}`, &path)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("GEOCAST_OUTPUT", "")
+	t.Setenv("GEOCAST_IP_PROVIDER", "ipapi")
+	t.Setenv("IPAPI_URL", ipapi.URL)
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	if _, err := runGeocast(t, "places", "add", "home", "30.2672,-97.7431"); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	// location runs geocast with JSON output and returns the location.
+	location := func(t *testing.T, args ...string) (map[string]any, error) {
+		out, err := runGeocast(t, append([]string{"--geocoder", "offline", "-o", "json"}, args...)...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result := map[string]any{}
+
+		if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &result); err != nil {
+			t.Fatalf("Expected JSON, got %q", out)
+		}
+
+		if l, ok := result["location"].(map[string]any); ok {
+			return l, nil
+		}
+
+		return result, nil
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+		lat  float64
+	}{
+		{"ZIP code", []string{"78701"}, "78701, Austin, TX, US", 30.2713},
+		{"Coordinates", []string{"forecast", "30.2672,-97.7431"}, "Austin", 30.2672},
+		{"Separate coordinates", []string{"geocode", "30.2672", "-97.7431"}, "Austin", 30.2672},
+		{"City", []string{"forecast", "Austin"}, "Austin", 0},
+		{"City with spaces", []string{"geocode", "San", "Antonio"}, "San Antonio", 0},
+		{"Saved place", []string{"geocode", "@home"}, "Austin, TX, US", 30.2672},
+		{"IP address", []string{"geocode", "8.8.8.8"}, "Ashburn", 39.03},
+		{"Flag", []string{"forecast", "--zip", "78701"}, "78701, Austin, TX, US", 30.2713},
+		{"Geocode ZIP flag", []string{"geocode", "--zip", "78701"}, "78701, Austin, TX, US", 30.2713},
+		{"Geocode point flag", []string{"geocode", "--pt", "30.2672,-97.7431"}, "Austin", 30.2672},
+		{"Geocode place flag", []string{"geocode", "--place", "home"}, "Austin, TX, US", 30.2672},
+		{"Geocode output flag", []string{"geocode", "-o", "json", "Austin"}, "Austin", 0},
+		{"Geocode ZIP subcommand", []string{"geocode", "zip", "78701"}, "78701, Austin, TX, US", 30.2713},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := location(t, tt.args...)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+
+			if name, _ := l["name"].(string); !strings.HasPrefix(name, tt.want) {
+				t.Errorf("Expected %s, got %v", tt.want, l)
+			}
+
+			if lat, _ := l["latitude"].(float64); tt.lat != 0 && lat != tt.lat {
+				t.Errorf("Expected latitude %f, got %v", tt.lat, l)
+			}
+		})
+	}
+
+	t.Run("Argument over default location", func(t *testing.T) {
+		t.Setenv("GEOCAST_LOCATION", "10001")
+
+		l, err := location(t, "geocode", "78701")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if name, _ := l["name"].(string); !strings.Contains(name, "Austin") {
+			t.Errorf("Expected the argument to win, got %v", l)
+		}
+	})
+
+	t.Run("Unknown city", func(t *testing.T) {
+		if _, err := location(t, "forecast", "Xyzzyville"); err == nil {
+			t.Errorf("Expected an error instead of locating the device")
+		}
+	})
+
+	t.Run("Suggestion", func(t *testing.T) {
+		for _, args := range [][]string{{"Clevland"}, {"geocode", "Clevland"}} {
+			_, err := location(t, args...)
+
+			if err == nil || !strings.Contains(err.Error(), "did you mean Cleveland") {
+				t.Errorf("Expected %v to suggest Cleveland, got %v", args, err)
+			}
+		}
+	})
+
+	t.Run("Short names are not geohashes", func(t *testing.T) {
+		for _, name := range []string{"K2", "b52", "dc3"} {
+			if _, err := location(t, "geocode", name); err == nil || !strings.Contains(err.Error(), "city") {
+				t.Errorf("Expected %s to be looked up as a city, got %v", name, err)
+			}
+		}
+
+		if l, err := location(t, "geocode", "geohash:9v6k"); err != nil || l["name"] != "Austin, TX, US" {
+			t.Errorf("Expected the prefix to force a short geohash, got %v (%v)", l, err)
+		}
+	})
+
+	t.Run("Flag after location", func(t *testing.T) {
+		if _, err := location(t, "forecast", "Austin", "--extended"); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
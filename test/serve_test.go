@@ -0,0 +1,325 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/cmd/cli"
+	"github.com/desertthunder/weather/internal/cache"
+	"github.com/desertthunder/weather/internal/ical"
+	"github.com/desertthunder/weather/internal/logger"
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/server"
+)
+
+// apiServer serves the API with weather from nwsServer, locating every
+// request with a location in Austin.
+func apiServer(t *testing.T) *httptest.Server {
+	client := weatherClient(t)
+
+	locate := func(r *http.Request) (nws.City, error) {
+		if r.URL.Query().Get("q") == "" {
+			return nws.City{}, server.ErrNoLocation
+		}
+
+		return nws.Austin(), nil
+	}
+
+	weather := func(units string) (*nws.WeatherClient, error) {
+		if units == "" {
+			units = "us"
+		}
+
+		return client, client.SetUnits(units)
+	}
+
+	s := server.New(locate, weather, logger.Init())
+	api := httptest.NewServer(s.Handler())
+
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	res, err := http.Get(url)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	return res, string(body)
+}
+
+func TestServer(t *testing.T) {
+	api := apiServer(t)
+
+	t.Run("Endpoints", func(t *testing.T) {
+		for path, want := range map[string]string{
+			"/forecast?q=austin": `"detailed_forecast"`,
+			"/hourly?q=austin":   `"periods"`,
+			"/alerts?q=austin":   `"Heat Advisory"`,
+			"/now?q=austin":      `"temperature"`,
+			"/geocode?q=austin":  `"latitude"`,
+			"/healthz":           `"ok"`,
+		} {
+			res, body := get(t, api.URL+path)
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected status 200, got %d: %s", path, res.StatusCode, body)
+			}
+
+			if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("%s: unexpected content type %s", path, ct)
+			}
+
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected %s in %s", path, want, body)
+			}
+
+			if !json.Valid([]byte(body)) {
+				t.Errorf("%s: invalid JSON %s", path, body)
+			}
+		}
+	})
+
+	t.Run("Units", func(t *testing.T) {
+		_, body := get(t, api.URL+"/forecast?q=austin&units=si")
+
+		if !strings.Contains(body, `"units": "si"`) {
+			t.Errorf("Expected SI units, got %s", body)
+		}
+
+		res, body := get(t, api.URL+"/forecast?q=austin&units=kelvin")
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for unknown units, got %d: %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("Problems", func(t *testing.T) {
+		for path, status := range map[string]int{
+			"/forecast":     http.StatusBadRequest,
+			"/geocode":      http.StatusBadRequest,
+			"/nowhere":      http.StatusNotFound,
+			"/forecast.ics": http.StatusBadRequest,
+		} {
+			res, body := get(t, api.URL+path)
+
+			if res.StatusCode != status {
+				t.Errorf("%s: expected status %d, got %d", path, status, res.StatusCode)
+			}
+
+			if ct := res.Header.Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("%s: unexpected content type %s", path, ct)
+			}
+
+			problem := nws.ProblemAPIResponse{}
+
+			if err := json.Unmarshal([]byte(body), &problem); err != nil || problem.Status != status || problem.Detail == "" {
+				t.Errorf("%s: unexpected problem %s", path, body)
+			}
+		}
+	})
+
+	t.Run("Calendar", func(t *testing.T) {
+		res, body := get(t, api.URL+"/forecast.ics?q=austin")
+
+		if ct := res.Header.Get("Content-Type"); ct != ical.ContentType {
+			t.Errorf("Unexpected content type %s", ct)
+		}
+
+		if !strings.Contains(body, "TZID:America/Chicago") || strings.Count(body, "BEGIN:VEVENT") != 3 {
+			t.Errorf("Unexpected calendar %s", body)
+		}
+
+		_, body = get(t, api.URL+"/forecast.ics?q=austin&alerts=false&tz=UTC")
+
+		if strings.Contains(body, "BEGIN:VTIMEZONE") || strings.Count(body, "BEGIN:VEVENT") != 2 {
+			t.Errorf("Unexpected calendar %s", body)
+		}
+	})
+
+	t.Run("OpenAPI", func(t *testing.T) {
+		_, body := get(t, api.URL+"/openapi.json")
+
+		doc := struct {
+			OpenAPI string                    `json:"openapi"`
+			Paths   map[string]map[string]any `json:"paths"`
+		}{}
+
+		if err := json.Unmarshal([]byte(body), &doc); err != nil {
+			t.Fatalf("Expected a JSON document, got %s", err.Error())
+		}
+
+		for _, path := range []string{"/forecast", "/forecast.ics", "/hourly", "/alerts", "/now", "/geocode"} {
+			if _, ok := doc.Paths[path]["get"]; !ok {
+				t.Errorf("Expected GET %s in the OpenAPI document", path)
+			}
+		}
+	})
+
+	t.Run("Method not allowed", func(t *testing.T) {
+		res, err := http.Post(api.URL+"/forecast?q=austin", "application/json", nil)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") == "" {
+			t.Errorf("Expected status 405, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		res, _ := get(t, api.URL+"/healthz")
+
+		if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Errorf("Expected no CORS headers by default, got %s", origin)
+		}
+
+		s := server.New(nil, nil, logger.Init())
+		s.CORSOrigin = "https://dashboard.example.com"
+		cors := httptest.NewServer(s.Handler())
+
+		defer cors.Close()
+
+		res, _ = get(t, cors.URL+"/healthz")
+
+		if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != s.CORSOrigin {
+			t.Errorf("Expected CORS headers, got %v", res.Header)
+		}
+	})
+}
+
+func TestServerCache(t *testing.T) {
+	requests := 0
+	upstream := nwsServer(t)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		req, _ := http.NewRequest(r.Method, upstream.URL+r.URL.RequestURI(), nil)
+		req.Header = r.Header
+
+		res, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		defer res.Body.Close()
+
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+	}))
+
+	t.Cleanup(proxy.Close)
+
+	s := server.New(
+		func(r *http.Request) (nws.City, error) { return nws.Austin(), nil },
+		func(units string) (*nws.WeatherClient, error) {
+			client := nws.NewWeatherClient()
+			client.SetURL(proxy.URL)
+			client.SetLogger(logger.Init())
+
+			return client, nil
+		},
+		logger.Init(),
+	)
+
+	s.Cache = cache.Fetcher{Store: cache.New(t.TempDir()), TTL: time.Minute, Log: logger.Init()}
+
+	api := httptest.NewServer(s.Handler())
+
+	t.Cleanup(api.Close)
+
+	get(t, api.URL+"/now")
+
+	n := requests
+
+	if res, body := get(t, api.URL+"/now"); res.StatusCode != http.StatusOK || requests != n {
+		t.Errorf("Expected cached conditions, got %d requests and %s", requests-n, body)
+	}
+
+	proxy.Close()
+
+	s.Cache.TTL = time.Nanosecond
+
+	if res, body := get(t, api.URL+"/now"); res.StatusCode != http.StatusOK {
+		t.Errorf("Expected expired conditions when weather.gov is down, got %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestServeCommand(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("CACHE_DIR", t.TempDir())
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- cli.Application().RunContext(ctx, []string{"geocast", "serve", "--addr", addr, "--metrics"})
+	}()
+
+	var res *http.Response
+
+	for range 50 {
+		if res, err = http.Get("http://" + addr + "/forecast?zip=78701"); err == nil {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatalf("Expected the server to listen on %s, got %s", addr, err.Error())
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", res.StatusCode)
+	}
+
+	if res, body := get(t, "http://"+addr+"/metrics"); res.StatusCode != http.StatusOK || !strings.Contains(body, "geocast_http_requests_total") {
+		t.Errorf("Expected client metrics, got %d: %s", res.StatusCode, body)
+	}
+
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Expected a graceful shutdown, got %s", err.Error())
+		}
+	case <-time.After(server.ShutdownTimeout):
+		t.Error("Expected the server to stop")
+	}
+}
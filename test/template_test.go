@@ -0,0 +1,171 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/weather/internal/nws"
+	"github.com/desertthunder/weather/internal/view"
+)
+
+func renderTemplate(t *testing.T, text string, r view.Result) string {
+	tmpl, err := view.ParseTemplate(text)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	buf := bytes.Buffer{}
+
+	if err := view.NewTemplateRenderer(tmpl, &buf).Render(r); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+func TestTemplates(t *testing.T) {
+	view.SetOutput(&bytes.Buffer{})
+
+	f := testForecast(t)
+
+	t.Run("Data model", func(t *testing.T) {
+		tests := []struct {
+			template string
+			want     string
+		}{
+			{`{{.City.Name}}: {{(index .Periods 0).Temp}}`, "Austin: 78°F\n"},
+			{`{{.Location.Latitude}},{{.Location.Longitude}} {{.Units}}`, "30.2672,-97.7431 us\n"},
+			{`{{range .Periods}}{{.Name}} {{.Precipitation}} {{.Wind}}|{{end}}`, "Tonight 20% 5 mph S|Saturday 0% 5 to 10 mph S|\n"},
+			{`{{.Summary}}`, "78°F Mostly Clear\n"},
+			{"{{.Temp}}\n", "78°F\n"},
+		}
+
+		for _, tt := range tests {
+			if got := renderTemplate(t, tt.template, f); got != tt.want {
+				t.Errorf("%s: expected %q, got %q", tt.template, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("Helpers", func(t *testing.T) {
+		soon := time.Now().Add(3*time.Hour + time.Minute).Format(time.RFC3339)
+		tests := []struct {
+			template string
+			want     string
+		}{
+			{`{{(index .Periods 1).Temperature | convert "F" "C" | round 1}}`, "38.3"},
+			{`{{convert "mph" "km/h" 10 | round 0}} {{convert "inHg" "hPa" 30 | round 0}} {{convert "°C" "K" 0}}`, "16 1016 273.15"},
+			{`{{icon "Chance Showers And Thunderstorms"}} {{icon "Mostly Clear" false}} {{icon "Sunny"}}`, "⛈ ☁ ☀"},
+			{`[{{pad 8 .City.Name}}][{{lpad 8 .City.Name}}][{{trunc 4 .City.Name}}]`, "[Austin  ][  Austin][Aus…]"},
+			{`{{upper .City.Name}} {{color "red" .City.Name}} {{bold "!"}}`, "AUSTIN Austin !"},
+			{`{{json .Location}}`, `{"name":"Austin","latitude":30.2672,"longitude":-97.7431}`},
+			{`{{relative "` + soon + `"}} {{relative "2020-01-01T00:00:00Z"}}`, "in 3 hours "},
+		}
+
+		for _, tt := range tests {
+			if got := renderTemplate(t, tt.template, f); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("%s: expected %q, got %q", tt.template, tt.want, got)
+			}
+		}
+
+		if _, err := view.Convert("F", "mph", 10); err == nil {
+			t.Errorf("Expected an error converting between measurements")
+		}
+	})
+
+	t.Run("Built-in templates", func(t *testing.T) {
+		if got := renderTemplate(t, "oneline", f); got != "Austin: 78°F Mostly Clear\n" {
+			t.Errorf("Unexpected oneline output %q", got)
+		}
+
+		if got := renderTemplate(t, "tmux", f); got != "⛅ 78°F\n" {
+			t.Errorf("Unexpected tmux output %q", got)
+		}
+
+		motd := renderTemplate(t, "motd", f)
+
+		if !strings.HasPrefix(motd, "Weather for Austin\nTonight            78°F  ☁ Mostly Clear\nSaturday          101°F  ☀ Sunny\n") {
+			t.Errorf("Unexpected motd output %q", motd)
+		}
+
+		if !strings.Contains(renderTemplate(t, "slack", f), "*Austin*") {
+			t.Errorf("Expected the city in bold for Slack")
+		}
+
+		client := weatherClient(t)
+		obs, _ := client.GetConditions(nws.Austin())
+		alerts, _ := client.GetAlerts(nws.Austin())
+
+		if got := renderTemplate(t, "oneline", view.NewConditions(nws.Austin(), nws.UnitsUS, *obs)); got != "Austin: 95°F Partly Cloudy\n" {
+			t.Errorf("Unexpected conditions %q", got)
+		}
+
+		if got := renderTemplate(t, "oneline", view.NewAlerts(nws.Austin(), alerts.Alerts())); got != "Austin: Heat Advisory\n" {
+			t.Errorf("Unexpected alerts %q", got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := view.ParseTemplate("weather"); err == nil || !strings.Contains(err.Error(), "motd, oneline, slack, tmux") {
+			t.Errorf("Expected the built-in templates to be listed, got %v", err)
+		}
+
+		if _, err := view.ParseTemplate("{{.City.Name"); err == nil {
+			t.Errorf("Expected a parse error")
+		}
+
+		tmpl, _ := view.ParseTemplate("{{.Nope}}")
+
+		if err := view.NewTemplateRenderer(tmpl, &bytes.Buffer{}).Render(f); err == nil {
+			t.Errorf("Expected an error for an unknown field")
+		}
+	})
+}
+
+func TestTemplateFlags(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("GEOCAST_CONFIG", "")
+	t.Setenv("GEOCAST_LOCATION", "")
+	t.Setenv("GEOCAST_OUTPUT", "")
+	t.Setenv("NWS_URL", nwsServer(t).URL)
+
+	t.Run("Template", func(t *testing.T) {
+		out, err := runGeocast(t, "--geocoder", "offline", "--zip", "78701", "--template", "{{.City.Name}}: {{(index .Periods 0).Temp}}")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.HasSuffix(out, "Austin, TX, US: 78°F\n") {
+			t.Errorf("Unexpected output %q", out)
+		}
+	})
+
+	t.Run("Template file", func(t *testing.T) {
+		path := filepath.Join(dir, "now.tmpl")
+		os.WriteFile(path, []byte("{{.Station}} {{.Temp}}\n"), 0o644)
+
+		out, err := runGeocast(t, "--geocoder", "offline", "now", "--zip", "78701", "--template-file", path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.HasSuffix(out, "KATT 95°F\n") {
+			t.Errorf("Unexpected output %q", out)
+		}
+	})
+
+	t.Run("Both", func(t *testing.T) {
+		if _, err := runGeocast(t, "--geocoder", "offline", "--template", "oneline", "--template-file", "x.tmpl", "--zip", "78701", "geocode"); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
@@ -34,6 +34,48 @@ func TestUtils(t *testing.T) {
 					t.Errorf("Expected %s to be invalid, got %t", invalid_ip, valid)
 				}
 			})
+
+			t.Run("Public", func(t *testing.T) {
+				for _, ip := range []string{"8.8.0.8", "1.1.1.1", "2001:4860:4860::8888", "::ffff:8.8.8.8"} {
+					if !utils.ValidateIPAddress(ip) {
+						t.Errorf("Expected %s to be valid", ip)
+					}
+				}
+			})
+
+			t.Run("Malformed", func(t *testing.T) {
+				for _, ip := range []string{"", "8.8.8", "8.8.8.8.8", "256.1.1.1", "8.8.8.256", "08.8.8.8", "2001:::1"} {
+					if utils.ValidateIPAddress(ip) {
+						t.Errorf("Expected %s to be invalid", ip)
+					}
+				}
+			})
+
+			t.Run("Reserved", func(t *testing.T) {
+				tests := map[string]string{
+					"10.0.0.1":    "private",
+					"192.168.1.1": "private",
+					"172.16.4.2":  "private",
+					"127.0.0.1":   "loopback",
+					"::1":         "loopback",
+					"169.254.1.1": "link-local",
+					"fe80::1":     "link-local",
+					"100.64.0.1":  "CGNAT",
+					"fd00::1":     "private",
+					"0.0.0.0":     "unspecified",
+					"239.1.2.3":   "multicast",
+				}
+
+				for ip, reason := range tests {
+					_, err := utils.ParseIPAddress(ip)
+
+					if err == nil {
+						t.Errorf("Expected %s to be rejected", ip)
+					} else if !strings.Contains(err.Error(), reason) {
+						t.Errorf("Expected error for %s to mention %s, got %s", ip, reason, err.Error())
+					}
+				}
+			})
 		})
 	})
 
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/desertthunder/weather/internal/ipinfo"
 	"github.com/desertthunder/weather/internal/nws"
 	"github.com/desertthunder/weather/internal/view"
 )
@@ -58,6 +59,30 @@ func TestTable(t *testing.T) {
 	}
 }
 
+func TestIPTable(t *testing.T) {
+	rs := []ipinfo.IPInfoResponse{
+		{
+			IP:       "8.8.8.8",
+			City:     "Mountain View",
+			Country:  "US",
+			Location: "37.4056,-122.0775",
+			ASN:      &ipinfo.ASN{ASN: "AS15169", Name: "Google LLC"},
+			Privacy:  &ipinfo.Privacy{Hosting: true},
+		},
+		{IP: "1.1.1.1", Organization: "AS13335 Cloudflare, Inc."},
+	}
+
+	captured := CaptureOutput(func() {
+		fmt.Println(view.IPTable(rs).Render())
+	})
+
+	for _, want := range []string{"8.8.8.8", "Mountain View, US", "AS15169 Google LLC", "hosting", "AS13335 Cloudflare, Inc."} {
+		if !strings.Contains(captured, want) {
+			t.Errorf("Expected %s not found in output %s", want, captured)
+		}
+	}
+}
+
 func TestLines(t *testing.T) {
 	t.Run("CityLine", func(t *testing.T) {
 		city := nws.Seattle()
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	osm "github.com/desertthunder/weather/internal/nominatim"
+	"github.com/desertthunder/weather/internal/zcta"
+)
+
+func TestZCTA(t *testing.T) {
+	t.Run("Normalize", func(t *testing.T) {
+		tests := []struct {
+			zip     string
+			want    string
+			wantErr bool
+		}{
+			{"78701", "78701", false},
+			{" 02108 ", "02108", false},
+			{"78701-1234", "78701", false},
+			{"7870", "", true},
+			{"787011", "", true},
+			{"78a01", "", true},
+			{"78701-12", "", true},
+		}
+
+		for _, tt := range tests {
+			got, err := zcta.Normalize(tt.zip)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Normalize(%q) error = %v, wantErr %t", tt.zip, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %s, want %s", tt.zip, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		table, err := zcta.New(strings.NewReader("78701\t30.2713\t-97.7426\n"))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		c, ok := table.Lookup("78701-0001")
+
+		if !ok || c.Lat != 30.2713 || c.Lon != -97.7426 {
+			t.Errorf("Unexpected centroid %v (found: %t)", c, ok)
+		}
+
+		if _, ok := table.Lookup("10001"); ok {
+			t.Errorf("Expected 10001 to be missing")
+		}
+
+		city := c.City()
+
+		if !strings.Contains(city.Name, "78701") || !strings.Contains(city.Name, "Austin") {
+			t.Errorf("Expected the city name to include the ZIP and nearest place, got %s", city.Name)
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		table, err := zcta.Default()
+
+		if err != nil {
+			t.Fatalf("Expected bundled table to load, got %s", err.Error())
+		}
+
+		if _, ok := table.Lookup("78701"); !ok {
+			t.Errorf("Expected 78701 to be in the bundled table")
+		}
+	})
+
+	t.Run("Nominatim", func(t *testing.T) {
+		var query string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			w.Write([]byte(`[{"lat": "30.2713", "lon": "-97.7426", "display_name": "78701, Austin, Travis County, Texas, United States"}]`))
+		}))
+
+		defer server.Close()
+
+		client := osm.Client()
+		client.SetURL(server.URL)
+
+		city, err := client.GeocodeByPostalCode("78701", "us")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+
+		if !strings.Contains(query, "postalcode=78701") || !strings.Contains(query, "countrycodes=us") {
+			t.Errorf("Expected a structured postal code query, got %s", query)
+		}
+
+		if city.Lat != 30.2713 {
+			t.Errorf("Expected latitude 30.2713, got %f", city.Lat)
+		}
+	})
+}